@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"fmt"
+	"microsservico-encoder/domain"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// APIKeyRepository define os métodos para manipulação das chaves de acesso à API
+type APIKeyRepository interface {
+	Insert(key *domain.APIKey) (*domain.APIKey, error)  // Registra uma nova chave
+	FindByPrefix(prefix string) (*domain.APIKey, error) // Busca uma chave pelo prefixo público (ErrNotFound se não existir)
+	List(tenantID string) ([]*domain.APIKey, error)     // Lista as chaves do tenant (todas, se vazio), da mais antiga para a mais recente
+	Revoke(id string, at time.Time) error               // Revoga uma chave; revogar novamente mantém a data original
+}
+
+// APIKeyRepositoryDb é a implementação de APIKeyRepository usando GORM
+type APIKeyRepositoryDb struct {
+	Db *gorm.DB // Conexão com o banco de dados via GORM
+}
+
+// Insert adiciona a chave no banco, retornando ErrConflict se o ID ou o prefixo já existirem
+func (repo APIKeyRepositoryDb) Insert(key *domain.APIKey) (*domain.APIKey, error) {
+	err := repo.Db.Create(key).Error
+
+	if isConflict(err) {
+		return nil, fmt.Errorf("api key %v: %w: %v", key.Prefix, ErrConflict, err)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error inserting api key %v: %w", key.Prefix, err)
+	}
+
+	return key, nil
+}
+
+// FindByPrefix busca a chave com o prefixo informado, revogada ou não
+func (repo APIKeyRepositoryDb) FindByPrefix(prefix string) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := repo.Db.First(&key, "prefix = ?", prefix).Error
+
+	if isNotFound(err) {
+		return nil, fmt.Errorf("api key %v: %w", prefix, ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding api key %v: %w", prefix, err)
+	}
+
+	return &key, nil
+}
+
+// List busca as chaves do tenant informado ou, se vazio, todas as chaves
+func (repo APIKeyRepositoryDb) List(tenantID string) ([]*domain.APIKey, error) {
+	var keys []*domain.APIKey
+
+	query := repo.Db
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	err := query.Order("created_at asc, id asc").Find(&keys).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Revoke registra a revogação da chave, retornando ErrNotFound se ela não existir
+func (repo APIKeyRepositoryDb) Revoke(id string, at time.Time) error {
+	result := repo.Db.Model(&domain.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).UpdateColumn("revoked_at", at)
+
+	if result.Error != nil {
+		return fmt.Errorf("error revoking api key %v: %w", id, result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var count int
+	err := repo.Db.Model(&domain.APIKey{}).Where("id = ?", id).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("error revoking api key %v: %w", id, err)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("api key %v: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
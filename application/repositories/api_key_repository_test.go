@@ -0,0 +1,67 @@
+package repositories_test
+
+import (
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestAPIKeyRepositoryDb testa que a chave é encontrada pelo prefixo, guardada apenas
+como hash, listada por tenant e revogada uma única vez
+*/
+func TestAPIKeyRepositoryDb(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	keys := repositories.APIKeyRepositoryDb{Db: db}
+
+	acme, secret, err := domain.NewAPIKey("acme-uploader", "acme", []string{domain.ScopeSubmit, domain.ScopeRead})
+	require.Nil(t, err)
+	_, err = keys.Insert(acme)
+	require.Nil(t, err)
+
+	admin, _, err := domain.NewAPIKey("ops", "", []string{domain.ScopeAdmin})
+	require.Nil(t, err)
+	_, err = keys.Insert(admin)
+	require.Nil(t, err)
+
+	prefix, ok := domain.APIKeyPrefix(secret)
+	require.True(t, ok)
+	require.Equal(t, acme.Prefix, prefix)
+
+	found, err := keys.FindByPrefix(prefix)
+	require.Nil(t, err)
+	require.Equal(t, acme.ID, found.ID)
+	require.NotContains(t, found.KeyHash, secret)
+	require.True(t, found.Matches(secret))
+	require.False(t, found.Matches(secret+"x"))
+	require.True(t, found.HasScope(domain.ScopeRead))
+	require.False(t, found.HasScope(domain.ScopeAdmin))
+
+	_, err = keys.FindByPrefix("enc_000000000000")
+	require.ErrorIs(t, err, repositories.ErrNotFound)
+
+	list, err := keys.List("acme")
+	require.Nil(t, err)
+	require.Len(t, list, 1)
+
+	list, err = keys.List("")
+	require.Nil(t, err)
+	require.Len(t, list, 2)
+
+	revokedAt := time.Now().Add(-time.Minute)
+	require.Nil(t, keys.Revoke(acme.ID, revokedAt))
+	require.Nil(t, keys.Revoke(acme.ID, time.Now()))
+
+	found, err = keys.FindByPrefix(prefix)
+	require.Nil(t, err)
+	require.True(t, found.Revoked())
+	require.WithinDuration(t, revokedAt, *found.RevokedAt, time.Second)
+
+	require.ErrorIs(t, keys.Revoke("00000000-0000-0000-0000-000000000000", time.Now()), repositories.ErrNotFound)
+}
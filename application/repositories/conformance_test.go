@@ -0,0 +1,584 @@
+package repositories_test
+
+import (
+	"errors"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// repositoryFactory cria um par de repositórios vazios, compartilhando o mesmo armazenamento.
+type repositoryFactory func(t *testing.T) (repositories.VideoRepository, repositories.JobRepository)
+
+/*
+TestRepositoryConformance executa a mesma suíte sobre as implementações GORM
+e em memória, garantindo que ambas se comportam da mesma forma.
+*/
+func TestRepositoryConformance(t *testing.T) {
+	factories := map[string]repositoryFactory{
+		"Db": func(t *testing.T) (repositories.VideoRepository, repositories.JobRepository) {
+			db := database.NewDbTest()
+			t.Cleanup(func() { db.Close() })
+			return repositories.VideoRepositoryDb{Db: db}, repositories.JobRepositoryDb{Db: db}
+		},
+		"Memory": func(t *testing.T) (repositories.VideoRepository, repositories.JobRepository) {
+			store := repositories.NewMemoryStore()
+			return repositories.VideoRepositoryMemory{Store: store}, repositories.JobRepositoryMemory{Store: store}
+		},
+	}
+
+	for name, factory := range factories {
+		factory := factory
+		t.Run(name, func(t *testing.T) {
+			t.Run("InsertFindUpdate", func(t *testing.T) { testInsertFindUpdate(t, factory) })
+			t.Run("ListJobs", func(t *testing.T) { testListJobs(t, factory) })
+			t.Run("ListVideos", func(t *testing.T) { testListVideos(t, factory) })
+			t.Run("FindByResourceID", func(t *testing.T) { testFindByResourceID(t, factory) })
+			t.Run("FindByOutput", func(t *testing.T) { testFindByOutput(t, factory) })
+			t.Run("CountByStatus", func(t *testing.T) { testCountByStatus(t, factory) })
+			t.Run("Delete", func(t *testing.T) { testDelete(t, factory) })
+			t.Run("Errors", func(t *testing.T) { testErrors(t, factory) })
+			t.Run("OptimisticLocking", func(t *testing.T) { testOptimisticLocking(t, factory) })
+			t.Run("Heartbeat", func(t *testing.T) { testHeartbeat(t, factory) })
+			t.Run("UpdateStale", func(t *testing.T) { testUpdateStale(t, factory) })
+			t.Run("ListByTag", func(t *testing.T) { testListByTag(t, factory) })
+			t.Run("History", func(t *testing.T) { testHistory(t, factory) })
+			t.Run("Tenants", func(t *testing.T) { testTenants(t, factory) })
+		})
+	}
+}
+
+// baseTime é a data de criação do primeiro registro de cada cenário; os seguintes são criados a cada minuto.
+var baseTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func insertVideo(t *testing.T, repo repositories.VideoRepository, resourceID string, minute int) *domain.Video {
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = resourceID
+	video.FilePath = "path"
+	video.CreatedAt = baseTime.Add(time.Duration(minute) * time.Minute)
+
+	_, err := repo.Insert(video)
+	require.Nil(t, err)
+
+	return video
+}
+
+func insertJob(t *testing.T, repo repositories.JobRepository, video *domain.Video, status string, minute int) *domain.Job {
+	job, err := domain.NewJob("output_path", status, video)
+	require.Nil(t, err)
+	job.CreatedAt = baseTime.Add(time.Duration(minute) * time.Minute)
+
+	_, err = repo.Insert(job)
+	require.Nil(t, err)
+
+	return job
+}
+
+func jobIDs(jobs []*domain.Job) []string {
+	ids := []string{}
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func videoIDs(videos []*domain.Video) []string {
+	ids := []string{}
+	for _, video := range videos {
+		ids = append(ids, video.ID)
+	}
+	return ids
+}
+
+func testInsertFindUpdate(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "resource", 0)
+	job := insertJob(t, jobs, video, domain.JobStatusStarting, 0)
+
+	found, err := jobs.Find(job.ID)
+	require.Nil(t, err)
+	require.Equal(t, video.ID, found.VideoID)
+	require.Equal(t, video.ID, found.Video.ID)
+
+	job.Status = domain.JobStatusCompleted
+	_, err = jobs.Update(job)
+	require.Nil(t, err)
+
+	found, err = jobs.Find(job.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusCompleted, found.Status)
+
+	foundVideo, err := videos.Find(video.ID)
+	require.Nil(t, err)
+	require.Equal(t, []string{job.ID}, jobIDs(foundVideo.Jobs))
+
+	_, err = jobs.Find(uuid.NewV4().String())
+	require.Error(t, err)
+
+	_, err = videos.Find(uuid.NewV4().String())
+	require.Error(t, err)
+}
+
+func testListJobs(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	a := insertVideo(t, videos, "a", 0)
+	b := insertVideo(t, videos, "b", 1)
+	first := insertJob(t, jobs, a, domain.JobStatusCompleted, 0)
+	second := insertJob(t, jobs, b, domain.JobStatusFailed, 1)
+	third := insertJob(t, jobs, a, domain.JobStatusCompleted, 2)
+	fourth := insertJob(t, jobs, b, domain.JobStatusCompleted, 3)
+
+	list, err := jobs.List(repositories.ListFilter{})
+	require.Nil(t, err)
+	require.Equal(t, []string{fourth.ID, third.ID, second.ID, first.ID}, jobIDs(list))
+	require.NotNil(t, list[0].Video)
+
+	list, err = jobs.List(repositories.ListFilter{Status: domain.JobStatusCompleted})
+	require.Nil(t, err)
+	require.Equal(t, []string{fourth.ID, third.ID, first.ID}, jobIDs(list))
+
+	list, err = jobs.List(repositories.ListFilter{ResourceID: "a"})
+	require.Nil(t, err)
+	require.Equal(t, []string{third.ID, first.ID}, jobIDs(list))
+
+	list, err = jobs.List(repositories.ListFilter{ResourceID: "b", Status: domain.JobStatusFailed})
+	require.Nil(t, err)
+	require.Equal(t, []string{second.ID}, jobIDs(list))
+
+	list, err = jobs.List(repositories.ListFilter{CreatedFrom: baseTime.Add(time.Minute), CreatedTo: baseTime.Add(3 * time.Minute)})
+	require.Nil(t, err)
+	require.Equal(t, []string{third.ID, second.ID}, jobIDs(list))
+
+	list, err = jobs.List(repositories.ListFilter{Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, []string{fourth.ID, third.ID}, jobIDs(list))
+
+	list, err = jobs.List(repositories.ListFilter{Limit: 2, Offset: 2})
+	require.Nil(t, err)
+	require.Equal(t, []string{second.ID, first.ID}, jobIDs(list))
+
+	list, err = jobs.List(repositories.ListFilter{Offset: 10})
+	require.Nil(t, err)
+	require.Empty(t, list)
+}
+
+func testListVideos(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	a := insertVideo(t, videos, "a", 0)
+	b := insertVideo(t, videos, "b", 1)
+	c := insertVideo(t, videos, "a", 2)
+	insertJob(t, jobs, a, domain.JobStatusCompleted, 0)
+	insertJob(t, jobs, b, domain.JobStatusFailed, 1)
+	insertJob(t, jobs, b, domain.JobStatusCompleted, 2)
+
+	list, err := videos.List(repositories.ListFilter{})
+	require.Nil(t, err)
+	require.Equal(t, []string{c.ID, b.ID, a.ID}, videoIDs(list))
+
+	list, err = videos.List(repositories.ListFilter{Status: domain.JobStatusCompleted})
+	require.Nil(t, err)
+	require.Equal(t, []string{b.ID, a.ID}, videoIDs(list))
+
+	list, err = videos.List(repositories.ListFilter{ResourceID: "a"})
+	require.Nil(t, err)
+	require.Equal(t, []string{c.ID, a.ID}, videoIDs(list))
+
+	list, err = videos.List(repositories.ListFilter{CreatedFrom: baseTime.Add(time.Minute)})
+	require.Nil(t, err)
+	require.Equal(t, []string{c.ID, b.ID}, videoIDs(list))
+
+	list, err = videos.List(repositories.ListFilter{Limit: 1, Offset: 1})
+	require.Nil(t, err)
+	require.Equal(t, []string{b.ID}, videoIDs(list))
+	require.Len(t, list[0].Jobs, 2)
+}
+
+func testFindByResourceID(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	a := insertVideo(t, videos, "a", 0)
+	b := insertVideo(t, videos, "b", 1)
+	c := insertVideo(t, videos, "a", 2)
+	first := insertJob(t, jobs, a, domain.JobStatusCompleted, 0)
+	insertJob(t, jobs, b, domain.JobStatusCompleted, 1)
+	third := insertJob(t, jobs, c, domain.JobStatusFailed, 2)
+
+	foundVideos, err := videos.FindByResourceID("a")
+	require.Nil(t, err)
+	require.Equal(t, []string{c.ID, a.ID}, videoIDs(foundVideos))
+
+	foundJobs, err := jobs.FindByResourceID("a")
+	require.Nil(t, err)
+	require.Equal(t, []string{third.ID, first.ID}, jobIDs(foundJobs))
+
+	foundVideos, err = videos.FindByResourceID("missing")
+	require.Nil(t, err)
+	require.Empty(t, foundVideos)
+}
+
+func testFindByOutput(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "a", 0)
+	first := insertJob(t, jobs, video, domain.JobStatusCompleted, 0)
+	second := insertJob(t, jobs, video, domain.JobStatusCompleted, 1)
+	other := insertJob(t, jobs, video, domain.JobStatusCompleted, 2)
+
+	for _, job := range []*domain.Job{first, second, other} {
+		job.OutputBucket = "out"
+	}
+	other.OutputBucketPath = "other_path"
+
+	for _, job := range []*domain.Job{first, second, other} {
+		_, err := jobs.Update(job)
+		require.Nil(t, err)
+	}
+
+	found, err := jobs.FindByOutput("out", "output_path")
+	require.Nil(t, err)
+	require.Equal(t, []string{second.ID, first.ID}, jobIDs(found))
+
+	found, err = jobs.FindByOutput("other", "output_path")
+	require.Nil(t, err)
+	require.Empty(t, found)
+}
+
+func testCountByStatus(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	a := insertVideo(t, videos, "a", 0)
+	b := insertVideo(t, videos, "b", 1)
+	insertJob(t, jobs, a, domain.JobStatusCompleted, 0)
+	insertJob(t, jobs, a, domain.JobStatusCompleted, 1)
+	insertJob(t, jobs, a, domain.JobStatusFailed, 2)
+	insertJob(t, jobs, b, domain.JobStatusCompleted, 3)
+
+	counts, err := jobs.CountByStatus()
+	require.Nil(t, err)
+	require.Equal(t, map[string]int{domain.JobStatusCompleted: 3, domain.JobStatusFailed: 1}, counts)
+
+	counts, err = videos.CountByStatus()
+	require.Nil(t, err)
+	require.Equal(t, map[string]int{domain.JobStatusCompleted: 2, domain.JobStatusFailed: 1}, counts)
+}
+
+func testDelete(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	a := insertVideo(t, videos, "a", 0)
+	b := insertVideo(t, videos, "b", 1)
+	first := insertJob(t, jobs, a, domain.JobStatusCompleted, 0)
+	second := insertJob(t, jobs, a, domain.JobStatusFailed, 1)
+	third := insertJob(t, jobs, b, domain.JobStatusCompleted, 2)
+
+	require.Nil(t, jobs.Delete(first.ID))
+	_, err := jobs.Find(first.ID)
+	require.Error(t, err)
+	require.Error(t, jobs.Delete(first.ID))
+
+	require.Nil(t, videos.Delete(a.ID))
+	_, err = videos.Find(a.ID)
+	require.Error(t, err)
+	_, err = jobs.Find(second.ID)
+	require.Error(t, err)
+	require.Error(t, videos.Delete(a.ID))
+
+	list, err := jobs.List(repositories.ListFilter{})
+	require.Nil(t, err)
+	require.Equal(t, []string{third.ID}, jobIDs(list))
+}
+
+func testErrors(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "a", 0)
+	job := insertJob(t, jobs, video, domain.JobStatusCompleted, 0)
+
+	_, err := videos.Find(uuid.NewV4().String())
+	require.True(t, errors.Is(err, repositories.ErrNotFound))
+
+	_, err = jobs.Find(uuid.NewV4().String())
+	require.True(t, errors.Is(err, repositories.ErrNotFound))
+
+	require.True(t, errors.Is(videos.Delete(uuid.NewV4().String()), repositories.ErrNotFound))
+	require.True(t, errors.Is(jobs.Delete(uuid.NewV4().String()), repositories.ErrNotFound))
+
+	duplicateVideo := *video
+	duplicateVideo.Jobs = nil
+	_, err = videos.Insert(&duplicateVideo)
+	require.True(t, errors.Is(err, repositories.ErrConflict))
+
+	duplicateJob := *job
+	_, err = jobs.Insert(&duplicateJob)
+	require.True(t, errors.Is(err, repositories.ErrConflict))
+	require.False(t, errors.Is(err, repositories.ErrStaleVersion))
+}
+
+func testOptimisticLocking(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "a", 0)
+	job := insertJob(t, jobs, video, domain.JobStatusStarting, 0)
+	require.Equal(t, 1, job.Version)
+
+	worker, err := jobs.Find(job.ID)
+	require.Nil(t, err)
+	canceller, err := jobs.Find(job.ID)
+	require.Nil(t, err)
+
+	canceller.Status = domain.JobStatusFailed
+	canceller.Error = "cancelled"
+	_, err = jobs.Update(canceller)
+	require.Nil(t, err)
+	require.Equal(t, 2, canceller.Version)
+
+	worker.Status = domain.JobStatusDownloading
+	_, err = jobs.Update(worker)
+	require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+	require.True(t, errors.Is(err, repositories.ErrConflict))
+	require.Equal(t, 1, worker.Version)
+
+	stored, err := jobs.Find(job.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusFailed, stored.Status)
+	require.Equal(t, "cancelled", stored.Error)
+	require.Equal(t, 2, stored.Version)
+
+	missing := *job
+	missing.ID = uuid.NewV4().String()
+	_, err = jobs.Update(&missing)
+	require.True(t, errors.Is(err, repositories.ErrNotFound))
+}
+
+func testHeartbeat(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "a", 0)
+	running := insertJob(t, jobs, video, domain.JobStatusEncoding, 0)
+	silent := insertJob(t, jobs, video, domain.JobStatusDownloading, 1)
+	finished := insertJob(t, jobs, video, domain.JobStatusCompleted, 2)
+
+	// Os jobs foram atualizados agora; com o heartbeat antigo, apenas running fica travado.
+	past := time.Now().Add(-time.Hour).UTC()
+	require.Nil(t, jobs.Heartbeat(running.ID, past))
+	require.Nil(t, jobs.Heartbeat(finished.ID, past))
+	require.True(t, errors.Is(jobs.Heartbeat(uuid.NewV4().String(), past), repositories.ErrNotFound))
+
+	stale, err := jobs.FindStale(time.Now().Add(-time.Minute), 10)
+	require.Nil(t, err)
+	require.Equal(t, []string{running.ID}, jobIDs(stale))
+	require.NotNil(t, stale[0].Video)
+
+	stale, err = jobs.FindStale(time.Now().Add(time.Minute), 10)
+	require.Nil(t, err)
+	require.Equal(t, []string{running.ID, silent.ID}, jobIDs(stale))
+
+	// O heartbeat não altera a versão e não é sobrescrito pelas atualizações do worker.
+	running.Status = domain.JobStatusUploading
+	_, err = jobs.Update(running)
+	require.Nil(t, err)
+
+	found, err := jobs.Find(running.ID)
+	require.Nil(t, err)
+	require.Equal(t, 2, found.Version)
+	require.NotNil(t, found.HeartbeatAt)
+	require.True(t, found.HeartbeatAt.Equal(past))
+}
+
+func testUpdateStale(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "a", 0)
+	stalled := insertJob(t, jobs, video, domain.JobStatusEncoding, 0)
+	revived := insertJob(t, jobs, video, domain.JobStatusEncoding, 1)
+
+	past := time.Now().Add(-time.Hour).UTC()
+	require.Nil(t, jobs.Heartbeat(stalled.ID, past))
+	require.Nil(t, jobs.Heartbeat(revived.ID, past))
+
+	before := time.Now().Add(-time.Minute)
+	stale, err := jobs.FindStale(before, 10)
+	require.Nil(t, err)
+	require.Len(t, stale, 2)
+
+	// O heartbeat recebido depois da leitura não altera a versão, mas impede a atualização.
+	require.Nil(t, jobs.Heartbeat(revived.ID, time.Now().UTC()))
+
+	for _, job := range stale {
+		job.Status = domain.JobStatusFailed
+		_, err = jobs.UpdateStale(job, before)
+
+		if job.ID == revived.ID {
+			require.True(t, errors.Is(err, repositories.ErrStaleVersion))
+		} else {
+			require.Nil(t, err)
+		}
+	}
+
+	found, err := jobs.Find(stalled.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusFailed, found.Status)
+
+	found, err = jobs.Find(revived.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusEncoding, found.Status)
+	require.Equal(t, 1, found.Version)
+}
+
+func testListByTag(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	promo := domain.NewVideo()
+	promo.ID = uuid.NewV4().String()
+	promo.ResourceID = "promo"
+	promo.FilePath = "path"
+	promo.CreatedAt = baseTime
+	promo.SetTags([]string{"promo", "short"})
+	_, err := videos.Insert(promo)
+	require.Nil(t, err)
+
+	other := insertVideo(t, videos, "other", 1)
+	promoJob := insertJob(t, jobs, promo, domain.JobStatusCompleted, 0)
+	insertJob(t, jobs, other, domain.JobStatusCompleted, 1)
+
+	list, err := videos.List(repositories.ListFilter{Tag: "short"})
+	require.Nil(t, err)
+	require.Equal(t, []string{promo.ID}, videoIDs(list))
+	require.True(t, list[0].HasTag("promo"))
+
+	list, err = videos.List(repositories.ListFilter{Tag: "prom"})
+	require.Nil(t, err)
+	require.Empty(t, list)
+
+	// "_" é um curinga do LIKE: a tag keep_me não pode selecionar os vídeos com keep-me.
+	dashed := insertVideo(t, videos, "dashed", 2)
+	dashed.SetTags([]string{"keep-me"})
+	_, err = videos.Update(dashed)
+	require.Nil(t, err)
+	insertJob(t, jobs, dashed, domain.JobStatusCompleted, 2)
+
+	list, err = videos.List(repositories.ListFilter{Tag: "keep_me"})
+	require.Nil(t, err)
+	require.Empty(t, list)
+
+	jobList, err := jobs.List(repositories.ListFilter{Tag: "keep_me"})
+	require.Nil(t, err)
+	require.Empty(t, jobList)
+
+	list, err = videos.List(repositories.ListFilter{Tag: "keep-me"})
+	require.Nil(t, err)
+	require.Equal(t, []string{dashed.ID}, videoIDs(list))
+
+	jobList, err = jobs.List(repositories.ListFilter{Tag: "promo", ResourceID: "promo"})
+	require.Nil(t, err)
+	require.Equal(t, []string{promoJob.ID}, jobIDs(jobList))
+}
+
+func testHistory(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	video := insertVideo(t, videos, "resource", 0)
+	job := insertJob(t, jobs, video, domain.JobStatusStarting, 0)
+
+	job.Status = domain.JobStatusDownloading
+	_, err := jobs.Update(job)
+	require.Nil(t, err)
+
+	// Atualizações sem mudança de status não entram no histórico.
+	job.SourceContainer = "mp4"
+	_, err = jobs.Update(job)
+	require.Nil(t, err)
+
+	job.Status = domain.JobStatusFailed
+	job.ErrorCode = domain.ErrorCodeDownload
+	_, err = jobs.Update(job)
+	require.Nil(t, err)
+
+	history, err := jobs.History(job.ID)
+	require.Nil(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, domain.JobStatusStarting, history[0].Status)
+	require.Equal(t, 1, history[0].Version)
+	require.Equal(t, domain.JobStatusDownloading, history[1].Status)
+	require.Equal(t, 2, history[1].Version)
+	require.Equal(t, domain.JobStatusFailed, history[2].Status)
+	require.Equal(t, 4, history[2].Version)
+	require.Equal(t, domain.ErrorCodeDownload, history[2].ErrorCode)
+
+	require.Nil(t, jobs.Delete(job.ID))
+
+	history, err = jobs.History(job.ID)
+	require.Nil(t, err)
+	require.Empty(t, history)
+}
+
+func testTenants(t *testing.T, factory repositoryFactory) {
+	videos, jobs := factory(t)
+
+	insertTenantJob := func(tenantID string, status string, seconds float64, minute int) *domain.Job {
+		video := domain.NewVideo()
+		video.ID = uuid.NewV4().String()
+		video.ResourceID = tenantID
+		video.FilePath = "path"
+		video.TenantID = tenantID
+		video.CreatedAt = baseTime.Add(time.Duration(minute) * time.Minute)
+		_, err := videos.Insert(video)
+		require.Nil(t, err)
+
+		job := insertJob(t, jobs, video, status, minute)
+		job.TenantID = tenantID
+		job.DurationSeconds = seconds
+		_, err = jobs.Update(job)
+		require.Nil(t, err)
+
+		return job
+	}
+
+	insertTenantJob("acme", domain.JobStatusCompleted, 90, 0)
+	insertTenantJob("acme", domain.JobStatusFailed, 0, 1)
+	running := insertTenantJob("acme", domain.JobStatusEncoding, 30, 2)
+	news := insertTenantJob("news", domain.JobStatusCompleted, 60, 3)
+	insertTenantJob("news", domain.JobStatusDownloading, 0, 4)
+
+	active, err := jobs.CountActive("acme")
+	require.Nil(t, err)
+	require.Equal(t, 1, active)
+
+	active, err = jobs.CountActive("other")
+	require.Nil(t, err)
+	require.Equal(t, 0, active)
+
+	jobList, err := jobs.List(repositories.ListFilter{TenantID: "acme", Status: domain.JobStatusEncoding})
+	require.Nil(t, err)
+	require.Equal(t, []string{running.ID}, jobIDs(jobList))
+
+	videoList, err := videos.List(repositories.ListFilter{TenantID: "news"})
+	require.Nil(t, err)
+	require.Len(t, videoList, 2)
+
+	usage, err := jobs.Usage(repositories.ListFilter{})
+	require.Nil(t, err)
+	require.Len(t, usage, 2)
+	require.Equal(t, repositories.TenantUsage{TenantID: "acme", Jobs: 3, Completed: 1, Failed: 1, Seconds: 120}, *usage[0])
+	require.Equal(t, 1, usage[0].Active())
+	require.Equal(t, 2.0, usage[0].Minutes())
+	require.Equal(t, repositories.TenantUsage{TenantID: "news", Jobs: 2, Completed: 1, Seconds: 60}, *usage[1])
+
+	// O intervalo de criação inclui CreatedFrom e exclui CreatedTo.
+	usage, err = jobs.Usage(repositories.ListFilter{
+		TenantID:    "news",
+		CreatedFrom: news.CreatedAt,
+		CreatedTo:   news.CreatedAt.Add(time.Minute),
+	})
+	require.Nil(t, err)
+	require.Equal(t, []*repositories.TenantUsage{{TenantID: "news", Jobs: 1, Completed: 1, Seconds: 60}}, usage)
+}
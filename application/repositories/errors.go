@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+/*
+Erros sentinela dos repositórios. São retornados encapsulados com a causa
+(fmt.Errorf com %w), então devem ser comparados com errors.Is.
+*/
+var (
+	ErrNotFound = errors.New("record not found")      // O registro buscado não existe
+	ErrConflict = errors.New("record already exists") // O registro viola uma chave primária ou única
+
+	// ErrStaleVersion indica que outro processo atualizou o registro desde a leitura.
+	// É um caso de ErrConflict: errors.Is(err, ErrConflict) também vale para ele.
+	ErrStaleVersion error = staleVersionError{}
+)
+
+// staleVersionError é o tipo de ErrStaleVersion, que também corresponde a ErrConflict.
+type staleVersionError struct{}
+
+func (staleVersionError) Error() string {
+	return "record was modified since read"
+}
+
+func (staleVersionError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// isNotFound verifica se o erro do GORM indica que nenhum registro foi encontrado.
+func isNotFound(err error) bool {
+	return gorm.IsRecordNotFoundError(err)
+}
+
+// isConflict verifica se o erro do banco é uma violação de chave primária ou única.
+func isConflict(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505" // unique_violation
+	}
+
+	return false
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"fmt"
 	"microsservico-encoder/domain"
+	"time"
 
 	"github.com/jinzhu/gorm"
 )
@@ -11,7 +12,21 @@ import (
 type JobRepository interface {
 	Insert(job *domain.Job) (*domain.Job, error) // Insere um novo Job e retorna o Job inserido ou erro
 	Find(id string) (*domain.Job, error)         // Busca um Job pelo ID, retorna o Job ou erro
-	Update(job *domain.Job) (*domain.Job, error) // Atualiza um Job existente e retorna o Job atualizado ou erro
+	Update(job *domain.Job) (*domain.Job, error) // Atualiza um Job existente se a versão não mudou (ErrStaleVersion, um ErrConflict, caso contrário)
+
+	List(filter ListFilter) ([]*domain.Job, error)                  // Lista os Jobs que atendem ao filtro, paginados
+	FindByResourceID(resourceID string) ([]*domain.Job, error)      // Busca os Jobs dos vídeos de um recurso
+	FindByOutput(bucket string, path string) ([]*domain.Job, error) // Busca os Jobs que gravam no mesmo bucket e caminho de saída
+	CountByStatus() (map[string]int, error)                         // Conta os Jobs de cada status
+	Delete(id string) error                                         // Remove um Job, as entregas de webhook e o histórico associados
+	History(id string) ([]*domain.JobStatusChange, error)           // Lista as mudanças de status do Job, da mais antiga para a mais recente
+
+	Heartbeat(id string, at time.Time) error                            // Registra o sinal de vida do worker que processa o Job
+	FindStale(before time.Time, limit int) ([]*domain.Job, error)       // Busca os Jobs não finalizados sem sinal de vida desde before
+	UpdateStale(job *domain.Job, before time.Time) (*domain.Job, error) // Como Update, mas só se o Job continua sem sinal de vida desde before
+
+	CountActive(tenantID string) (int, error)        // Conta os Jobs não finalizados do tenant
+	Usage(filter ListFilter) ([]*TenantUsage, error) // Resume, por tenant, os Jobs criados no intervalo do filtro
 }
 
 // JobRepositoryDb é a implementação da interface JobRepository usando GORM e uma conexão ao banco
@@ -19,36 +34,408 @@ type JobRepositoryDb struct {
 	Db *gorm.DB // Conexão com o banco de dados via GORM
 }
 
-// Insert adiciona um novo registro de Job no banco
+/*
+Insert adiciona um novo registro de Job no banco, junto com o status inicial no histórico,
+retornando ErrConflict se o ID já existir
+*/
 func (repo JobRepositoryDb) Insert(job *domain.Job) (*domain.Job, error) {
-	err := repo.Db.Create(job).Error // Cria o registro no banco, verifica erro
+	err := repo.Db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(job).Error // Cria o registro no banco, verifica erro
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(domain.NewJobStatusChange(job)).Error
+	})
+
+	if isConflict(err) {
+		return nil, fmt.Errorf("job %v: %w: %v", job.ID, ErrConflict, err) // Retorna o conflito com a causa
+	}
 
 	if err != nil {
-		return nil, err // Retorna erro se falhar
+		return nil, fmt.Errorf("error inserting job %v: %w", job.ID, err) // Retorna erro se falhar
 	}
 
 	return job, nil // Retorna o Job inserido
 }
 
-// Find busca um Job pelo seu ID no banco, carregando também a referência ao Video (Preload)
+/*
+Find busca um Job pelo seu ID no banco, carregando também a referência ao Video (Preload).
+Retorna ErrNotFound se o Job não existir; falhas do banco são retornadas com a causa.
+*/
 func (repo JobRepositoryDb) Find(id string) (*domain.Job, error) {
 	var job domain.Job
-	repo.Db.Preload("Video").First(&job, "id = ?", id) // Busca o primeiro registro com o ID informado e faz preload do vídeo associado
+	err := repo.Db.Preload("Video").First(&job, "id = ?", id).Error // Busca o primeiro registro com o ID informado e faz preload do vídeo associado
+
+	if isNotFound(err) {
+		return nil, fmt.Errorf("job %v: %w", id, ErrNotFound) // Retorna ErrNotFound se não encontrar o Job
+	}
 
-	if job.ID == "" {
-		return nil, fmt.Errorf("job does not exist") // Retorna erro se não encontrar o Job
+	if err != nil {
+		return nil, fmt.Errorf("error finding job %v: %w", id, err) // Retorna a falha do banco
 	}
 
 	return &job, nil // Retorna o Job encontrado
 }
 
-// Update atualiza o registro do Job no banco
+/*
+Update atualiza o registro do Job no banco com controle de concorrência otimista:
+a gravação só ocorre se a versão no banco for igual a job.Version, que é incrementada.
+Retorna ErrStaleVersion se outro processo atualizou o Job desde a leitura e ErrNotFound se ele não existir.
+Quando o status muda, a mudança é registrada no histórico na mesma transação.
+As associações (Video) não são gravadas.
+*/
 func (repo JobRepositoryDb) Update(job *domain.Job) (*domain.Job, error) {
-	err := repo.Db.Save(&job).Error // Salva as alterações no banco e verifica erro
+	return repo.update(job, nil)
+}
+
+/*
+UpdateStale atualiza o Job como Update, mas apenas se o seu último sinal de vida ainda
+for anterior a before. Como o heartbeat não altera a versão, só a versão não basta para
+saber se o worker voltou a dar sinal de vida desde a leitura feita por FindStale.
+*/
+func (repo JobRepositoryDb) UpdateStale(job *domain.Job, before time.Time) (*domain.Job, error) {
+	return repo.update(job, &before)
+}
+
+// update grava o Job com compare-and-swap da versão e, se staleBefore for informado, do último sinal de vida.
+func (repo JobRepositoryDb) update(job *domain.Job, staleBefore *time.Time) (*domain.Job, error) {
+	columns := map[string]interface{}{}
+	for _, field := range repo.Db.NewScope(job).Fields() {
+		// heartbeat_at é gravado apenas por Heartbeat, que roda em paralelo às atualizações do worker
+		if field.IsNormal && !field.IsPrimaryKey && !field.IsIgnored && field.DBName != "heartbeat_at" {
+			columns[field.DBName] = field.Field.Interface()
+		}
+	}
+
+	updatedAt := time.Now()
+	columns["updated_at"] = updatedAt
+	columns["version"] = job.Version + 1
+
+	err := repo.Db.Transaction(func(tx *gorm.DB) error {
+		// Compare-and-swap: só atualiza se ninguém alterou o Job desde a leitura
+		query := tx.Model(&domain.Job{}).Where("id = ? AND version = ?", job.ID, job.Version)
+		if staleBefore != nil {
+			query = query.Where("COALESCE(heartbeat_at, updated_at) < ?", *staleBefore)
+		}
+
+		result := query.UpdateColumns(columns)
+
+		if result.Error != nil {
+			return fmt.Errorf("error updating job %v: %w", job.ID, result.Error) // Retorna erro caso ocorra falha na atualização
+		}
+
+		if result.RowsAffected == 0 {
+			var count int
+			err := tx.Model(&domain.Job{}).Where("id = ?", job.ID).Count(&count).Error
+			if err != nil {
+				return fmt.Errorf("error updating job %v: %w", job.ID, err)
+			}
+
+			if count == 0 {
+				return fmt.Errorf("job %v: %w", job.ID, ErrNotFound)
+			}
+
+			if staleBefore != nil {
+				return fmt.Errorf("job %v: %w: version %d is stale or the job is alive since %v", job.ID, ErrStaleVersion, job.Version, staleBefore.Format(time.RFC3339))
+			}
+
+			return fmt.Errorf("job %v: %w: version %d is stale", job.ID, ErrStaleVersion, job.Version)
+		}
+
+		err := recordStatusChange(tx, job, job.Version+1)
+		if err != nil {
+			return fmt.Errorf("error recording status of job %v: %w", job.ID, err)
+		}
+
+		return nil
+	})
 
 	if err != nil {
-		return nil, err // Retorna erro caso ocorra falha na atualização
+		return nil, err
 	}
 
+	job.Version++
+	job.UpdatedAt = updatedAt
+
 	return job, nil // Retorna o Job atualizado
 }
+
+/*
+recordStatusChange registra o status do Job no histórico, com a versão informada,
+se ele for diferente do último status registrado.
+*/
+func recordStatusChange(tx *gorm.DB, job *domain.Job, version int) error {
+	var statuses []string
+	err := tx.Model(&domain.JobStatusChange{}).Where("job_id = ?", job.ID).Order("version desc").Limit(1).Pluck("status", &statuses).Error
+
+	if err != nil {
+		return err
+	}
+
+	if len(statuses) > 0 && statuses[0] == job.Status {
+		return nil
+	}
+
+	change := domain.NewJobStatusChange(job)
+	change.Version = version
+
+	return tx.Create(change).Error
+}
+
+// History busca as mudanças de status do Job, da mais antiga para a mais recente
+func (repo JobRepositoryDb) History(id string) ([]*domain.JobStatusChange, error) {
+	var changes []*domain.JobStatusChange
+
+	err := repo.Db.Where("job_id = ?", id).Order("version asc").Find(&changes).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding history of job %v: %w", id, err)
+	}
+
+	return changes, nil
+}
+
+/*
+Heartbeat registra o sinal de vida do worker no Job, sem alterar a versão,
+para não gerar conflitos com as atualizações do próprio worker.
+*/
+func (repo JobRepositoryDb) Heartbeat(id string, at time.Time) error {
+	result := repo.Db.Model(&domain.Job{}).Where("id = ?", id).UpdateColumn("heartbeat_at", at)
+
+	if result.Error != nil {
+		return fmt.Errorf("error updating heartbeat of job %v: %w", id, result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("job %v: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+/*
+FindStale busca os Jobs não finalizados cujo último sinal de vida (o heartbeat ou,
+sem heartbeat, a última atualização) é anterior a before, do mais antigo para o mais recente.
+*/
+func (repo JobRepositoryDb) FindStale(before time.Time, limit int) ([]*domain.Job, error) {
+	var jobs []*domain.Job
+
+	err := repo.Db.Preload("Video").
+		Where("status NOT IN (?)", []string{domain.JobStatusCompleted, domain.JobStatusFailed}).
+		Where("COALESCE(heartbeat_at, updated_at) < ?", before).
+		Order("COALESCE(heartbeat_at, updated_at) asc, id asc").
+		Limit(ListFilter{Limit: limit}.limit()).
+		Find(&jobs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+/*
+List busca os Jobs que atendem ao filtro, do mais recente para o mais antigo,
+carregando também o Video de cada Job.
+*/
+func (repo JobRepositoryDb) List(filter ListFilter) ([]*domain.Job, error) {
+	var jobs []*domain.Job
+
+	query := repo.Db.Preload("Video").Select("jobs.*")
+
+	if filter.Status != "" {
+		query = query.Where("jobs.status = ?", filter.Status)
+	}
+
+	if filter.TenantID != "" {
+		query = query.Where("jobs.tenant_id = ?", filter.TenantID)
+	}
+
+	if filter.ResourceID != "" || filter.Tag != "" {
+		query = query.Joins("JOIN videos ON videos.id = jobs.video_id")
+	}
+
+	if filter.ResourceID != "" {
+		query = query.Where("videos.resource_id = ?", filter.ResourceID)
+	}
+
+	if filter.Tag != "" {
+		query = query.Where(`videos.tags LIKE ? ESCAPE '\'`, filter.tagPattern())
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("jobs.created_at >= ?", filter.CreatedFrom)
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("jobs.created_at < ?", filter.CreatedTo)
+	}
+
+	err := query.Order("jobs.created_at desc, jobs.id asc").Limit(filter.limit()).Offset(filter.offset()).Find(&jobs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// FindByResourceID busca todos os Jobs dos vídeos de um recurso, do mais recente para o mais antigo
+func (repo JobRepositoryDb) FindByResourceID(resourceID string) ([]*domain.Job, error) {
+	var jobs []*domain.Job
+
+	err := repo.Db.Preload("Video").Select("jobs.*").
+		Joins("JOIN videos ON videos.id = jobs.video_id").
+		Where("videos.resource_id = ?", resourceID).
+		Order("jobs.created_at desc, jobs.id asc").
+		Find(&jobs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+/*
+FindByOutput busca os Jobs com o bucket e o caminho de saída informados. Templates de
+caminho como {video_id} fazem vários Jobs gravarem no mesmo prefixo.
+*/
+func (repo JobRepositoryDb) FindByOutput(bucket string, path string) ([]*domain.Job, error) {
+	var jobs []*domain.Job
+
+	err := repo.Db.Where("output_bucket = ? AND output_bucket_path = ?", bucket, path).
+		Order("created_at desc, id asc").
+		Find(&jobs).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// CountByStatus retorna a quantidade de Jobs de cada status
+func (repo JobRepositoryDb) CountByStatus() (map[string]int, error) {
+	rows, err := repo.Db.Model(&domain.Job{}).Select("status, count(*)").Group("status").Rows()
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+
+		err = rows.Scan(&status, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// CountActive retorna a quantidade de Jobs não finalizados do tenant
+func (repo JobRepositoryDb) CountActive(tenantID string) (int, error) {
+	var count int
+
+	err := repo.Db.Model(&domain.Job{}).
+		Where("tenant_id = ? AND status NOT IN (?)", tenantID, []string{domain.JobStatusCompleted, domain.JobStatusFailed}).
+		Count(&count).Error
+
+	if err != nil {
+		return 0, fmt.Errorf("error counting active jobs of tenant %v: %w", tenantID, err)
+	}
+
+	return count, nil
+}
+
+/*
+Usage resume os Jobs criados no intervalo do filtro, agrupados por tenant e ordenados
+pelo ID do tenant. Apenas TenantID e o intervalo de criação são considerados.
+*/
+func (repo JobRepositoryDb) Usage(filter ListFilter) ([]*TenantUsage, error) {
+	query := repo.Db.Model(&domain.Job{}).Select(
+		"tenant_id, count(*), "+
+			"sum(CASE WHEN status = ? THEN 1 ELSE 0 END), "+
+			"sum(CASE WHEN status = ? THEN 1 ELSE 0 END), "+
+			"coalesce(sum(duration_seconds), 0)",
+		domain.JobStatusCompleted, domain.JobStatusFailed)
+
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedTo)
+	}
+
+	rows, err := query.Group("tenant_id").Order("tenant_id asc").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usages := []*TenantUsage{}
+	for rows.Next() {
+		var usage TenantUsage
+
+		err = rows.Scan(&usage.TenantID, &usage.Jobs, &usage.Completed, &usage.Failed, &usage.Seconds)
+		if err != nil {
+			return nil, err
+		}
+
+		usages = append(usages, &usage)
+	}
+
+	return usages, rows.Err()
+}
+
+/*
+Delete remove o Job e, na mesma transação, as entregas de webhook e o histórico associados.
+A remoção é feita explicitamente para não depender das foreign keys do banco
+(o SQLite só as aplica com PRAGMA foreign_keys).
+*/
+func (repo JobRepositoryDb) Delete(id string) error {
+	return repo.Db.Transaction(func(tx *gorm.DB) error {
+		return deleteJobs(tx, []string{id})
+	})
+}
+
+// deleteJobs remove os Jobs informados, as entregas de webhook e o histórico associados.
+func deleteJobs(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	err := tx.Where("job_id IN (?)", ids).Delete(&domain.WebhookDelivery{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Where("job_id IN (?)", ids).Delete(&domain.JobStatusChange{}).Error
+	if err != nil {
+		return err
+	}
+
+	result := tx.Where("id IN (?)", ids).Delete(&domain.Job{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("job %v: %w", ids[0], ErrNotFound)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package repositories_test
 
 import (
+	"errors"
 	"microsservico-encoder/application/repositories"
 	"microsservico-encoder/domain"
 	"microsservico-encoder/framework/database"
@@ -72,3 +73,23 @@ func TestJobRepositoryDbUpdate(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, j.Status, job.Status)
 }
+
+/*
+TestJobRepositoryDbFindDatabaseError testa que uma falha do banco não é
+confundida com um Job inexistente
+*/
+func TestJobRepositoryDbFindDatabaseError(t *testing.T) {
+	db := database.NewDbTest()
+	db.Close()
+
+	repoJob := repositories.JobRepositoryDb{Db: db}
+
+	_, err := repoJob.Find(uuid.NewV4().String())
+	require.Error(t, err)
+	require.False(t, errors.Is(err, repositories.ErrNotFound))
+	require.Contains(t, err.Error(), "database is closed")
+
+	_, err = repositories.VideoRepositoryDb{Db: db}.Find(uuid.NewV4().String())
+	require.Error(t, err)
+	require.False(t, errors.Is(err, repositories.ErrNotFound))
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultListLimit é o tamanho de página usado quando ListFilter.Limit não é informado.
+const DefaultListLimit = 100
+
+/*
+ListFilter define os filtros e a paginação das listagens de jobs e vídeos.
+Campos vazios não filtram. O intervalo de criação inclui CreatedFrom e exclui CreatedTo.
+Os resultados são ordenados do mais recente para o mais antigo.
+*/
+type ListFilter struct {
+	Status      string    // Status do job (para vídeos: possui algum job com o status)
+	ResourceID  string    // Identificador do recurso de origem do vídeo
+	Tag         string    // Tag do vídeo (para jobs: tag do vídeo do job)
+	TenantID    string    // Tenant dono do job ou do vídeo
+	CreatedFrom time.Time // Criados a partir desta data
+	CreatedTo   time.Time // Criados antes desta data
+	Limit       int       // Quantidade máxima de resultados (padrão: DefaultListLimit)
+	Offset      int       // Quantidade de resultados ignorados, para paginação
+}
+
+// limit retorna o tamanho da página, aplicando o padrão.
+func (f ListFilter) limit() int {
+	if f.Limit <= 0 {
+		return DefaultListLimit
+	}
+
+	return f.Limit
+}
+
+// offset retorna a quantidade de resultados ignorados, nunca negativa.
+func (f ListFilter) offset() int {
+	if f.Offset < 0 {
+		return 0
+	}
+
+	return f.Offset
+}
+
+// likeEscaper escapa os curingas do LIKE (% e _) e o próprio caractere de escape.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+/*
+tagPattern retorna o padrão LIKE que seleciona os vídeos com a tag do filtro. A tag é
+escapada (ex.: o "_" de keep_me não casa com keep-me), e a consulta deve usar ESCAPE '\'.
+*/
+func (f ListFilter) tagPattern() string {
+	return "%," + likeEscaper.Replace(f.Tag) + ",%"
+}
+
+// createdIn verifica se a data de criação está no intervalo do filtro.
+func (f ListFilter) createdIn(createdAt time.Time) bool {
+	if !f.CreatedFrom.IsZero() && createdAt.Before(f.CreatedFrom) {
+		return false
+	}
+
+	if !f.CreatedTo.IsZero() && !createdAt.Before(f.CreatedTo) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/*
+LockRepository define locks nomeados com prazo de expiração, usados para que apenas
+uma instância do encoder execute uma tarefa periódica (ex.: o reaper de jobs).
+Um lock expirado pode ser adquirido por outra instância, então o dono deve
+renová-lo (adquirindo-o novamente) antes do prazo.
+*/
+type LockRepository interface {
+	Acquire(name string, owner string, ttl time.Duration) (bool, error) // Adquire ou renova o lock; retorna false se outra instância o detém
+	Release(name string, owner string) error                            // Libera o lock, se pertencer ao dono informado
+}
+
+// LockRepositoryDb é a implementação de LockRepository usando a tabela locks
+type LockRepositoryDb struct {
+	Db *gorm.DB // Conexão com o banco de dados via GORM
+}
+
+/*
+Acquire adquire o lock se ele estiver livre, expirado ou já pertencer ao dono,
+definindo a expiração para agora + ttl.
+A atualização condicional e a chave primária garantem que apenas uma instância o obtenha.
+*/
+func (repo LockRepositoryDb) Acquire(name string, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+
+	result := repo.Db.Exec("UPDATE locks SET owner = ?, expires_at = ? WHERE name = ? AND (owner = ? OR expires_at < ?)",
+		owner, expiresAt, name, owner, now)
+
+	if result.Error != nil {
+		return false, fmt.Errorf("error acquiring lock %v: %w", name, result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	err := repo.Db.Exec("INSERT INTO locks (name, owner, expires_at) VALUES (?, ?, ?)", name, owner, expiresAt).Error
+
+	if isConflict(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("error acquiring lock %v: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// Release libera o lock, se ele pertencer ao dono informado
+func (repo LockRepositoryDb) Release(name string, owner string) error {
+	err := repo.Db.Exec("DELETE FROM locks WHERE name = ? AND owner = ?", name, owner).Error
+
+	if err != nil {
+		return fmt.Errorf("error releasing lock %v: %w", name, err)
+	}
+
+	return nil
+}
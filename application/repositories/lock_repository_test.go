@@ -0,0 +1,49 @@
+package repositories_test
+
+import (
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/framework/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestLockRepositoryDb testa que apenas um dono detém o lock até que ele seja
+liberado ou expire
+*/
+func TestLockRepositoryDb(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	locks := repositories.LockRepositoryDb{Db: db}
+
+	acquired, err := locks.Acquire("reaper", "a", time.Minute)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	acquired, err = locks.Acquire("reaper", "b", time.Minute)
+	require.Nil(t, err)
+	require.False(t, acquired)
+
+	// O dono pode renovar o lock.
+	acquired, err = locks.Acquire("reaper", "a", time.Minute)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	require.Nil(t, locks.Release("reaper", "b"))
+	acquired, err = locks.Acquire("reaper", "b", time.Minute)
+	require.Nil(t, err)
+	require.False(t, acquired)
+
+	require.Nil(t, locks.Release("reaper", "a"))
+	acquired, err = locks.Acquire("reaper", "b", -time.Second)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	// Um lock expirado pode ser adquirido por outro dono.
+	acquired, err = locks.Acquire("reaper", "a", time.Minute)
+	require.Nil(t, err)
+	require.True(t, acquired)
+}
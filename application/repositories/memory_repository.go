@@ -0,0 +1,584 @@
+package repositories
+
+import (
+	"fmt"
+	"microsservico-encoder/domain"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+/*
+MemoryStore mantém vídeos e jobs em memória, compartilhados pelos repositórios
+VideoRepositoryMemory e JobRepositoryMemory para que as buscas por recurso e as
+remoções em cascata funcionem entre eles.
+É utilizado em testes e ferramentas que não precisam de um banco real.
+*/
+type MemoryStore struct {
+	mu      sync.Mutex
+	videos  map[string]domain.Video
+	jobs    map[string]domain.Job
+	history map[string][]domain.JobStatusChange
+}
+
+// NewMemoryStore cria um MemoryStore vazio.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		videos:  map[string]domain.Video{},
+		jobs:    map[string]domain.Job{},
+		history: map[string][]domain.JobStatusChange{},
+	}
+}
+
+// VideoRepositoryMemory é a implementação de VideoRepository em memória.
+type VideoRepositoryMemory struct {
+	Store *MemoryStore
+}
+
+// JobRepositoryMemory é a implementação de JobRepository em memória.
+type JobRepositoryMemory struct {
+	Store *MemoryStore
+}
+
+// Insert armazena uma cópia do vídeo, gerando o ID e a data de criação quando vazios.
+func (repo VideoRepositoryMemory) Insert(video *domain.Video) (*domain.Video, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if video.ID == "" {
+		video.ID = uuid.NewV4().String()
+	}
+
+	if _, ok := s.videos[video.ID]; ok {
+		return nil, fmt.Errorf("video %v: %w", video.ID, ErrConflict)
+	}
+
+	if video.CreatedAt.IsZero() {
+		video.CreatedAt = time.Now()
+	}
+
+	stored := *video
+	stored.Jobs = nil
+	s.videos[video.ID] = stored
+
+	return video, nil
+}
+
+// Find busca um vídeo pelo ID, com os jobs associados.
+func (repo VideoRepositoryMemory) Find(id string) (*domain.Video, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.videos[id]; !ok {
+		return nil, fmt.Errorf("video %v: %w", id, ErrNotFound)
+	}
+
+	return s.video(id), nil
+}
+
+// Update substitui os dados do vídeo, sem alterar os jobs associados.
+func (repo VideoRepositoryMemory) Update(video *domain.Video) (*domain.Video, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *video
+	stored.Jobs = nil
+	s.videos[video.ID] = stored
+
+	return video, nil
+}
+
+// List lista os vídeos que atendem ao filtro, com as mesmas regras de VideoRepositoryDb.List.
+func (repo VideoRepositoryMemory) List(filter ListFilter) ([]*domain.Video, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	videos := []*domain.Video{}
+	for id, video := range s.videos {
+		if filter.ResourceID != "" && video.ResourceID != filter.ResourceID {
+			continue
+		}
+
+		if filter.TenantID != "" && video.TenantID != filter.TenantID {
+			continue
+		}
+
+		if filter.Tag != "" && !video.HasTag(filter.Tag) {
+			continue
+		}
+
+		if !filter.createdIn(video.CreatedAt) {
+			continue
+		}
+
+		if filter.Status != "" && !s.hasJobWithStatus(id, filter.Status) {
+			continue
+		}
+
+		videos = append(videos, s.video(id))
+	}
+
+	sortVideos(videos)
+
+	return paginate(videos, filter), nil
+}
+
+// FindByResourceID busca os vídeos de um recurso, do mais recente para o mais antigo.
+func (repo VideoRepositoryMemory) FindByResourceID(resourceID string) ([]*domain.Video, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	videos := []*domain.Video{}
+	for id, video := range s.videos {
+		if video.ResourceID == resourceID {
+			videos = append(videos, s.video(id))
+		}
+	}
+
+	sortVideos(videos)
+
+	return videos, nil
+}
+
+// CountByStatus conta, para cada status, os vídeos que possuem algum job nesse status.
+func (repo VideoRepositoryMemory) CountByStatus() (map[string]int, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	videos := map[string]map[string]bool{}
+	for _, job := range s.jobs {
+		if videos[job.Status] == nil {
+			videos[job.Status] = map[string]bool{}
+		}
+		videos[job.Status][job.VideoID] = true
+	}
+
+	counts := map[string]int{}
+	for status, ids := range videos {
+		counts[status] = len(ids)
+	}
+
+	return counts, nil
+}
+
+// Delete remove o vídeo, os jobs associados e o histórico desses jobs.
+func (repo VideoRepositoryMemory) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.videos[id]; !ok {
+		return fmt.Errorf("video %v: %w", id, ErrNotFound)
+	}
+
+	for jobID, job := range s.jobs {
+		if job.VideoID == id {
+			delete(s.jobs, jobID)
+			delete(s.history, jobID)
+		}
+	}
+
+	delete(s.videos, id)
+
+	return nil
+}
+
+// Insert armazena uma cópia do job, associando-o ao vídeo informado em job.Video, e registra o status inicial.
+func (repo JobRepositoryMemory) Insert(job *domain.Job) (*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[job.ID]; ok {
+		return nil, fmt.Errorf("job %v: %w", job.ID, ErrConflict)
+	}
+
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	if job.UpdatedAt.IsZero() {
+		job.UpdatedAt = now
+	}
+
+	s.putJob(job)
+	s.history[job.ID] = []domain.JobStatusChange{*domain.NewJobStatusChange(job)}
+
+	return job, nil
+}
+
+// Find busca um job pelo ID, com o vídeo associado.
+func (repo JobRepositoryMemory) Find(id string) (*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return nil, fmt.Errorf("job %v: %w", id, ErrNotFound)
+	}
+
+	return s.job(id), nil
+}
+
+/*
+Update substitui os dados do job se a versão armazenada for igual a job.Version,
+com as mesmas regras de JobRepositoryDb.Update.
+*/
+func (repo JobRepositoryMemory) Update(job *domain.Job) (*domain.Job, error) {
+	return repo.update(job, nil)
+}
+
+// UpdateStale atualiza o job se ele continua sem sinal de vida desde before, com as mesmas regras de JobRepositoryDb.UpdateStale.
+func (repo JobRepositoryMemory) UpdateStale(job *domain.Job, before time.Time) (*domain.Job, error) {
+	return repo.update(job, &before)
+}
+
+func (repo JobRepositoryMemory) update(job *domain.Job, staleBefore *time.Time) (*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.jobs[job.ID]
+	if !ok {
+		return nil, fmt.Errorf("job %v: %w", job.ID, ErrNotFound)
+	}
+
+	if stored.Version != job.Version {
+		return nil, fmt.Errorf("job %v: %w: version %d is stale", job.ID, ErrStaleVersion, job.Version)
+	}
+
+	if staleBefore != nil && !lastSeen(&stored).Before(*staleBefore) {
+		return nil, fmt.Errorf("job %v: %w: alive since %v", job.ID, ErrStaleVersion, staleBefore.Format(time.RFC3339))
+	}
+
+	job.Version++
+	job.UpdatedAt = time.Now()
+	job.HeartbeatAt = stored.HeartbeatAt
+	s.putJob(job)
+
+	if history := s.history[job.ID]; len(history) == 0 || history[len(history)-1].Status != job.Status {
+		s.history[job.ID] = append(history, *domain.NewJobStatusChange(job))
+	}
+
+	return job, nil
+}
+
+// List lista os jobs que atendem ao filtro, com as mesmas regras de JobRepositoryDb.List.
+func (repo JobRepositoryMemory) List(filter ListFilter) ([]*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []*domain.Job{}
+	for id, job := range s.jobs {
+		if filter.Status != "" && job.Status != filter.Status {
+			continue
+		}
+
+		if filter.TenantID != "" && job.TenantID != filter.TenantID {
+			continue
+		}
+
+		if filter.ResourceID != "" && s.videos[job.VideoID].ResourceID != filter.ResourceID {
+			continue
+		}
+
+		if video := s.videos[job.VideoID]; filter.Tag != "" && !video.HasTag(filter.Tag) {
+			continue
+		}
+
+		if !filter.createdIn(job.CreatedAt) {
+			continue
+		}
+
+		jobs = append(jobs, s.job(id))
+	}
+
+	sortJobs(jobs)
+
+	return paginate(jobs, filter), nil
+}
+
+// FindByResourceID busca os jobs dos vídeos de um recurso, do mais recente para o mais antigo.
+func (repo JobRepositoryMemory) FindByResourceID(resourceID string) ([]*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []*domain.Job{}
+	for id, job := range s.jobs {
+		if video, ok := s.videos[job.VideoID]; ok && video.ResourceID == resourceID {
+			jobs = append(jobs, s.job(id))
+		}
+	}
+
+	sortJobs(jobs)
+
+	return jobs, nil
+}
+
+// FindByOutput busca os jobs com o bucket e o caminho de saída informados.
+func (repo JobRepositoryMemory) FindByOutput(bucket string, path string) ([]*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []*domain.Job{}
+	for id, job := range s.jobs {
+		if job.OutputBucket == bucket && job.OutputBucketPath == path {
+			jobs = append(jobs, s.job(id))
+		}
+	}
+
+	sortJobs(jobs)
+
+	return jobs, nil
+}
+
+// CountByStatus retorna a quantidade de jobs de cada status.
+func (repo JobRepositoryMemory) CountByStatus() (map[string]int, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, job := range s.jobs {
+		counts[job.Status]++
+	}
+
+	return counts, nil
+}
+
+// Delete remove o job e o seu histórico.
+func (repo JobRepositoryMemory) Delete(id string) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return fmt.Errorf("job %v: %w", id, ErrNotFound)
+	}
+
+	delete(s.jobs, id)
+	delete(s.history, id)
+
+	return nil
+}
+
+// History retorna as mudanças de status do job, da mais antiga para a mais recente.
+func (repo JobRepositoryMemory) History(id string) ([]*domain.JobStatusChange, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := []*domain.JobStatusChange{}
+	for _, change := range s.history[id] {
+		change := change
+		changes = append(changes, &change)
+	}
+
+	return changes, nil
+}
+
+// Heartbeat registra o sinal de vida do worker no job, sem alterar a versão.
+func (repo JobRepositoryMemory) Heartbeat(id string, at time.Time) error {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %v: %w", id, ErrNotFound)
+	}
+
+	job.HeartbeatAt = &at
+	s.jobs[id] = job
+
+	return nil
+}
+
+// FindStale busca os jobs não finalizados sem sinal de vida desde before, com as mesmas regras de JobRepositoryDb.FindStale.
+func (repo JobRepositoryMemory) FindStale(before time.Time, limit int) ([]*domain.Job, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []*domain.Job{}
+	for id, job := range s.jobs {
+		if !job.Finished() && lastSeen(&job).Before(before) {
+			jobs = append(jobs, s.job(id))
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if !lastSeen(jobs[i]).Equal(lastSeen(jobs[j])) {
+			return lastSeen(jobs[i]).Before(lastSeen(jobs[j]))
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+
+	return paginate(jobs, ListFilter{Limit: limit}), nil
+}
+
+// CountActive retorna a quantidade de jobs não finalizados do tenant.
+func (repo JobRepositoryMemory) CountActive(tenantID string) (int, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, job := range s.jobs {
+		if job.TenantID == tenantID && !job.Finished() {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Usage resume os jobs por tenant, com as mesmas regras de JobRepositoryDb.Usage.
+func (repo JobRepositoryMemory) Usage(filter ListFilter) ([]*TenantUsage, error) {
+	s := repo.Store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenants := map[string]*TenantUsage{}
+	for _, job := range s.jobs {
+		if filter.TenantID != "" && job.TenantID != filter.TenantID {
+			continue
+		}
+
+		if !filter.createdIn(job.CreatedAt) {
+			continue
+		}
+
+		usage, ok := tenants[job.TenantID]
+		if !ok {
+			usage = &TenantUsage{TenantID: job.TenantID}
+			tenants[job.TenantID] = usage
+		}
+
+		usage.Jobs++
+		usage.Seconds += job.DurationSeconds
+
+		switch job.Status {
+		case domain.JobStatusCompleted:
+			usage.Completed++
+		case domain.JobStatusFailed:
+			usage.Failed++
+		}
+	}
+
+	usages := []*TenantUsage{}
+	for _, usage := range tenants {
+		usages = append(usages, usage)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].TenantID < usages[j].TenantID
+	})
+
+	return usages, nil
+}
+
+// lastSeen retorna o último sinal de vida do job: o heartbeat ou, sem heartbeat, a última atualização.
+func lastSeen(job *domain.Job) time.Time {
+	if job.HeartbeatAt != nil {
+		return *job.HeartbeatAt
+	}
+
+	return job.UpdatedAt
+}
+
+// putJob armazena uma cópia do job sem o vídeo, preenchendo VideoID a partir de job.Video.
+func (s *MemoryStore) putJob(job *domain.Job) {
+	if job.Video != nil && job.VideoID == "" {
+		job.VideoID = job.Video.ID
+	}
+
+	stored := *job
+	stored.Video = nil
+	s.jobs[job.ID] = stored
+}
+
+// video retorna uma cópia do vídeo com cópias dos seus jobs, como o Preload("Jobs").
+func (s *MemoryStore) video(id string) *domain.Video {
+	video := s.videos[id]
+	video.Jobs = []*domain.Job{}
+
+	for _, job := range s.jobs {
+		if job.VideoID == id {
+			job := job
+			video.Jobs = append(video.Jobs, &job)
+		}
+	}
+
+	sortJobs(video.Jobs)
+
+	return &video
+}
+
+// job retorna uma cópia do job com uma cópia do vídeo, como o Preload("Video").
+func (s *MemoryStore) job(id string) *domain.Job {
+	job := s.jobs[id]
+
+	if video, ok := s.videos[job.VideoID]; ok {
+		job.Video = &video
+	}
+
+	return &job
+}
+
+// hasJobWithStatus verifica se o vídeo possui algum job com o status informado.
+func (s *MemoryStore) hasJobWithStatus(videoID string, status string) bool {
+	for _, job := range s.jobs {
+		if job.VideoID == videoID && job.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortVideos ordena os vídeos do mais recente para o mais antigo, desempatando pelo ID.
+func sortVideos(videos []*domain.Video) {
+	sort.Slice(videos, func(i, j int) bool {
+		if !videos[i].CreatedAt.Equal(videos[j].CreatedAt) {
+			return videos[i].CreatedAt.After(videos[j].CreatedAt)
+		}
+		return videos[i].ID < videos[j].ID
+	})
+}
+
+// sortJobs ordena os jobs do mais recente para o mais antigo, desempatando pelo ID.
+func sortJobs(jobs []*domain.Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+}
+
+// paginate aplica o deslocamento e o limite do filtro.
+func paginate[T any](items []T, filter ListFilter) []T {
+	offset := filter.offset()
+	if offset >= len(items) {
+		return items[:0]
+	}
+
+	items = items[offset:]
+	if len(items) > filter.limit() {
+		items = items[:filter.limit()]
+	}
+
+	return items
+}
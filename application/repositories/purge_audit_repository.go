@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"microsservico-encoder/domain"
+
+	"github.com/jinzhu/gorm"
+)
+
+// PurgeAuditRepository define os métodos para registrar as ações das regras de retenção
+type PurgeAuditRepository interface {
+	Insert(audit *domain.PurgeAudit) (*domain.PurgeAudit, error) // Registra uma ação de retenção
+	FindByRule(rule string) ([]*domain.PurgeAudit, error)        // Lista as ações de uma regra, da mais antiga para a mais recente
+}
+
+// PurgeAuditRepositoryDb é a implementação de PurgeAuditRepository usando GORM
+type PurgeAuditRepositoryDb struct {
+	Db *gorm.DB // Conexão com o banco de dados via GORM
+}
+
+// Insert adiciona o registro de uma ação de retenção no banco
+func (repo PurgeAuditRepositoryDb) Insert(audit *domain.PurgeAudit) (*domain.PurgeAudit, error) {
+	err := repo.Db.Create(audit).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return audit, nil
+}
+
+// FindByRule busca todas as ações de uma regra, ordenadas pela data de criação
+func (repo PurgeAuditRepositoryDb) FindByRule(rule string) ([]*domain.PurgeAudit, error) {
+	var audits []*domain.PurgeAudit
+
+	err := repo.Db.Where("rule = ?", rule).Order("created_at asc, id asc").Find(&audits).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return audits, nil
+}
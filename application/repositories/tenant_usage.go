@@ -0,0 +1,20 @@
+package repositories
+
+// TenantUsage resume os jobs de um tenant em um período, para os relatórios de uso.
+type TenantUsage struct {
+	TenantID  string
+	Jobs      int     // Jobs criados no período
+	Completed int     // Jobs concluídos
+	Failed    int     // Jobs com falha
+	Seconds   float64 // Soma da duração das saídas, em segundos
+}
+
+// Active retorna a quantidade de jobs ainda não finalizados.
+func (u *TenantUsage) Active() int {
+	return u.Jobs - u.Completed - u.Failed
+}
+
+// Minutes retorna a duração processada, em minutos.
+func (u *TenantUsage) Minutes() float64 {
+	return u.Seconds / 60
+}
@@ -15,6 +15,12 @@ Abstrai as operações de persistência de um vídeo.
 type VideoRepository interface {
 	Insert(video *domain.Video) (*domain.Video, error) // Insere um novo vídeo no banco
 	Find(id string) (*domain.Video, error)             // Busca um vídeo por ID
+	Update(video *domain.Video) (*domain.Video, error) // Atualiza os dados de um vídeo existente
+
+	List(filter ListFilter) ([]*domain.Video, error)             // Lista os vídeos que atendem ao filtro, paginados
+	FindByResourceID(resourceID string) ([]*domain.Video, error) // Busca os vídeos de um recurso
+	CountByStatus() (map[string]int, error)                      // Conta os vídeos com jobs em cada status
+	Delete(id string) error                                      // Remove um vídeo, seus jobs e as entregas de webhook
 }
 
 // Estrutura concreta que implementa VideoRepository usando o GORM como ORM.
@@ -30,7 +36,7 @@ func NewVideoRepository(db *gorm.DB) *VideoRepositoryDb {
 /*
 Método que insere um novo vídeo no banco de dados.
 Se o ID do vídeo estiver vazio, gera um UUID novo.
-Retorna o vídeo inserido ou um erro, se ocorrer.
+Retorna o vídeo inserido ou um erro, se ocorrer (ErrConflict se o ID já existir).
 */
 func (repo VideoRepositoryDb) Insert(video *domain.Video) (*domain.Video, error) {
 
@@ -40,8 +46,12 @@ func (repo VideoRepositoryDb) Insert(video *domain.Video) (*domain.Video, error)
 
 	err := repo.Db.Create(video).Error
 
+	if isConflict(err) {
+		return nil, fmt.Errorf("video %v: %w: %v", video.ID, ErrConflict, err)
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error inserting video %v: %w", video.ID, err)
 	}
 
 	return video, nil
@@ -50,16 +60,157 @@ func (repo VideoRepositoryDb) Insert(video *domain.Video) (*domain.Video, error)
 /*
 Método que busca um vídeo no banco de dados com base no ID.
 Usa Preload para carregar os jobs associados ao vídeo.
-Retorna um erro se o vídeo não for encontrado.
+Retorna ErrNotFound se o vídeo não for encontrado; falhas do banco são retornadas com a causa.
 */
 func (repo VideoRepositoryDb) Find(id string) (*domain.Video, error) {
 
 	var video domain.Video
-	repo.Db.Preload("Jobs").First(&video, "id = ?", id)
+	err := repo.Db.Preload("Jobs").First(&video, "id = ?", id).Error
 
-	if video.ID == "" {
-		return nil, fmt.Errorf("video does not exist")
+	if isNotFound(err) {
+		return nil, fmt.Errorf("video %v: %w", id, ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding video %v: %w", id, err)
 	}
 
 	return &video, nil
 }
+
+/*
+Método que atualiza os dados de um vídeo existente no banco de dados,
+sem alterar os jobs associados.
+Retorna o vídeo atualizado ou um erro, se ocorrer.
+*/
+func (repo VideoRepositoryDb) Update(video *domain.Video) (*domain.Video, error) {
+
+	err := repo.Db.Set("gorm:save_associations", false).Save(video).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return video, nil
+}
+
+/*
+Método que lista os vídeos que atendem ao filtro, do mais recente para o mais antigo,
+carregando os jobs associados.
+O filtro de status seleciona os vídeos que possuem algum job com o status informado.
+*/
+func (repo VideoRepositoryDb) List(filter ListFilter) ([]*domain.Video, error) {
+
+	var videos []*domain.Video
+	query := repo.Db.Preload("Jobs")
+
+	if filter.Status != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM jobs WHERE jobs.video_id = videos.id AND jobs.status = ?)", filter.Status)
+	}
+
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+
+	if filter.Tag != "" {
+		query = query.Where(`tags LIKE ? ESCAPE '\'`, filter.tagPattern())
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedTo)
+	}
+
+	err := query.Order("created_at desc, id asc").Limit(filter.limit()).Offset(filter.offset()).Find(&videos).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+/*
+Método que busca os vídeos de um recurso, do mais recente para o mais antigo.
+Um mesmo recurso pode ter sido enviado para encoding mais de uma vez.
+*/
+func (repo VideoRepositoryDb) FindByResourceID(resourceID string) ([]*domain.Video, error) {
+
+	var videos []*domain.Video
+	err := repo.Db.Preload("Jobs").Where("resource_id = ?", resourceID).Order("created_at desc, id asc").Find(&videos).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return videos, nil
+}
+
+/*
+Método que conta, para cada status, os vídeos que possuem algum job nesse status.
+Um vídeo com jobs em status diferentes é contado em cada um deles.
+*/
+func (repo VideoRepositoryDb) CountByStatus() (map[string]int, error) {
+
+	rows, err := repo.Db.Model(&domain.Job{}).Select("status, count(DISTINCT video_id)").Group("status").Rows()
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+
+		err = rows.Scan(&status, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
+
+/*
+Método que remove um vídeo e, na mesma transação, seus jobs e as entregas de
+webhook desses jobs.
+*/
+func (repo VideoRepositoryDb) Delete(id string) error {
+
+	return repo.Db.Transaction(func(tx *gorm.DB) error {
+		var jobIDs []string
+		err := tx.Model(&domain.Job{}).Where("video_id = ?", id).Pluck("id", &jobIDs).Error
+		if err != nil {
+			return err
+		}
+
+		if len(jobIDs) > 0 {
+			err = deleteJobs(tx, jobIDs)
+			if err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id = ?", id).Delete(&domain.Video{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("video %v: %w", id, ErrNotFound)
+		}
+
+		return nil
+	})
+}
@@ -33,3 +33,39 @@ func TestVideoRepositoryDbInsert(t *testing.T) {
 	require.Nil(t, err)              // Verifica se não houve erro ao buscar o vídeo
 	require.Equal(t, v.ID, video.ID) // Compara o ID do vídeo inserido com o recuperado
 }
+
+/*
+TestVideoRepositoryDbDeleteCascade testa que a remoção de um vídeo remove
+também seus jobs e as entregas de webhook desses jobs
+*/
+func TestVideoRepositoryDbDeleteCascade(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.FilePath = "path"
+	video.CreatedAt = time.Now()
+
+	repo := repositories.VideoRepositoryDb{Db: db}
+	repo.Insert(video)
+
+	job, err := domain.NewJob("output_path", "Pending", video)
+	require.Nil(t, err)
+
+	repoJob := repositories.JobRepositoryDb{Db: db}
+	repoJob.Insert(job)
+
+	repoDelivery := repositories.WebhookDeliveryRepositoryDb{Db: db}
+	_, err = repoDelivery.Insert(domain.NewWebhookDelivery(job, domain.NewJobEvent(domain.JobCompleted, job), 1))
+	require.Nil(t, err)
+
+	require.Nil(t, repo.Delete(video.ID))
+
+	deliveries, err := repoDelivery.FindByJob(job.ID)
+	require.Nil(t, err)
+	require.Empty(t, deliveries)
+
+	_, err = repoJob.Find(job.ID)
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"microsservico-encoder/domain"
+
+	"github.com/jinzhu/gorm"
+)
+
+// WebhookDeliveryRepository define os métodos para registrar as tentativas de entrega de webhooks
+type WebhookDeliveryRepository interface {
+	Insert(delivery *domain.WebhookDelivery) (*domain.WebhookDelivery, error) // Registra uma tentativa de entrega
+	FindByJob(jobID string) ([]*domain.WebhookDelivery, error)                // Lista as tentativas de um job, da mais antiga para a mais recente
+}
+
+// WebhookDeliveryRepositoryDb é a implementação de WebhookDeliveryRepository usando GORM
+type WebhookDeliveryRepositoryDb struct {
+	Db *gorm.DB // Conexão com o banco de dados via GORM
+}
+
+// Insert adiciona o registro de uma tentativa de entrega no banco
+func (repo WebhookDeliveryRepositoryDb) Insert(delivery *domain.WebhookDelivery) (*domain.WebhookDelivery, error) {
+	err := repo.Db.Create(delivery).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// FindByJob busca todas as tentativas de entrega de um job, ordenadas pela data de criação
+func (repo WebhookDeliveryRepositoryDb) FindByJob(jobID string) ([]*domain.WebhookDelivery, error) {
+	var deliveries []*domain.WebhookDelivery
+
+	err := repo.Db.Where("job_id = ?", jobID).Order("created_at asc, attempt asc").Find(&deliveries).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"strings"
+)
+
+/*
+AudioTrack representa uma faixa de áudio alternativa (ex.: dublagem) entregue em um
+arquivo separado. FilePath segue as mesmas regras do arquivo de vídeo; Label é o nome
+exibido pelos players (ex.: "Português"). Cada faixa é empacotada em um adaptation
+set próprio, com o atributo lang definido por Language.
+*/
+type AudioTrack struct {
+	FilePath   string `json:"file_path"`
+	Language   string `json:"language"`
+	Label      string `json:"label,omitempty"`
+	SourcePath string `json:"-"` // Arquivo baixado
+	MediaPath  string `json:"-"` // Arquivo AAC/MP4 gerado em Convert
+}
+
+/*
+Validate verifica se a faixa informa o arquivo e um código de idioma válido.
+*/
+func (a *AudioTrack) Validate() error {
+	if a.FilePath == "" {
+		return fmt.Errorf("audio track file_path is required")
+	}
+
+	if !languageCode.MatchString(a.Language) {
+		return fmt.Errorf("invalid audio track language: %q", a.Language)
+	}
+
+	// O label é repassado ao mp4dash dentro de [+opção=valor,...].
+	if strings.ContainsAny(a.Label, "[],=") {
+		return fmt.Errorf("invalid audio track label: %q", a.Label)
+	}
+
+	return nil
+}
+
+/*
+DownloadAudioTracks baixa as faixas de áudio alternativas do job.
+Arquivos que não são áudio (ou vídeo com áudio) são recusados.
+*/
+func (v *VideoService) DownloadAudioTracks(bucketName string) error {
+
+	for i := range v.AudioTracks {
+		track := &v.AudioTracks[i]
+
+		path, err := v.fetch(track.FilePath, bucketName, func(header []byte) (string, error) {
+			container := utils.DetectAudioContainer(header)
+			if container == "" {
+				return "", fmt.Errorf("unsupported audio content type: %v", http.DetectContentType(header))
+			}
+
+			return v.localPath(fmt.Sprintf(".audio%d.source.%v", i, container)), nil
+		})
+		if err != nil {
+			return fmt.Errorf("error downloading audio track %v: %v", track.FilePath, err)
+		}
+
+		track.SourcePath = path
+
+		log.Printf("audio track %v (%v) of video %v has been stored", track.FilePath, track.Language, v.Video.ID)
+	}
+
+	return nil
+}
+
+/*
+convertAudioTracks extrai o primeiro áudio de cada faixa alternativa para um MP4
+com AAC, o formato aceito pelo mp4fragment.
+*/
+func (v *VideoService) convertAudioTracks() error {
+
+	for i := range v.AudioTracks {
+		track := &v.AudioTracks[i]
+
+		target := v.localPath(fmt.Sprintf(".audio%d.m4a", i))
+		v.addTemporaryFile(target)
+
+		output, err := v.run("ffmpeg", "-y", "-v", "error", "-i", track.SourcePath,
+			"-vn", "-map", "0:a:0", "-c:a", "aac", "-b:a", "128k", "-movflags", "+faststart", target)
+		if err != nil {
+			return fmt.Errorf("error converting audio track %v: %v", track.FilePath, err)
+		}
+
+		printOutput(output)
+
+		track.MediaPath = target
+	}
+
+	return nil
+}
+
+/*
+fragmentAudioTracks fragmenta cada faixa de áudio alternativa com o `mp4fragment`.
+*/
+func (v *VideoService) fragmentAudioTracks() error {
+
+	for i := range v.AudioTracks {
+		target := v.audioTrackFragment(i)
+		v.addTemporaryFile(target)
+
+		output, err := v.run("mp4fragment", v.AudioTracks[i].MediaPath, target)
+		if err != nil {
+			return err
+		}
+
+		printOutput(output)
+	}
+
+	return nil
+}
+
+// audioTrackFragment retorna o arquivo fragmentado da faixa de áudio i.
+func (v *VideoService) audioTrackFragment(i int) string {
+	return v.localPath(fmt.Sprintf(".audio%d.frag", i))
+}
+
+/*
+audioTrackInputs monta as entradas das faixas de áudio do mp4dash, no formato
+[+language=<idioma>,+language_name=<label>]<arquivo>.
+*/
+func (v *VideoService) audioTrackInputs() []string {
+	inputs := make([]string, 0, len(v.AudioTracks))
+
+	for i, track := range v.AudioTracks {
+		options := "+language=" + track.Language
+		if track.Label != "" {
+			options += ",+language_name=" + track.Label
+		}
+
+		inputs = append(inputs, fmt.Sprintf("[%v]%v", options, v.audioTrackFragment(i)))
+	}
+
+	return inputs
+}
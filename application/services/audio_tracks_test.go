@@ -0,0 +1,87 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestVideoServiceAudioTracks verifica que as faixas de áudio alternativas são baixadas,
+convertidas, fragmentadas e passadas ao mp4dash com o idioma e o label.
+*/
+func TestVideoServiceAudioTracks(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+	client.Put("bucket", "video.en.m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x02\x00english"))
+	client.Put("bucket", "video.es.mp3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00spanish"))
+	client.Put("bucket", "video.fr.mp3", []byte("<html><body>not audio</body></html>"))
+
+	video, repo := prepare()
+	video.FilePath = "video.mp4"
+
+	runner := &recordingRunner{}
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.AudioTracks = []services.AudioTrack{
+		{FilePath: "video.en.m4a", Language: "en", Label: "English"},
+		{FilePath: "video.es.mp3", Language: "es"},
+	}
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.DownloadAudioTracks("bucket"))
+	require.Nil(t, videoService.Convert())
+	require.Nil(t, videoService.Fragment())
+	require.Nil(t, videoService.Encode())
+
+	extracted := 0
+	for _, command := range runner.commands {
+		if strings.HasPrefix(command, "ffmpeg ") {
+			require.Contains(t, command, "-vn")
+			extracted++
+		}
+	}
+	require.Equal(t, 2, extracted)
+
+	mp4dash := runner.commands[len(runner.commands)-1]
+	require.Contains(t, mp4dash, "[+language=en,+language_name=English]"+localStoragePath+"/"+video.ID+".audio0.frag")
+	require.Contains(t, mp4dash, "[+language=es]"+localStoragePath+"/"+video.ID+".audio1.frag")
+
+	content, err := ioutil.ReadFile(localStoragePath + "/" + video.ID + ".audio1.frag")
+	require.Nil(t, err)
+	require.Contains(t, string(content), "spanish")
+
+	require.Nil(t, videoService.Finish())
+	files, err := ioutil.ReadDir(localStoragePath)
+	require.Nil(t, err)
+	require.Empty(t, files)
+
+	videoService.AudioTracks = []services.AudioTrack{{FilePath: "video.fr.mp3", Language: "fr"}}
+	err = videoService.DownloadAudioTracks("bucket")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported audio content type")
+}
+
+/*
+TestAudioTrackValidate verifica o idioma e o label das faixas de áudio.
+*/
+func TestAudioTrackValidate(t *testing.T) {
+	require.Nil(t, (&services.AudioTrack{FilePath: "a.m4a", Language: "pt-BR", Label: "Português"}).Validate())
+	require.Error(t, (&services.AudioTrack{FilePath: "a.m4a", Language: "Portuguese"}).Validate())
+	require.Error(t, (&services.AudioTrack{FilePath: "a.m4a", Language: "pt", Label: "pt],+key=x"}).Validate())
+	require.Error(t, (&services.AudioTrack{Language: "pt"}).Validate())
+}
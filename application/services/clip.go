@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+Clip define o trecho do vídeo mantido na conversão, em segundos.
+Start e End são opcionais: sem Start, o corte começa no início; sem End, vai até o final.
+Quando há concatenação, o corte é aplicado ao resultado concatenado.
+*/
+type Clip struct {
+	Start *float64 `json:"start,omitempty"`
+	End   *float64 `json:"end,omitempty"`
+}
+
+/*
+Validate verifica se o intervalo do corte é válido.
+*/
+func (c *Clip) Validate() error {
+	if c.Start != nil && *c.Start < 0 {
+		return fmt.Errorf("invalid start: %v", *c.Start)
+	}
+
+	if c.End != nil && *c.End <= 0 {
+		return fmt.Errorf("invalid end: %v", *c.End)
+	}
+
+	if c.Start != nil && c.End != nil && *c.End <= *c.Start {
+		return fmt.Errorf("end must be after start")
+	}
+
+	return nil
+}
+
+// args retorna as opções de saída do ffmpeg que aplicam o corte.
+func (c *Clip) args() []string {
+	args := []string{}
+	start := 0.0
+
+	if c.Start != nil {
+		start = *c.Start
+		args = append(args, "-ss", formatFloat(start))
+	}
+
+	if c.End != nil {
+		args = append(args, "-t", formatFloat(*c.End-start))
+	}
+
+	return args
+}
+
+// duration retorna a duração do trecho mantido de uma entrada com a duração total informada.
+func (c *Clip) duration(total float64) float64 {
+	start, end := 0.0, total
+
+	if c.Start != nil {
+		start = *c.Start
+	}
+
+	if c.End != nil && *c.End < end {
+		end = *c.End
+	}
+
+	if end < start {
+		return 0
+	}
+
+	return end - start
+}
+
+/*
+DownloadInputs baixa as entradas adicionais concatenadas após o arquivo principal,
+na ordem informada. Assim como em Download, apenas contêineres de vídeo conhecidos
+são aceitos.
+*/
+func (v *VideoService) DownloadInputs(bucketName string) error {
+	v.InputPaths = make([]string, 0, len(v.Inputs))
+
+	for i, input := range v.Inputs {
+		path, err := v.fetch(input, bucketName, func(header []byte) (string, error) {
+			container := utils.DetectContainer(header)
+			if container == "" {
+				return "", fmt.Errorf("unsupported input content type: %v", http.DetectContentType(header))
+			}
+
+			return v.localPath(fmt.Sprintf(".input%d%v", i+1, sourceExtension(input, container))), nil
+		})
+		if err != nil {
+			return fmt.Errorf("error downloading input %v: %v", input, err)
+		}
+
+		v.InputPaths = append(v.InputPaths, path)
+
+		log.Printf("input %v of video %v has been stored", input, v.Video.ID)
+	}
+
+	return nil
+}
+
+/*
+concatFilter monta o filtro do ffmpeg que concatena as entradas na ordem.
+Como o filtro concat exige parâmetros iguais, cada entrada é normalizada para a
+resolução (720p, se desconhecida) e a taxa de quadros da primeira, com barras para
+preservar a proporção, e para áudio estéreo a 48 kHz; entradas sem áudio recebem
+silêncio com a sua duração.
+O resultado fica nos rótulos [cv] e [ca].
+*/
+func concatFilter(probes []*probeResult) string {
+	first := probes[0].video()
+	width, height := even(first.Width), even(first.Height)
+	if first.Width == 0 || first.Height == 0 {
+		width, height = 1280, 720
+	}
+
+	frameRate := first.RFrameRate
+	if frameRate == "" || strings.HasPrefix(frameRate, "0/") {
+		frameRate = "30"
+	}
+
+	filters := []string{}
+	segments := ""
+
+	for i, probe := range probes {
+		filters = append(filters, fmt.Sprintf(
+			"[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%v,format=yuv420p[v%d]",
+			i, width, height, width, height, frameRate, i))
+
+		if probe.audio() != nil {
+			filters = append(filters, fmt.Sprintf("[%d:a:0]aresample=48000,aformat=sample_fmts=fltp:channel_layouts=stereo[a%d]", i, i))
+		} else {
+			filters = append(filters, fmt.Sprintf("anullsrc=r=48000:cl=stereo,atrim=duration=%v[a%d]", probe.duration(), i))
+		}
+
+		segments += fmt.Sprintf("[v%d][a%d]", i, i)
+	}
+
+	filters = append(filters, fmt.Sprintf("%vconcat=n=%d:v=1:a=1[cv][ca]", segments, len(probes)))
+
+	return strings.Join(filters, ";")
+}
+
+// duration retorna a duração do arquivo informada pelo ffprobe, em segundos (0 se desconhecida).
+func (p *probeResult) duration() string {
+	return formatFloat(p.seconds())
+}
+
+// seconds retorna a duração do arquivo informada pelo ffprobe, em segundos (0 se desconhecida).
+func (p *probeResult) seconds() float64 {
+	duration, err := strconv.ParseFloat(p.Format.Duration, 64)
+	if err != nil || duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// even arredonda a dimensão para baixo até um número par, exigido pelo yuv420p.
+func even(dimension int) int {
+	if dimension < 2 {
+		return 2
+	}
+	return dimension - dimension%2
+}
+
+// formatFloat formata um número para os argumentos do ffmpeg, sem casas decimais desnecessárias.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
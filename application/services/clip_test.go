@@ -0,0 +1,88 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestVideoServiceConcatAndClip verifica que as entradas são baixadas na ordem,
+normalizadas e concatenadas, e que o corte é aplicado ao resultado.
+*/
+func TestVideoServiceConcatAndClip(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "intro.mp4", fakeMp4("intro"))
+	client.Put("bucket", "main.mkv", []byte("\x1A\x45\xDF\xA3\x42\x82\x88matroska"))
+
+	video, repo := prepare()
+	video.FilePath = "intro.mp4"
+
+	start, end := 2.5, 12.5
+	runner := &recordingRunner{fakeRunner: fakeRunner{Probes: map[string]string{
+		".mkv": `{"streams": [{"codec_type": "video", "codec_name": "vp9", "width": 640, "height": 480}], "format": {"duration": "42.0"}}`,
+	}}}
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.Inputs = []string{"main.mkv"}
+	videoService.Clip = &services.Clip{Start: &start, End: &end}
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.DownloadInputs("bucket"))
+	require.Len(t, videoService.InputPaths, 1)
+
+	require.Nil(t, videoService.Convert())
+	require.Equal(t, services.ConversionTranscode, videoService.Conversion)
+	// 60s + 42s concatenados, cortados de 2.5s a 12.5s.
+	require.Equal(t, 10.0, videoService.Duration)
+
+	ffmpeg := runner.commands[len(runner.commands)-1]
+	require.Contains(t, ffmpeg, "-i "+videoService.SourcePath+" -i "+videoService.InputPaths[0])
+	require.Contains(t, ffmpeg, "[1:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720")
+	require.Contains(t, ffmpeg, "fps=30/1")
+	require.Contains(t, ffmpeg, "anullsrc=r=48000:cl=stereo,atrim=duration=42[a1]")
+	require.Contains(t, ffmpeg, "[v0][a0][v1][a1]concat=n=2:v=1:a=1[cv][ca]")
+	require.Contains(t, ffmpeg, "-map [cv] -map [ca]")
+	require.Contains(t, ffmpeg, "-ss 2.5 -t 10")
+
+	require.Nil(t, videoService.Fragment())
+	require.Nil(t, videoService.Finish())
+	files, err := ioutil.ReadDir(localStoragePath)
+	require.Nil(t, err)
+	require.Empty(t, files)
+}
+
+func TestParseJobMessageInputsAndClip(t *testing.T) {
+	broker := queue.NewMemoryBroker(4)
+
+	jobMessage, err := services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "inputs": ["intro.mp4", "main.mp4"], "start": 1, "end": 5}`), nil))
+	require.Nil(t, err)
+	require.Equal(t, "intro.mp4", jobMessage.FilePath)
+	require.Equal(t, []string{"main.mp4"}, jobMessage.ConcatInputs())
+	require.Equal(t, 1.0, *jobMessage.Clip().Start)
+
+	_, err = services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "inputs": ["b.mp4"]}`), nil))
+	require.Error(t, err)
+
+	_, err = services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "start": 5, "end": 1}`), nil))
+	require.Error(t, err)
+
+	jobMessage, err = services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4"}`), nil))
+	require.Nil(t, err)
+	require.Nil(t, jobMessage.Clip())
+	require.Nil(t, jobMessage.ConcatInputs())
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+/*
+CommandRunner executa as ferramentas externas utilizadas no processamento
+(mp4fragment, mp4dash, ffmpeg...). Permite substituir a execução real nos testes.
+*/
+type CommandRunner interface {
+	// Run executa o comando e retorna a saída padrão e de erro combinadas.
+	Run(name string, args ...string) ([]byte, error)
+}
+
+/*
+ExecRunner é o CommandRunner padrão, que executa os comandos com os/exec.
+Se Progress for informado, é chamado a cada escrita do comando na saída padrão ou
+de erro (o ffmpeg, por exemplo, imprime as estatísticas da conversão periodicamente).
+*/
+type ExecRunner struct {
+	Progress func()
+}
+
+func (r ExecRunner) Run(name string, args ...string) ([]byte, error) {
+	output := &progressWriter{Progress: r.Progress}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	return output.Bytes(), err
+}
+
+// progressWriter acumula a saída de um comando e sinaliza cada escrita em Progress.
+type progressWriter struct {
+	bytes.Buffer
+	Progress func()
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	if w.Progress != nil && len(p) > 0 {
+		w.Progress()
+	}
+
+	return w.Buffer.Write(p)
+}
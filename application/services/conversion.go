@@ -0,0 +1,225 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Conversões possíveis da entrada antes da fragmentação.
+const (
+	ConversionNone      = "none"      // A entrada já é um MP4 compatível e é fragmentada diretamente
+	ConversionRemux     = "remux"     // As faixas são copiadas para um contêiner MP4, sem recodificação
+	ConversionTranscode = "transcode" // Ao menos uma faixa é recodificada (H.264/AAC)
+)
+
+// Codecs que podem ser copiados para um MP4 e fragmentados pelo Bento4.
+var (
+	compatibleVideoCodecs = map[string]bool{"h264": true, "hevc": true}
+	compatibleAudioCodecs = map[string]bool{"aac": true, "mp3": true, "ac3": true, "eac3": true}
+)
+
+// probeResult representa a saída do ffprobe utilizada para decidir a conversão.
+type probeResult struct {
+	Streams []probeStream `json:"streams"`
+	Format  struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+type probeStream struct {
+	CodecType  string `json:"codec_type"`
+	CodecName  string `json:"codec_name"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	RFrameRate string `json:"r_frame_rate,omitempty"`
+}
+
+// video retorna a primeira faixa de vídeo, ou nil.
+func (p *probeResult) video() *probeStream {
+	return p.stream("video")
+}
+
+// audio retorna a primeira faixa de áudio, ou nil.
+func (p *probeResult) audio() *probeStream {
+	return p.stream("audio")
+}
+
+func (p *probeResult) stream(codecType string) *probeStream {
+	for i := range p.Streams {
+		if p.Streams[i].CodecType == codecType {
+			return &p.Streams[i]
+		}
+	}
+	return nil
+}
+
+/*
+Convert prepara a entrada para o mp4fragment, que aceita apenas MP4.
+As faixas do arquivo baixado são inspecionadas com o ffprobe:
+- MP4 com codecs compatíveis é usado como está (none);
+- outros contêineres com codecs compatíveis são remuxados para MP4 (remux);
+- faixas com codecs incompatíveis são recodificadas para H.264/AAC (transcode).
+Concatenação de entradas, corte (Clip), normalização de loudness e marca d'água
+exigem filtros do ffmpeg e, portanto, sempre recodificam as faixas afetadas; como o
+resultado é a única entrada das etapas seguintes, as alterações aparecem em todas
+as renditions.
+A conversão realizada fica registrada em Conversion, o arquivo resultante em MediaPath
+e a duração da saída (a soma das entradas, limitada ao corte) em Duration.
+As faixas de áudio alternativas são sempre convertidas para AAC.
+*/
+func (v *VideoService) Convert() error {
+
+	err := v.convertAudioTracks()
+	if err != nil {
+		return err
+	}
+
+	sources := append([]string{v.SourcePath}, v.InputPaths...)
+	probes := make([]*probeResult, 0, len(sources))
+	for _, source := range sources {
+		probe, err := v.probe(source)
+		if err != nil {
+			return err
+		}
+
+		if probe.video() == nil {
+			return fmt.Errorf("input %v has no video stream", filepath.Base(source))
+		}
+
+		probes = append(probes, probe)
+	}
+
+	v.Duration = 0
+	for _, probe := range probes {
+		v.Duration += probe.seconds()
+	}
+
+	if v.Clip != nil {
+		v.Duration = v.Clip.duration(v.Duration)
+	}
+
+	videoCodec := probes[0].video().CodecName
+	audioCodec := ""
+	if audio := probes[0].audio(); audio != nil {
+		audioCodec = audio.CodecName
+	}
+
+	concat := len(sources) > 1
+	trim := v.Clip != nil
+	normalize := v.Loudnorm != nil && (audioCodec != "" || concat)
+
+	copyVideo := compatibleVideoCodecs[videoCodec] && !concat && !trim && v.Watermark == nil
+	copyAudio := (audioCodec == "" || compatibleAudioCodecs[audioCodec]) && !concat && !trim && !normalize
+
+	if v.SourceContainer == "mp4" && copyVideo && copyAudio {
+		v.Conversion = ConversionNone
+		v.MediaPath = v.SourcePath
+		return nil
+	}
+
+	v.Conversion = ConversionRemux
+	if !copyVideo || !copyAudio {
+		v.Conversion = ConversionTranscode
+	}
+
+	target := v.localPath(".converted.mp4")
+	v.addTemporaryFile(target)
+
+	cmdArgs := []string{"-y", "-v", "error"}
+	for _, source := range sources {
+		cmdArgs = append(cmdArgs, "-i", source)
+	}
+
+	// Monta o grafo de filtros; videoLabel e audioLabel indicam as faixas mapeadas na saída.
+	filters := []string{}
+	videoLabel, audioLabel := "0:v:0", "0:a:0?"
+
+	if concat {
+		filters = append(filters, concatFilter(probes))
+		videoLabel, audioLabel = "[cv]", "[ca]"
+	}
+
+	if normalize {
+		// A medição usa o mesmo áudio da saída: concatenado e cortado.
+		measured, err := v.measureLoudness(cmdArgs, filters, audioLabel)
+		if err != nil {
+			return err
+		}
+
+		if measured != nil {
+			filters = append(filters, filterInput(audioLabel)+v.Loudnorm.filter(measured)+"[na]")
+			audioLabel = "[na]"
+		}
+	}
+
+	if v.Watermark != nil {
+		cmdArgs = append(cmdArgs, "-i", v.Watermark.LocalPath)
+		filters = append(filters, v.Watermark.filter(len(sources), filterInput(videoLabel)))
+		videoLabel = "[v]"
+	}
+
+	if len(filters) > 0 {
+		cmdArgs = append(cmdArgs, "-filter_complex", strings.Join(filters, ";"))
+	}
+	cmdArgs = append(cmdArgs, "-map", videoLabel, "-map", audioLabel)
+
+	if trim {
+		cmdArgs = append(cmdArgs, v.Clip.args()...)
+	}
+
+	if copyVideo {
+		cmdArgs = append(cmdArgs, "-c:v", "copy")
+	} else {
+		cmdArgs = append(cmdArgs, "-c:v", "libx264", "-preset", "medium", "-pix_fmt", "yuv420p")
+	}
+	if copyAudio {
+		cmdArgs = append(cmdArgs, "-c:a", "copy")
+	} else {
+		cmdArgs = append(cmdArgs, "-c:a", "aac", "-b:a", "128k")
+	}
+	cmdArgs = append(cmdArgs, "-movflags", "+faststart", target)
+
+	output, err := v.run("ffmpeg", cmdArgs...)
+	if err != nil {
+		return fmt.Errorf("error converting %v input (%v/%v): %v", v.SourceContainer, videoCodec, audioCodec, err)
+	}
+
+	printOutput(output)
+
+	v.MediaPath = target
+
+	log.Printf("video %v converted from %v (%v/%v): %v", v.Video.ID, v.SourceContainer, videoCodec, audioCodec, v.Conversion)
+
+	return nil
+}
+
+// filterInput converte um rótulo de -map (ex.: 0:v:0 ou [cv]) em uma entrada do grafo de filtros.
+func filterInput(label string) string {
+	if strings.HasPrefix(label, "[") {
+		return label
+	}
+	return "[" + strings.TrimSuffix(label, "?") + "]"
+}
+
+/*
+probe executa o ffprobe e retorna as faixas do arquivo com os seus codecs,
+dimensões e taxa de quadros, e a duração do arquivo.
+*/
+func (v *VideoService) probe(path string) (*probeResult, error) {
+	output, err := v.run("ffprobe", "-v", "error",
+		"-show_entries", "stream=codec_type,codec_name,width,height,r_frame_rate:format=duration", "-of", "json", path)
+	if err != nil {
+		return nil, fmt.Errorf("error probing input: %v", err)
+	}
+
+	var result probeResult
+	err = json.Unmarshal(output, &result)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ffprobe output: %v", strings.TrimSpace(string(output)))
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,127 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingRunner registra os comandos executados pelo fakeRunner.
+type recordingRunner struct {
+	fakeRunner
+	commands []string
+}
+
+func (r *recordingRunner) Run(name string, args ...string) ([]byte, error) {
+	r.commands = append(r.commands, name+" "+strings.Join(args, " "))
+	return r.fakeRunner.Run(name, args...)
+}
+
+/*
+TestVideoServiceConvert verifica a decisão de conversão para cada tipo de entrada:
+MP4 compatível é usado como está, outros contêineres com codecs compatíveis são
+remuxados e codecs incompatíveis são recodificados. O arquivo baixado mantém a extensão original.
+*/
+func TestVideoServiceConvert(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("mp4"))
+	client.Put("bucket", "video.mov", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00qt  mov"))
+	client.Put("bucket", "video.mkv", []byte("\x1A\x45\xDF\xA3\x42\x82\x88matroska"))
+
+	vp9 := `{"streams": [{"codec_type": "video", "codec_name": "vp9"}, {"codec_type": "audio", "codec_name": "opus"}]}`
+
+	cases := []struct {
+		filePath   string
+		probe      string
+		container  string
+		conversion string
+		ffmpeg     []string
+	}{
+		{"video.mp4", "", "mp4", services.ConversionNone, nil},
+		{"video.mov", "", "mov", services.ConversionRemux, []string{"-c:v copy", "-c:a copy"}},
+		{"video.mkv", vp9, "mkv", services.ConversionTranscode, []string{"-c:v libx264", "-c:a aac"}},
+	}
+
+	for _, c := range cases {
+		video, repo := prepare()
+		video.FilePath = c.filePath
+
+		runner := &recordingRunner{fakeRunner: fakeRunner{Probe: c.probe}}
+
+		videoService := services.NewVideoService()
+		videoService.Video = video
+		videoService.VideoRepository = repo
+		videoService.Storage = client
+		videoService.Runner = runner
+
+		require.Nil(t, videoService.Download("bucket"))
+		require.Equal(t, c.container, videoService.SourceContainer)
+		require.Equal(t, filepath.Ext(c.filePath), filepath.Ext(videoService.SourcePath))
+
+		require.Nil(t, videoService.Convert())
+		require.Equal(t, c.conversion, videoService.Conversion)
+
+		ffmpeg := ""
+		for _, command := range runner.commands {
+			if strings.HasPrefix(command, "ffmpeg ") {
+				ffmpeg = command
+			}
+		}
+
+		if c.ffmpeg == nil {
+			require.Empty(t, ffmpeg)
+			require.Equal(t, videoService.SourcePath, videoService.MediaPath)
+		} else {
+			for _, arg := range c.ffmpeg {
+				require.Contains(t, ffmpeg, arg)
+			}
+			require.True(t, strings.HasSuffix(videoService.MediaPath, ".mp4"))
+		}
+
+		require.Nil(t, videoService.Fragment())
+		require.Nil(t, videoService.Finish())
+
+		files, err := ioutil.ReadDir(localStoragePath)
+		require.Nil(t, err)
+		require.Empty(t, files)
+	}
+}
+
+/*
+TestVideoServiceConvertRequiresVideoStream verifica que entradas sem faixa de vídeo são rejeitadas.
+*/
+func TestVideoServiceConvertRequiresVideoStream(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "audio.mp4", fakeMp4("audio"))
+
+	video, repo := prepare()
+	video.FilePath = "audio.mp4"
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = fakeRunner{Probe: `{"streams": [{"codec_type": "audio", "codec_name": "aac"}]}`}
+
+	require.Nil(t, videoService.Download("bucket"))
+
+	err = videoService.Convert()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no video stream")
+}
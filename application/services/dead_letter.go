@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"microsservico-encoder/framework/queue"
+	"strings"
+)
+
+/*
+DeadLetterFilter seleciona as mensagens reenfileiradas por DeadLetterReplayer.
+Campos vazios não filtram.
+*/
+type DeadLetterFilter struct {
+	ResourceID    string // resource_id do corpo da mensagem
+	CorrelationID string // correlation_id do corpo ou do cabeçalho x-correlation-id
+	Limit         int    // Quantidade máxima de mensagens lidas da fila (0: todas)
+}
+
+// matches verifica se a mensagem atende ao filtro.
+func (f DeadLetterFilter) matches(message queue.Message) bool {
+	if f.ResourceID == "" && f.CorrelationID == "" {
+		return true
+	}
+
+	var body struct {
+		ResourceID    string `json:"resource_id"`
+		CorrelationID string `json:"correlation_id"`
+	}
+	_ = json.Unmarshal(message.Body(), &body)
+
+	if f.ResourceID != "" && body.ResourceID != f.ResourceID {
+		return false
+	}
+
+	if f.CorrelationID != "" && body.CorrelationID != f.CorrelationID &&
+		fmt.Sprint(message.Headers()[CorrelationIDHeader]) != f.CorrelationID {
+		return false
+	}
+
+	return true
+}
+
+// DeadLetterReplay resume uma execução de DeadLetterReplayer.Replay.
+type DeadLetterReplay struct {
+	Matched  int // Mensagens que atendem ao filtro
+	Replayed int // Mensagens movidas para a fila de entrada
+	Skipped  int // Mensagens devolvidas para a fila de mensagens mortas
+}
+
+/*
+DeadLetterReplayer move as mensagens da fila de mensagens mortas (DLQ) de volta para
+a fila de entrada, publicando-as na exchange e routing key informadas, com a mesma prioridade.
+As mensagens que não atendem ao filtro, ou todas em modo de simulação (DryRun),
+são devolvidas para a DLQ ao final, para não serem lidas de novo na mesma execução.
+*/
+type DeadLetterReplayer struct {
+	Getter     queue.Getter
+	Publisher  queue.Publisher
+	Queue      string // Fila de mensagens mortas
+	Exchange   string // Exchange em que as mensagens são republicadas
+	RoutingKey string // Routing key das mensagens republicadas (a fila de entrada, pela exchange padrão)
+	DryRun     bool
+}
+
+// Replay lê as mensagens da DLQ e republica as que atendem ao filtro.
+func (r *DeadLetterReplayer) Replay(filter DeadLetterFilter) (DeadLetterReplay, error) {
+	var result DeadLetterReplay
+	var skipped []queue.Message
+
+	defer func() {
+		for _, message := range skipped {
+			err := message.Nack(true)
+			if err != nil {
+				log.Printf("error returning message %v to %v: %v", message.ID(), r.Queue, err)
+			}
+		}
+	}()
+
+	for read := 0; filter.Limit <= 0 || read < filter.Limit; read++ {
+		message, ok, err := r.Getter.Get(r.Queue)
+		if err != nil {
+			return result, err
+		}
+
+		if !ok {
+			break
+		}
+
+		if !filter.matches(message) {
+			result.Skipped++
+			skipped = append(skipped, message)
+			continue
+		}
+
+		result.Matched++
+
+		if r.DryRun {
+			skipped = append(skipped, message)
+			continue
+		}
+
+		err = r.Publisher.Publish(r.Exchange, r.RoutingKey, queue.Publishing{
+			ContentType: "application/json",
+			Body:        message.Body(),
+			Headers:     replayHeaders(message.Headers()),
+			Priority:    message.Priority(),
+		})
+		if err != nil {
+			skipped = append(skipped, message)
+			return result, fmt.Errorf("error replaying message %v: %w", message.ID(), err)
+		}
+
+		err = message.Ack()
+		if err != nil {
+			return result, fmt.Errorf("error removing message %v from %v: %w", message.ID(), r.Queue, err)
+		}
+
+		result.Replayed++
+	}
+
+	return result, nil
+}
+
+// replayHeaders copia os cabeçalhos da mensagem, sem os registros de dead-lettering do RabbitMQ.
+func replayHeaders(headers map[string]interface{}) map[string]interface{} {
+	replayed := map[string]interface{}{}
+	for key, value := range headers {
+		if key == "x-death" || strings.HasPrefix(key, "x-first-death-") || strings.HasPrefix(key, "x-last-death-") {
+			continue
+		}
+		replayed[key] = value
+	}
+
+	return replayed
+}
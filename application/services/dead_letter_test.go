@@ -0,0 +1,70 @@
+package services_test
+
+import (
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestDeadLetterReplayerReplaysMatchingMessages verifica que apenas as mensagens que
+atendem ao filtro são republicadas, sem os cabeçalhos de dead-lettering, e que as
+demais são devolvidas para a DLQ.
+*/
+func TestDeadLetterReplayerReplaysMatchingMessages(t *testing.T) {
+	dlq := queue.NewMemoryBroker(3)
+	first := dlq.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4"}`), map[string]interface{}{
+		"x-death":                    []interface{}{"rejected"},
+		"x-first-death-queue":        "videos",
+		services.CorrelationIDHeader: "corr-a",
+	})
+	other := dlq.Enqueue([]byte(`{"resource_id": "b", "file_path": "b.mp4"}`), nil)
+	invalid := dlq.Enqueue([]byte(`not json`), nil)
+
+	broker := queue.NewMemoryBroker(0)
+	replayer := &services.DeadLetterReplayer{Getter: dlq, Publisher: broker, Queue: "videos-dlq", RoutingKey: "videos"}
+
+	result, err := replayer.Replay(services.DeadLetterFilter{ResourceID: "a"})
+	require.Nil(t, err)
+	require.Equal(t, services.DeadLetterReplay{Matched: 1, Replayed: 1, Skipped: 2}, result)
+
+	require.True(t, first.Acked())
+	for _, message := range []*queue.MemoryMessage{other, invalid} {
+		nacked, requeued := message.Nacked()
+		require.True(t, nacked)
+		require.True(t, requeued)
+	}
+
+	published := broker.Published()
+	require.Len(t, published, 1)
+	require.Equal(t, "videos", published[0].RoutingKey)
+	require.Equal(t, first.Body(), published[0].Publishing.Body)
+	require.Equal(t, map[string]interface{}{services.CorrelationIDHeader: "corr-a"}, published[0].Publishing.Headers)
+}
+
+// TestDeadLetterReplayerDryRunAndLimit verifica que a simulação não republica e que o limite é respeitado.
+func TestDeadLetterReplayerDryRunAndLimit(t *testing.T) {
+	dlq := queue.NewMemoryBroker(3)
+	first := dlq.Enqueue([]byte(`{"resource_id": "a", "correlation_id": "corr"}`), nil)
+	dlq.Enqueue([]byte(`{"resource_id": "b"}`), map[string]interface{}{services.CorrelationIDHeader: "corr"})
+	third := dlq.Enqueue([]byte(`{"resource_id": "c", "correlation_id": "corr"}`), nil)
+
+	broker := queue.NewMemoryBroker(0)
+	replayer := &services.DeadLetterReplayer{Getter: dlq, Publisher: broker, Queue: "videos-dlq", RoutingKey: "videos", DryRun: true}
+
+	result, err := replayer.Replay(services.DeadLetterFilter{CorrelationID: "corr", Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, services.DeadLetterReplay{Matched: 2}, result)
+	require.Empty(t, broker.Published())
+
+	nacked, requeued := first.Nacked()
+	require.True(t, nacked)
+	require.True(t, requeued)
+
+	// A terceira mensagem não foi lida.
+	nacked, _ = third.Nacked()
+	require.False(t, nacked)
+	require.False(t, third.Acked())
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Esquemas de criptografia Common Encryption aceitos pelo mp4dash.
+const (
+	EncryptionSchemeCENC = "cenc" // AES-CTR
+	EncryptionSchemeCBCS = "cbcs" // AES-CBC com padrão de blocos (exigido pelo FairPlay/HLS)
+)
+
+// Sistemas de DRM sinalizados no manifesto.
+const (
+	DRMClearKey  = "clearkey"
+	DRMWidevine  = "widevine"
+	DRMPlayReady = "playready"
+)
+
+/*
+Encryption define a criptografia opcional do conteúdo empacotado.
+Exemplo na mensagem do job:
+
+	"encryption": {"scheme": "cbcs", "systems": ["widevine", "playready"]}
+
+Sem scheme, é utilizado cenc; sem systems, apenas ClearKey é sinalizado.
+*/
+type Encryption struct {
+	Scheme  string   `json:"scheme"`
+	Systems []string `json:"systems"`
+}
+
+/*
+Validate verifica o esquema e os sistemas de DRM e define os valores padrão.
+*/
+func (e *Encryption) Validate() error {
+	if e.Scheme == "" {
+		e.Scheme = EncryptionSchemeCENC
+	}
+
+	if e.Scheme != EncryptionSchemeCENC && e.Scheme != EncryptionSchemeCBCS {
+		return fmt.Errorf("invalid encryption scheme: %q", e.Scheme)
+	}
+
+	if len(e.Systems) == 0 {
+		e.Systems = []string{DRMClearKey}
+	}
+
+	for _, system := range e.Systems {
+		if system != DRMClearKey && system != DRMWidevine && system != DRMPlayReady {
+			return fmt.Errorf("invalid DRM system: %q", system)
+		}
+	}
+
+	return nil
+}
+
+/*
+encryptionArgs obtém a chave de conteúdo do KeyProvider e monta os argumentos de
+criptografia e de sinalização de DRM do mp4dash. O KID é registrado em KeyID;
+a chave é retornada apenas para que a saída do mp4dash possa ser mascarada.
+*/
+func (v *VideoService) encryptionArgs() ([]string, string, error) {
+	if v.Encryption == nil {
+		return nil, "", nil
+	}
+
+	if v.KeyProvider == nil {
+		return nil, "", errors.New("encryption requested but no key provider is configured")
+	}
+
+	contentID := v.Video.ResourceID
+	if contentID == "" {
+		contentID = v.Video.ID
+	}
+
+	key, err := v.KeyProvider.ContentKey(context.Background(), contentID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = key.Validate()
+	if err != nil {
+		return nil, "", err
+	}
+
+	v.KeyID = key.KID
+
+	cmdArgs := []string{
+		"--encryption-key=" + key.KID + ":" + key.Key,
+		"--encryption-cenc-scheme=" + v.Encryption.Scheme,
+	}
+
+	for _, system := range v.Encryption.Systems {
+		switch system {
+		case DRMClearKey:
+			cmdArgs = append(cmdArgs, "--clearkey")
+			if url := os.Getenv("DRM_CLEARKEY_LICENSE_URL"); url != "" {
+				cmdArgs = append(cmdArgs, "--clearkey-license-uri="+url)
+			}
+		case DRMWidevine:
+			provider := os.Getenv("DRM_WIDEVINE_PROVIDER")
+			if provider == "" {
+				return nil, "", errors.New("DRM_WIDEVINE_PROVIDER is required for widevine")
+			}
+			cmdArgs = append(cmdArgs, "--widevine-header=provider:"+provider+"#content_id:"+hex.EncodeToString([]byte(contentID)))
+		case DRMPlayReady:
+			cmdArgs = append(cmdArgs, "--playready")
+			if url := os.Getenv("DRM_PLAYREADY_LICENSE_URL"); url != "" {
+				cmdArgs = append(cmdArgs, "--playready-header=LA_URL:"+url)
+			}
+		}
+	}
+
+	return cmdArgs, key.Key, nil
+}
+
+// redactKey remove a chave de conteúdo da saída dos comandos antes que ela seja registrada.
+func redactKey(output []byte, key string) []byte {
+	if key == "" {
+		return output
+	}
+	return bytes.ReplaceAll(output, []byte(key), []byte("[redacted]"))
+}
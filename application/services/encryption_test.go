@@ -0,0 +1,78 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/drm"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestVideoServiceEncodeEncrypted verifica que o mp4dash recebe a chave do KeyProvider,
+o esquema e a sinalização de cada sistema de DRM, e que apenas o KID é registrado.
+*/
+func TestVideoServiceEncodeEncrypted(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "DRM_WIDEVINE_PROVIDER", "widevine_test")
+	setEnv(t, "DRM_PLAYREADY_LICENSE_URL", "https://license.example.com/playready")
+
+	const kid, key = "eb676abbcb345e96bbcf616630f1a3da", "100b6c20940f779a4589152b57d2dacb"
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+
+	video, repo := prepare()
+	video.FilePath = "video.mp4"
+
+	runner := &recordingRunner{}
+
+	encryption := &services.Encryption{Scheme: services.EncryptionSchemeCBCS, Systems: []string{"clearkey", "widevine", "playready"}}
+	require.Nil(t, encryption.Validate())
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.Encryption = encryption
+	videoService.KeyProvider = &drm.StaticKeyProvider{Key: drm.ContentKey{KID: kid, Key: key}}
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.Fragment())
+	require.Nil(t, videoService.Encode())
+	require.Equal(t, kid, videoService.KeyID)
+
+	mp4dash := runner.commands[len(runner.commands)-1]
+	require.Contains(t, mp4dash, "--encryption-key="+kid+":"+key)
+	require.Contains(t, mp4dash, "--encryption-cenc-scheme=cbcs")
+	require.Contains(t, mp4dash, "--clearkey")
+	require.Contains(t, mp4dash, "--widevine-header=provider:widevine_test#content_id:")
+	require.Contains(t, mp4dash, "--playready-header=LA_URL:https://license.example.com/playready")
+
+	require.Nil(t, videoService.Finish())
+
+	videoService.KeyProvider = nil
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.Fragment())
+	err = videoService.Encode()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no key provider")
+	require.Nil(t, videoService.Finish())
+}
+
+func TestEncryptionValidate(t *testing.T) {
+	encryption := &services.Encryption{}
+	require.Nil(t, encryption.Validate())
+	require.Equal(t, services.EncryptionSchemeCENC, encryption.Scheme)
+	require.Equal(t, []string{services.DRMClearKey}, encryption.Systems)
+
+	require.Error(t, (&services.Encryption{Scheme: "aes"}).Validate())
+	require.Error(t, (&services.Encryption{Systems: []string{"fairplay"}}).Validate())
+}
@@ -0,0 +1,274 @@
+package services_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestJobManagerRejectsInvalidMessages exercita o JobManager de ponta a ponta
+com o broker em memória: mensagens inválidas devem ser rejeitadas sem reenfileiramento
+e gerar um evento job.failed contendo o código do erro e o corpo original.
+*/
+func TestJobManagerRejectsInvalidMessages(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	broker := queue.NewMemoryBroker(2)
+	invalidJson := broker.Enqueue([]byte("not a json"), map[string]interface{}{services.CorrelationIDHeader: "corr-1"})
+	invalidVideo := broker.Enqueue([]byte(`{"resource_id": "abc"}`), nil)
+	broker.Close()
+
+	messageChannel := make(chan queue.Message)
+	jobReturnChannel := make(chan services.JobWorkerResult)
+	broker.Consume(messageChannel)
+
+	jobManager := services.NewJobManager(db, broker, jobReturnChannel, messageChannel)
+	jobManager.Start()
+
+	for _, message := range []*queue.MemoryMessage{invalidJson, invalidVideo} {
+		nacked, requeued := message.Nacked()
+		require.True(t, nacked)
+		require.False(t, requeued)
+		require.False(t, message.Acked())
+	}
+
+	published := broker.Published()
+	require.Len(t, published, 2)
+
+	codes := map[string]string{}
+	for _, p := range published {
+		require.Equal(t, "jobs.failed", p.RoutingKey)
+
+		var event domain.JobEvent
+		require.Nil(t, json.Unmarshal(p.Publishing.Body, &event))
+		require.Equal(t, domain.JobFailed, event.Type)
+		require.Equal(t, domain.JobEventSchemaVersion, event.SchemaVersion)
+		require.NotEmpty(t, event.ID)
+		require.NotEmpty(t, event.CorrelationID)
+		require.False(t, event.OccurredAt.IsZero())
+		require.NotEmpty(t, event.Error.Message)
+		codes[event.Message] = event.Error.Code
+
+		if event.Message == "not a json" {
+			require.Equal(t, "corr-1", event.CorrelationID)
+		}
+	}
+	require.Equal(t, map[string]string{
+		"not a json":             domain.ErrorCodeInvalidMessage,
+		`{"resource_id": "abc"}`: domain.ErrorCodeInvalidVideo,
+	}, codes)
+}
+
+/*
+fakeRunner simula o ffprobe, o ffmpeg, o mp4fragment e o mp4dash copiando o conteúdo
+do vídeo de origem para o arquivo convertido, para o fragmento e para o manifesto gerado,
+permitindo verificar que cada job processou o seu próprio arquivo.
+O ffprobe responde com Probes[extensão do arquivo], com Probe ou, se ambos vazios,
+com uma faixa H.264 e uma AAC em 1280x720. A medição de loudness (ffmpeg com saída
+nula) responde com Loudness ou com valores fixos.
+*/
+type fakeRunner struct {
+	Probe    string
+	Probes   map[string]string
+	Loudness string
+}
+
+func (r fakeRunner) loudness() string {
+	if r.Loudness != "" {
+		return r.Loudness
+	}
+	return "[Parsed_loudnorm_0 @ 0x0]\n{\n\t\"input_i\" : \"-27.61\",\n\t\"input_tp\" : \"-4.47\",\n\t\"input_lra\" : \"18.06\",\n\t\"input_thresh\" : \"-39.20\",\n\t\"target_offset\" : \"0.58\"\n}\n"
+}
+
+func (r fakeRunner) Run(name string, args ...string) ([]byte, error) {
+	switch name {
+	case "ffprobe":
+		if probe, ok := r.Probes[filepath.Ext(args[len(args)-1])]; ok {
+			return []byte(probe), nil
+		}
+		if r.Probe != "" {
+			return []byte(r.Probe), nil
+		}
+		return []byte(`{"streams": [{"codec_type": "video", "codec_name": "h264", "width": 1280, "height": 720, "r_frame_rate": "30/1"}, {"codec_type": "audio", "codec_name": "aac"}], "format": {"duration": "60.0"}}`), nil
+	case "ffmpeg":
+		if args[len(args)-1] == "-" {
+			return []byte(r.loudness()), nil
+		}
+		content, err := ioutil.ReadFile(args[indexOf(args, "-i")+1])
+		if err != nil {
+			return nil, err
+		}
+		return nil, ioutil.WriteFile(args[len(args)-1], content, 0644)
+	case "mp4fragment":
+		content, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return nil, ioutil.WriteFile(args[1], content, 0644)
+	case "mp4dash":
+		content, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		output := args[indexOf(args, "-o")+1]
+		if err := os.MkdirAll(output, os.ModePerm); err != nil {
+			return nil, err
+		}
+		return nil, ioutil.WriteFile(filepath.Join(output, "stream.mpd"), content, 0644)
+	}
+
+	return nil, fmt.Errorf("unexpected command %v", name)
+}
+
+/*
+TestJobManagerIsolatesConcurrentJobs processa muitos jobs ao mesmo tempo e verifica
+que cada um manteve o seu próprio vídeo do início ao fim do pipeline.
+Deve ser executado com o detector de corridas: go test -race ./application/services/
+*/
+func TestJobManagerIsolatesConcurrentJobs(t *testing.T) {
+	const jobs = 20
+
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "CONCURRENCY_WORKERS", "8")
+	setEnv(t, "CONCURRENCY_UPLOAD", "2")
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	broker := queue.NewMemoryBroker(jobs)
+	messages := []*queue.MemoryMessage{}
+
+	for i := 0; i < jobs; i++ {
+		resourceID := fmt.Sprintf("resource-%d", i)
+		client.Put(os.Getenv("inputBucketName"), resourceID+".mp4", fakeMp4(resourceID))
+
+		body := fmt.Sprintf(`{"resource_id": %q, "file_path": %q}`, resourceID, resourceID+".mp4")
+		messages = append(messages, broker.Enqueue([]byte(body), nil))
+	}
+	broker.Close()
+
+	messageChannel := make(chan queue.Message)
+	broker.Consume(messageChannel)
+
+	jobManager := services.NewJobManager(db, broker, make(chan services.JobWorkerResult), messageChannel)
+	jobManager.Storage = client
+	jobManager.Runner = fakeRunner{}
+	jobManager.Start()
+
+	for _, message := range messages {
+		require.True(t, message.Acked())
+	}
+
+	completed := map[string]bool{}
+	for _, published := range broker.Published() {
+		var event domain.JobEvent
+		require.Nil(t, json.Unmarshal(published.Publishing.Body, &event))
+
+		if event.Type != domain.JobCompleted {
+			continue
+		}
+
+		manifest, ok := client.Get(os.Getenv("outputBucketName"), event.VideoID+"/stream.mpd")
+		require.True(t, ok)
+		require.Equal(t, fakeMp4(event.ResourceID), manifest)
+		completed[event.ResourceID] = true
+	}
+	require.Len(t, completed, jobs)
+}
+
+/*
+TestJobManagerCompletesWithoutOutputs verifica que uma falha ao assinar as URLs dos
+manifestos não impede o evento job.completed de um job já concluído: ele é publicado
+sem as URLs, com o motivo em outputs_error, e a mensagem é confirmada.
+*/
+func TestJobManagerCompletesWithoutOutputs(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "CONCURRENCY_WORKERS", "1")
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	client.Put(os.Getenv("inputBucketName"), "a.mp4", fakeMp4("a"))
+
+	broker := queue.NewMemoryBroker(1)
+	message := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4"}`), nil)
+	broker.Close()
+
+	messageChannel := make(chan queue.Message)
+	broker.Consume(messageChannel)
+
+	jobManager := services.NewJobManager(db, broker, make(chan services.JobWorkerResult), messageChannel)
+	jobManager.Storage = client
+	jobManager.Runner = fakeRunner{}
+	jobManager.Signer = &storage.URLSigner{GoogleAccessID: "encoder@project.iam.gserviceaccount.com", PrivateKey: []byte("invalid"), TTL: time.Hour}
+	jobManager.Start()
+
+	require.True(t, message.Acked())
+
+	var completed []domain.JobEvent
+	for _, published := range broker.Published() {
+		var event domain.JobEvent
+		require.Nil(t, json.Unmarshal(published.Publishing.Body, &event))
+
+		if event.Type == domain.JobCompleted {
+			completed = append(completed, event)
+		}
+	}
+
+	require.Len(t, completed, 1)
+	require.Nil(t, completed[0].Outputs)
+	require.Equal(t, domain.ErrorCodeOutputs, completed[0].OutputsError.Code)
+	require.NotEmpty(t, completed[0].OutputsError.Message)
+}
+
+// fakeMp4 retorna um conteúdo reconhecido como MP4, identificado pelo texto informado.
+func fakeMp4(id string) []byte {
+	return append([]byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isommp41"), id...)
+}
+
+// indexOf retorna a posição de value em args, ou -1.
+func indexOf(args []string, value string) int {
+	for i, arg := range args {
+		if arg == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// setEnv altera uma variável de ambiente durante o teste, restaurando o valor original ao final.
+func setEnv(t *testing.T, key string, value string) {
+	original, existed := os.LookupEnv(key)
+	os.Setenv(key, value)
+
+	t.Cleanup(func() {
+		if existed {
+			os.Setenv(key, original)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
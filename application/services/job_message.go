@@ -0,0 +1,235 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/queue"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// CorrelationIDHeader é o cabeçalho opcional utilizado para propagar o correlation ID.
+const CorrelationIDHeader = "x-correlation-id"
+
+// tagPattern define o formato aceito para as tags dos vídeos.
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// RequeuesHeader informa quantas vezes a mensagem foi reenfileirada.
+const RequeuesHeader = "x-encoder-requeues"
+
+/*
+JobMessage representa o corpo da mensagem recebida da fila para criar um job.
+Exemplo:
+
+	{
+	    "resource_id": "id do video da pessoa que enviou para nossa fila",
+	    "file_path": "convite.mp4",
+	    "inputs": ["opcional, substitui file_path: arquivos concatenados em ordem", "intro.mp4", "convite.mp4"],
+	    "start": 5.5,
+	    "end": 60,
+	    "correlation_id": "opcional",
+	    "callback_url": "opcional, notificada via webhook ao final do job",
+	    "output_bucket": "opcional, bucket de saída (padrão: outputBucketName)",
+	    "output_path": "opcional, template do caminho de saída com {video_id} ou {job_id}, ex.: {resource_id}/{date}/{video_id}",
+	    "profile": "opcional, perfil de encoding (padrão: default)",
+	    "priority": "opcional, de 0 a 10 (padrão: a prioridade AMQP da mensagem)",
+	    "tenant_id": "opcional, tenant dono do job (padrão: cabeçalho x-tenant-id ou default; pela API, o da chave)",
+	    "tags": ["opcional, tags do vídeo usadas pelas regras de retenção", "promo"],
+	    "audio_tracks": [
+	        {"file_path": "convite.en.m4a", "language": "en", "label": "opcional, ex.: English"}
+	    ],
+	    "subtitles": [
+	        {"file_path": "convite.pt.srt", "language": "pt-BR", "role": "opcional, subtitle ou caption"}
+	    ],
+	    "watermark": {"file_path": "logo.png", "position": "bottom-right", "opacity": 0.8, "scale": 0.15, "start": 0, "end": 10},
+	    "loudnorm": {"integrated": -23, "true_peak": -2, "lra": 7},
+	    "encryption": {"scheme": "opcional, cenc ou cbcs", "systems": ["clearkey", "widevine", "playready"]}
+	}
+
+O campo priority define a prioridade do job registrado, mas só altera a ordem de entrega
+da fila quando a mensagem é publicada com a mesma prioridade AMQP (ver SubmitJob).
+*/
+type JobMessage struct {
+	ResourceID    string          `json:"resource_id"`
+	FilePath      string          `json:"file_path"`
+	Inputs        []string        `json:"inputs"`
+	Start         *float64        `json:"start"`
+	End           *float64        `json:"end"`
+	CorrelationID string          `json:"correlation_id"`
+	CallbackURL   string          `json:"callback_url"`
+	OutputBucket  string          `json:"output_bucket"`
+	OutputPath    string          `json:"output_path"`
+	Profile       string          `json:"profile"`
+	Priority      *int            `json:"priority"`
+	TenantID      string          `json:"tenant_id"`
+	Tags          []string        `json:"tags"`
+	AudioTracks   []AudioTrack    `json:"audio_tracks"`
+	Subtitles     []SubtitleTrack `json:"subtitles"`
+	Watermark     *Watermark      `json:"watermark"`
+	Loudnorm      *Loudnorm       `json:"loudnorm"`
+	Encryption    *Encryption     `json:"encryption"`
+}
+
+/*
+ParseJobMessage faz o parse do corpo da mensagem para um JobMessage.
+Quando a mensagem não informa o correlation ID, ele é obtido do cabeçalho
+x-correlation-id ou gerado automaticamente. Sem priority no corpo, vale a
+prioridade AMQP da mensagem; sem tenant_id, vale o cabeçalho x-tenant-id ou o tenant padrão.
+*/
+func ParseJobMessage(message queue.Message) (*JobMessage, error) {
+	var jobMessage JobMessage
+
+	err := json.Unmarshal(message.Body(), &jobMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	if jobMessage.CallbackURL != "" {
+		callbackURL, err := url.Parse(jobMessage.CallbackURL)
+		if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+			return nil, fmt.Errorf("invalid callback_url: %v", jobMessage.CallbackURL)
+		}
+	}
+
+	// Com inputs, o primeiro arquivo é o principal e os demais são concatenados após ele.
+	if len(jobMessage.Inputs) > 0 {
+		if jobMessage.FilePath != "" {
+			return nil, fmt.Errorf("file_path and inputs cannot be used together")
+		}
+		jobMessage.FilePath = jobMessage.Inputs[0]
+	}
+
+	if clip := jobMessage.Clip(); clip != nil {
+		err = clip.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range jobMessage.AudioTracks {
+		err = jobMessage.AudioTracks[i].Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range jobMessage.Subtitles {
+		err = jobMessage.Subtitles[i].Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if jobMessage.Watermark != nil {
+		err = jobMessage.Watermark.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if jobMessage.Loudnorm != nil {
+		err = jobMessage.Loudnorm.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if jobMessage.Encryption != nil {
+		err = jobMessage.Encryption.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if jobMessage.Priority != nil && (*jobMessage.Priority < 0 || *jobMessage.Priority > queue.MaxPriority) {
+		return nil, fmt.Errorf("invalid priority: %d (must be between 0 and %d)", *jobMessage.Priority, queue.MaxPriority)
+	}
+
+	for _, tag := range jobMessage.Tags {
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag: %q", tag)
+		}
+	}
+
+	if jobMessage.Profile == "" {
+		jobMessage.Profile = DefaultProfile
+	}
+
+	if jobMessage.CorrelationID == "" {
+		jobMessage.CorrelationID = correlationIDFromHeaders(message)
+	}
+
+	if jobMessage.Priority == nil {
+		priority := int(message.Priority())
+		jobMessage.Priority = &priority
+	}
+
+	if jobMessage.TenantID == "" {
+		jobMessage.TenantID = tenantIDFromHeaders(message)
+	}
+
+	if !tenantIDPattern.MatchString(jobMessage.TenantID) {
+		return nil, fmt.Errorf("invalid tenant_id: %q", jobMessage.TenantID)
+	}
+
+	return &jobMessage, nil
+}
+
+/*
+Video cria um novo domain.Video a partir dos dados da mensagem,
+com um identificador próprio.
+*/
+func (m *JobMessage) Video() *domain.Video {
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = m.ResourceID
+	video.FilePath = m.FilePath
+	video.TenantID = m.TenantID
+	video.SetTags(m.Tags)
+
+	return video
+}
+
+// Clip retorna o corte pedido na mensagem, ou nil se start e end não forem informados.
+func (m *JobMessage) Clip() *Clip {
+	if m.Start == nil && m.End == nil {
+		return nil
+	}
+	return &Clip{Start: m.Start, End: m.End}
+}
+
+// ConcatInputs retorna as entradas concatenadas após o arquivo principal.
+func (m *JobMessage) ConcatInputs() []string {
+	if len(m.Inputs) < 2 {
+		return nil
+	}
+	return m.Inputs[1:]
+}
+
+/*
+correlationIDFromHeaders retorna o correlation ID informado no cabeçalho da mensagem
+ou gera um novo identificador quando ele não estiver presente.
+*/
+func correlationIDFromHeaders(message queue.Message) string {
+	if value, ok := message.Headers()[CorrelationIDHeader]; ok {
+		if id := fmt.Sprint(value); id != "" {
+			return id
+		}
+	}
+
+	return uuid.NewV4().String()
+}
+
+// requeuesFromHeaders lê o cabeçalho RequeuesHeader, retornando 0 quando ausente ou inválido.
+func requeuesFromHeaders(message queue.Message) int {
+	requeues, err := strconv.Atoi(fmt.Sprint(message.Headers()[RequeuesHeader]))
+	if err != nil || requeues < 0 {
+		return 0
+	}
+
+	return requeues
+}
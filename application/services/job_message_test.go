@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"errors"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestParseJobMessagePriority verifica que a prioridade do corpo prevalece sobre a
+prioridade AMQP da mensagem e que valores fora do intervalo são recusados.
+*/
+func TestParseJobMessagePriority(t *testing.T) {
+	broker := queue.NewMemoryBroker(4)
+
+	jobMessage, err := services.ParseJobMessage(broker.EnqueuePriority([]byte(`{"resource_id": "a", "file_path": "a.mp4", "priority": 8}`), nil, 2))
+	require.Nil(t, err)
+	require.Equal(t, 8, *jobMessage.Priority)
+
+	jobMessage, err = services.ParseJobMessage(broker.EnqueuePriority([]byte(`{"resource_id": "a", "file_path": "a.mp4"}`), nil, 2))
+	require.Nil(t, err)
+	require.Equal(t, 2, *jobMessage.Priority)
+
+	_, err = services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "priority": 11}`), nil))
+	require.Error(t, err)
+
+	_, err = services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "priority": -1}`), nil))
+	require.Error(t, err)
+}
+
+/*
+TestSubmitJobPriorityOrder verifica que a prioridade do corpo vira a prioridade AMQP da
+mensagem publicada e que a fila entrega primeiro os jobs mais urgentes.
+*/
+func TestSubmitJobPriorityOrder(t *testing.T) {
+	producer := queue.NewMemoryBroker(3)
+
+	for _, body := range []string{
+		`{"resource_id": "normal", "file_path": "a.mp4"}`,
+		`{"resource_id": "urgent", "file_path": "a.mp4", "priority": 9}`,
+		`{"resource_id": "high", "file_path": "a.mp4", "priority": 5}`,
+	} {
+		_, err := services.SubmitJob(producer, "", "videos", []byte(body), "")
+		require.Nil(t, err)
+	}
+
+	_, err := services.SubmitJob(producer, "", "videos", []byte(`{"resource_id": "x", "file_path": "a.mp4", "priority": 11}`), "")
+	require.Error(t, err)
+
+	// A fila de consumo recebe as mensagens na ordem de publicação, com a prioridade AMQP.
+	consumer := queue.NewMemoryBroker(3)
+	for _, published := range producer.Published() {
+		require.Equal(t, "videos", published.RoutingKey)
+		require.NotEmpty(t, published.Publishing.Headers[services.CorrelationIDHeader])
+		consumer.EnqueuePriority(published.Publishing.Body, published.Publishing.Headers, published.Publishing.Priority)
+	}
+	consumer.Close()
+
+	messageChannel := make(chan queue.Message)
+	consumer.Consume(messageChannel)
+
+	order := []string{}
+	for message := range messageChannel {
+		jobMessage, err := services.ParseJobMessage(message)
+		require.Nil(t, err)
+		order = append(order, jobMessage.ResourceID)
+	}
+
+	require.Equal(t, []string{"urgent", "high", "normal"}, order)
+}
+
+/*
+TestSubmitJobStampsTenant verifica que o tenant autenticado do produtor substitui o
+declarado no corpo e no cabeçalho da mensagem publicada.
+*/
+func TestSubmitJobStampsTenant(t *testing.T) {
+	producer := queue.NewMemoryBroker(0)
+
+	jobMessage, err := services.SubmitJob(producer, "", "videos", []byte(`{"resource_id": "x", "file_path": "a.mp4", "tenant_id": "other"}`), "news")
+	require.Nil(t, err)
+	require.Equal(t, "news", jobMessage.TenantID)
+
+	published := producer.Published()
+	require.Len(t, published, 1)
+	require.Equal(t, "news", published[0].Publishing.Headers[services.TenantIDHeader])
+
+	parsed, err := services.ParseJobMessage(producer.Enqueue(published[0].Publishing.Body, nil))
+	require.Nil(t, err)
+	require.Equal(t, "news", parsed.TenantID)
+	require.Equal(t, "x", parsed.ResourceID)
+
+	_, err = services.SubmitJob(producer, "", "videos", []byte(`["not", "an", "object"]`), "news")
+	require.True(t, errors.Is(err, services.ErrInvalidJobMessage))
+	require.Len(t, producer.Published(), 1)
+}
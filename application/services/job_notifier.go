@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/json"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/queue"
+	"os"
+	"strings"
+)
+
+/*
+JobNotifier publica os eventos do ciclo de vida dos jobs no broker.
+Cada tipo de evento é publicado com uma routing key própria, formada pelo
+prefixo RoutingKey e pelo nome do evento (ex.: jobs.completed, jobs.failed),
+permitindo que os assinantes filtrem apenas os eventos de interesse.
+Os eventos de um tenant com prefixo próprio em TenantRoutingKeys usam esse prefixo.
+A exchange deve ser do tipo topic (ex.: amq.topic) para que um assinante receba todos
+os eventos com um único binding (jobs.*, ou #.failed para as falhas de todos os tenants);
+com uma exchange direct, cada routing key precisa do seu próprio binding.
+*/
+type JobNotifier struct {
+	Publisher         queue.Publisher   // Broker utilizado para publicar os eventos
+	Exchange          string            // Exchange de notificação
+	RoutingKey        string            // Prefixo das routing keys dos eventos
+	TenantRoutingKeys map[string]string // Prefixos das routing keys dos eventos de cada tenant (opcional)
+}
+
+/*
+NewJobNotifier cria um JobNotifier com a exchange e o prefixo de routing key
+definidos nas variáveis RABBITMQ_NOTIFICATION_EX e RABBITMQ_NOTIFICATION_ROUTING_KEY,
+e com os prefixos dos tenants informados (ver LoadTenants).
+*/
+func NewJobNotifier(publisher queue.Publisher, tenants Tenants) *JobNotifier {
+	return &JobNotifier{
+		Publisher:         publisher,
+		Exchange:          os.Getenv("RABBITMQ_NOTIFICATION_EX"),
+		RoutingKey:        os.Getenv("RABBITMQ_NOTIFICATION_ROUTING_KEY"),
+		TenantRoutingKeys: tenants.routingKeys(),
+	}
+}
+
+// Notify serializa o evento em JSON e o publica com a routing key do seu tipo.
+func (n *JobNotifier) Notify(event *domain.JobEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return n.Publisher.Publish(n.Exchange, n.routingKeyFor(event), queue.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+		Headers: map[string]interface{}{
+			"schema_version":    event.SchemaVersion,
+			"event_type":        string(event.Type),
+			CorrelationIDHeader: event.CorrelationID,
+		},
+	})
+}
+
+/*
+routingKeyFor retorna a routing key de um evento.
+O prefixo "job." do tipo é substituído pelo prefixo do tenant do evento ou,
+se o tenant não tiver um, pelo prefixo configurado.
+*/
+func (n *JobNotifier) routingKeyFor(event *domain.JobEvent) string {
+	name := strings.TrimPrefix(string(event.Type), "job.")
+
+	prefix := n.RoutingKey
+	if key, ok := n.TenantRoutingKeys[event.TenantID]; ok {
+		prefix = key
+	}
+
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
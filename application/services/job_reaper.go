@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/queue"
+	"os"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// reaperLockName é o nome do lock que garante uma única instância do reaper em execução.
+const reaperLockName = "job-reaper"
+
+// reaperBatchSize limita os jobs tratados em cada execução do reaper.
+const reaperBatchSize = 100
+
+/*
+JobReaper encerra os jobs que ficaram em um status não final sem progredir: o worker
+travou (um ffmpeg que não avança, por exemplo) ou morreu. Um job é considerado travado
+quando o seu último heartbeat (ou, sem heartbeat, a sua última atualização) é mais
+antigo que StaleAfter; o heartbeat só é renovado enquanto o job progride.
+O job é marcado como FAILED (código JOB_STALLED) e um evento job.failed é publicado.
+A mensagem não é reenfileirada: se o worker morreu, o RabbitMQ já entrega novamente a
+mensagem não confirmada, e reenfileirá-la geraria um segundo encoding do mesmo vídeo.
+Um lock no banco garante que apenas uma instância execute o reaper por vez.
+*/
+type JobReaper struct {
+	JobRepository repositories.JobRepository
+	Locks         repositories.LockRepository
+	Notifier      *JobNotifier     // Publica os eventos dos jobs tratados
+	Webhooks      *WebhookNotifier // Entrega o evento job.failed na callback_url (opcional)
+	StaleAfter    time.Duration
+	Interval      time.Duration
+	Owner         string // Identificador desta instância no lock
+}
+
+/*
+NewJobReaper cria um JobReaper configurado pelas variáveis de ambiente
+REAPER_STALE_AFTER e REAPER_INTERVAL. Os eventos usam as routing keys dos tenants
+definidos em TENANTS, e os webhooks a configuração de NewWebhookNotifier.
+*/
+func NewJobReaper(db *gorm.DB, publisher queue.Publisher) (*JobReaper, error) {
+	staleAfter, err := time.ParseDuration(os.Getenv("REAPER_STALE_AFTER"))
+	if err != nil || staleAfter <= 0 {
+		staleAfter = 5 * time.Minute
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("REAPER_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	tenants, err := LoadTenants()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANTS: %w", err)
+	}
+
+	webhooks, err := NewWebhookNotifier(repositories.WebhookDeliveryRepositoryDb{Db: db})
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &JobReaper{
+		JobRepository: repositories.JobRepositoryDb{Db: db},
+		Locks:         repositories.LockRepositoryDb{Db: db},
+		Notifier:      NewJobNotifier(publisher, tenants),
+		Webhooks:      webhooks,
+		StaleAfter:    staleAfter,
+		Interval:      interval,
+		Owner:         hostname + "-" + uuid.NewV4().String(),
+	}, nil
+}
+
+// Run executa o reaper a cada Interval até que o contexto seja cancelado.
+func (r *JobReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		reaped, err := r.Reap()
+		if err != nil {
+			log.Printf("error reaping stalled jobs: %v", err)
+		} else if reaped > 0 {
+			log.Printf("%d stalled job(s) reaped", reaped)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Reap executa uma passagem do reaper, se esta instância obtiver o lock,
+e retorna a quantidade de jobs tratados. Os webhooks dos jobs que falharam são
+entregues depois que o lock é liberado: com as retentativas, a entrega pode levar
+mais que o TTL do lock (Interval) e permitir uma passagem concorrente.
+*/
+func (r *JobReaper) Reap() (int, error) {
+	reaped, webhooks, err := r.reap()
+
+	for _, webhook := range webhooks {
+		deliverErr := r.Webhooks.Deliver(webhook.job, webhook.event)
+		if deliverErr != nil {
+			log.Printf("error delivering webhook for job %v: %v", webhook.job.ID, deliverErr)
+		}
+	}
+
+	return reaped, err
+}
+
+// reaperWebhook é um evento job.failed a entregar na callback_url do job após a passagem do reaper.
+type reaperWebhook struct {
+	job   *domain.Job
+	event *domain.JobEvent
+}
+
+// reap trata os jobs travados com o lock e retorna os webhooks a entregar.
+func (r *JobReaper) reap() (int, []reaperWebhook, error) {
+	acquired, err := r.Locks.Acquire(reaperLockName, r.Owner, r.Interval)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !acquired {
+		return 0, nil, nil
+	}
+	defer r.Locks.Release(reaperLockName, r.Owner)
+
+	before := time.Now().Add(-r.StaleAfter)
+	jobs, err := r.JobRepository.FindStale(before, reaperBatchSize)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	reaped := 0
+	var webhooks []reaperWebhook
+	for _, job := range jobs {
+		event, err := r.reapJob(job, before)
+		if err != nil {
+			log.Printf("error reaping job %v: %v", job.ID, err)
+			continue
+		}
+
+		if event == nil {
+			continue
+		}
+		reaped++
+
+		if job.CallbackURL != "" && r.Webhooks != nil {
+			webhooks = append(webhooks, reaperWebhook{job: job, event: event})
+		}
+	}
+
+	return reaped, webhooks, nil
+}
+
+/*
+reapJob marca o job travado como FAILED e retorna o evento job.failed publicado. Se o job for atualizado ou enviar um heartbeat depois de before (o worker
+ainda está vivo), ele é ignorado e nenhum evento é retornado.
+*/
+func (r *JobReaper) reapJob(job *domain.Job, before time.Time) (*domain.JobEvent, error) {
+	lastSeen := job.UpdatedAt
+	if job.HeartbeatAt != nil {
+		lastSeen = *job.HeartbeatAt
+	}
+
+	job.Error = fmt.Sprintf("job stalled in %v: no progress since %v", job.Status, lastSeen.Format(time.RFC3339))
+	job.Status = domain.JobStatusFailed
+	job.ErrorCode = domain.ErrorCodeStalled
+
+	_, err := r.JobRepository.UpdateStale(job, before)
+	if errors.Is(err, repositories.ErrStaleVersion) {
+		log.Printf("job %v was updated or sent a heartbeat while being reaped, skipping", job.ID)
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	event := domain.NewJobEvent(domain.JobFailed, job)
+	event.Error = &domain.JobEventError{Code: job.ErrorCode, Message: job.Error}
+	r.notify(event, job)
+
+	return event, nil
+}
+
+// notify publica o evento do job tratado; falhas são apenas registradas em log.
+func (r *JobReaper) notify(event *domain.JobEvent, job *domain.Job) {
+	if r.Notifier == nil {
+		return
+	}
+
+	err := r.Notifier.Notify(event)
+	if err != nil {
+		log.Printf("error publishing event %v for job %v: %v", event.Type, job.ID, err)
+	}
+}
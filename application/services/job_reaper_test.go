@@ -0,0 +1,366 @@
+package services_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// insertReaperJob insere um job no status informado, com o último heartbeat em lastSeen.
+func insertReaperJob(t *testing.T, db *gorm.DB, status string, lastSeen time.Time, requeues int) *domain.Job {
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = "resource"
+	video.FilePath = "video.mp4"
+	_, err := repositories.VideoRepositoryDb{Db: db}.Insert(video)
+	require.Nil(t, err)
+
+	job, err := domain.NewJob("bucket", status, video)
+	require.Nil(t, err)
+	job.Message = `{"resource_id": "resource", "file_path": "video.mp4"}`
+	job.CorrelationID = "corr-" + job.ID
+	job.Requeues = requeues
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	_, err = jobs.Insert(job)
+	require.Nil(t, err)
+	require.Nil(t, jobs.Heartbeat(job.ID, lastSeen))
+
+	return job
+}
+
+func newTestReaper(db *gorm.DB, broker *queue.MemoryBroker) *services.JobReaper {
+	return &services.JobReaper{
+		JobRepository: repositories.JobRepositoryDb{Db: db},
+		Locks:         repositories.LockRepositoryDb{Db: db},
+		Notifier:      &services.JobNotifier{Publisher: broker, Exchange: "ex", RoutingKey: "jobs"},
+		StaleAfter:    time.Minute,
+		Interval:      time.Minute,
+		Owner:         "test",
+	}
+}
+
+/*
+TestJobReaperFailsStalledJobs verifica que apenas os jobs não finalizados sem
+heartbeat recente são marcados como FAILED e notificados, sem reenfileirar a mensagem.
+*/
+func TestJobReaperFailsStalledJobs(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	old := time.Now().Add(-time.Hour)
+	stalled := insertReaperJob(t, db, domain.JobStatusEncoding, old, 0)
+	running := insertReaperJob(t, db, domain.JobStatusEncoding, time.Now(), 0)
+	completed := insertReaperJob(t, db, domain.JobStatusCompleted, old, 0)
+
+	broker := queue.NewMemoryBroker(0)
+	reaper := newTestReaper(db, broker)
+
+	reaped, err := reaper.Reap()
+	require.Nil(t, err)
+	require.Equal(t, 1, reaped)
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	job, err := jobs.Find(stalled.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusFailed, job.Status)
+	require.Equal(t, domain.ErrorCodeStalled, job.ErrorCode)
+	require.Contains(t, job.Error, "job stalled in ENCODING")
+
+	job, err = jobs.Find(running.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusEncoding, job.Status)
+
+	job, err = jobs.Find(completed.ID)
+	require.Nil(t, err)
+	require.Empty(t, job.ErrorCode)
+
+	published := broker.Published()
+	require.Len(t, published, 1)
+	require.Equal(t, "jobs.failed", published[0].RoutingKey)
+
+	var event domain.JobEvent
+	require.Nil(t, json.Unmarshal(published[0].Publishing.Body, &event))
+	require.Equal(t, stalled.ID, event.JobID)
+	require.Equal(t, domain.ErrorCodeStalled, event.Error.Code)
+
+	// Os jobs já tratados não são tratados de novo.
+	reaped, err = reaper.Reap()
+	require.Nil(t, err)
+	require.Equal(t, 0, reaped)
+}
+
+/*
+TestJobReaperRequiresLock verifica que o reaper não executa enquanto outra
+instância detém o lock.
+*/
+func TestJobReaperRequiresLock(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	insertReaperJob(t, db, domain.JobStatusEncoding, time.Now().Add(-time.Hour), 0)
+
+	acquired, err := repositories.LockRepositoryDb{Db: db}.Acquire("job-reaper", "other", time.Minute)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	broker := queue.NewMemoryBroker(0)
+	reaped, err := newTestReaper(db, broker).Reap()
+	require.Nil(t, err)
+	require.Equal(t, 0, reaped)
+	require.Empty(t, broker.Published())
+}
+
+// revivingJobRepository simula um worker que volta a enviar heartbeats logo após a busca dos jobs travados.
+type revivingJobRepository struct {
+	repositories.JobRepository
+}
+
+func (r revivingJobRepository) FindStale(before time.Time, limit int) ([]*domain.Job, error) {
+	jobs, err := r.JobRepository.FindStale(before, limit)
+	for _, job := range jobs {
+		r.JobRepository.Heartbeat(job.ID, time.Now())
+	}
+
+	return jobs, err
+}
+
+/*
+TestJobReaperSkipsRevivedJobs verifica que um job que envia um heartbeat durante a
+passagem do reaper não é marcado como FAILED, embora a versão não tenha mudado.
+*/
+func TestJobReaperSkipsRevivedJobs(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	revived := insertReaperJob(t, db, domain.JobStatusEncoding, time.Now().Add(-time.Hour), 0)
+
+	broker := queue.NewMemoryBroker(0)
+	reaper := newTestReaper(db, broker)
+	reaper.JobRepository = revivingJobRepository{JobRepository: repositories.JobRepositoryDb{Db: db}}
+
+	reaped, err := reaper.Reap()
+	require.Nil(t, err)
+	require.Equal(t, 0, reaped)
+	require.Empty(t, broker.Published())
+
+	job, err := repositories.JobRepositoryDb{Db: db}.Find(revived.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusEncoding, job.Status)
+	require.Equal(t, revived.Version, job.Version)
+}
+
+// trackingLockRepository registra se o lock do reaper está com esta instância.
+type trackingLockRepository struct {
+	repositories.LockRepository
+	held *int32
+}
+
+func (l trackingLockRepository) Acquire(name string, owner string, ttl time.Duration) (bool, error) {
+	acquired, err := l.LockRepository.Acquire(name, owner, ttl)
+	if acquired {
+		atomic.StoreInt32(l.held, 1)
+	}
+
+	return acquired, err
+}
+
+func (l trackingLockRepository) Release(name string, owner string) error {
+	atomic.StoreInt32(l.held, 0)
+	return l.LockRepository.Release(name, owner)
+}
+
+/*
+TestJobReaperDeliversWebhooksAfterLock verifica que o webhook job.failed é entregue
+depois que o lock é liberado, para que as retentativas não excedam o TTL do lock.
+*/
+func TestJobReaperDeliversWebhooksAfterLock(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	var held, calls, heldDuringDelivery int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&heldDuringDelivery, atomic.LoadInt32(&held))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	stalled := insertReaperJob(t, db, domain.JobStatusEncoding, time.Now().Add(-time.Hour), 0)
+	stalled.CallbackURL = server.URL
+	_, err := jobs.Update(stalled)
+	require.Nil(t, err)
+
+	setEnv(t, "ALLOW_PRIVATE_TARGETS", "true")
+	setEnv(t, "WEBHOOK_SECRET", "secret")
+	broker := queue.NewMemoryBroker(0)
+	reaper := newTestReaper(db, broker)
+	reaper.Locks = trackingLockRepository{LockRepository: repositories.LockRepositoryDb{Db: db}, held: &held}
+	reaper.Webhooks, err = services.NewWebhookNotifier(repositories.WebhookDeliveryRepositoryDb{Db: db})
+	require.Nil(t, err)
+
+	reaped, err := reaper.Reap()
+	require.Nil(t, err)
+	require.Equal(t, 1, reaped)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	require.Equal(t, int32(0), atomic.LoadInt32(&heldDuringDelivery))
+}
+
+/*
+TestNewJobReaperUsesTenants verifica que o reaper publica os eventos com as routing keys
+dos tenants e que uma configuração de tenants inválida é informada.
+*/
+func TestNewJobReaperUsesTenants(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	broker := queue.NewMemoryBroker(0)
+
+	setEnv(t, "TENANTS", `[{"id": "news", "routing_key": "news.jobs"}]`)
+	reaper, err := services.NewJobReaper(db, broker)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"news": "news.jobs"}, reaper.Notifier.TenantRoutingKeys)
+
+	setEnv(t, "TENANTS", `[{"id": "news"}, {"id": "news"}]`)
+	_, err = services.NewJobReaper(db, broker)
+	require.NotNil(t, err)
+}
+
+/*
+TestJobManagerRecordsHeartbeat verifica que o worker registra a mensagem original,
+os reenfileiramentos, a prioridade e o heartbeat do job.
+*/
+func TestJobManagerRecordsHeartbeat(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "CONCURRENCY_WORKERS", "1")
+	setEnv(t, "CONCURRENCY_UPLOAD", "1")
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	client.Put(os.Getenv("inputBucketName"), "heartbeat.mp4", fakeMp4("heartbeat"))
+
+	body := `{"resource_id": "heartbeat", "file_path": "heartbeat.mp4"}`
+	broker := queue.NewMemoryBroker(1)
+	message := broker.EnqueuePriority([]byte(body), map[string]interface{}{services.RequeuesHeader: int32(1)}, 7)
+	broker.Close()
+
+	messageChannel := make(chan queue.Message)
+	broker.Consume(messageChannel)
+
+	jobManager := services.NewJobManager(db, broker, make(chan services.JobWorkerResult), messageChannel)
+	jobManager.Storage = client
+	jobManager.Runner = fakeRunner{}
+	jobManager.Heartbeat = time.Millisecond
+	jobManager.Start()
+	require.True(t, message.Acked())
+
+	jobs, err := repositories.JobRepositoryDb{Db: db}.FindByResourceID("heartbeat")
+	require.Nil(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, domain.JobStatusCompleted, jobs[0].Status)
+	require.Equal(t, body, jobs[0].Message)
+	require.Equal(t, 1, jobs[0].Requeues)
+	require.Equal(t, 7, jobs[0].Priority)
+	require.NotNil(t, jobs[0].HeartbeatAt)
+}
+
+// blockingRunner simula uma ferramenta travada: o mp4fragment só termina quando release é fechado.
+type blockingRunner struct {
+	fakeRunner
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r blockingRunner) Run(name string, args ...string) ([]byte, error) {
+	if name == "mp4fragment" {
+		close(r.started)
+		<-r.release
+	}
+
+	return r.fakeRunner.Run(name, args...)
+}
+
+/*
+TestJobManagerHeartbeatFollowsProgress verifica que o heartbeat deixa de ser renovado
+enquanto uma ferramenta externa não progride, para que o reaper identifique o job travado.
+*/
+func TestJobManagerHeartbeatFollowsProgress(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "CONCURRENCY_WORKERS", "1")
+	setEnv(t, "CONCURRENCY_UPLOAD", "1")
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	client.Put(os.Getenv("inputBucketName"), "hung.mp4", fakeMp4("hung"))
+
+	broker := queue.NewMemoryBroker(1)
+	message := broker.Enqueue([]byte(`{"resource_id": "hung", "file_path": "hung.mp4"}`), nil)
+	broker.Close()
+
+	messageChannel := make(chan queue.Message)
+	broker.Consume(messageChannel)
+
+	runner := blockingRunner{started: make(chan struct{}), release: make(chan struct{})}
+
+	jobManager := services.NewJobManager(db, broker, make(chan services.JobWorkerResult), messageChannel)
+	jobManager.Storage = client
+	jobManager.Runner = runner
+	jobManager.Heartbeat = time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobManager.Start()
+	}()
+
+	<-runner.started
+	hungAt := time.Now()
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	heartbeat := func() time.Time {
+		found, err := jobs.FindByResourceID("hung")
+		require.Nil(t, err)
+		require.Len(t, found, 1)
+		require.NotNil(t, found[0].HeartbeatAt)
+		return *found[0].HeartbeatAt
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	first := heartbeat()
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, first.Equal(heartbeat()))
+	require.False(t, first.After(hungAt))
+
+	close(runner.release)
+	<-done
+	require.True(t, message.Acked())
+	require.True(t, heartbeat().After(hungAt))
+}
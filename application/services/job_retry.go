@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/queue"
+)
+
+// ErrJobNotRetryable indica que o job não falhou ou não guardou a mensagem original.
+var ErrJobNotRetryable = errors.New("job cannot be retried")
+
+/*
+RetryJob reenfileira a mensagem original de um job que falhou, com o mesmo correlation ID.
+O job original é mantido como FAILED; a mensagem reenfileirada cria um novo job.
+Jobs criados antes do registro da mensagem original não podem ser reenfileirados.
+*/
+func RetryJob(jobs repositories.JobRepository, publisher queue.Publisher, exchange string, routingKey string, id string) (*domain.Job, error) {
+	job, err := jobs.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != domain.JobStatusFailed {
+		return nil, fmt.Errorf("%w: job %v is %v", ErrJobNotRetryable, job.ID, job.Status)
+	}
+
+	if job.Message == "" {
+		return nil, fmt.Errorf("%w: job %v has no original message", ErrJobNotRetryable, job.ID)
+	}
+
+	err = republishJob(publisher, exchange, routingKey, job, job.Requeues)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+/*
+republishJob publica novamente a mensagem original do job, com a prioridade do job,
+informando o correlation ID, os reenfileiramentos e o tenant, que pode ter vindo apenas
+do cabeçalho da mensagem original.
+*/
+func republishJob(publisher queue.Publisher, exchange string, routingKey string, job *domain.Job, requeues int) error {
+	return publisher.Publish(exchange, routingKey, queue.Publishing{
+		ContentType: "application/json",
+		Body:        []byte(job.Message),
+		Headers: map[string]interface{}{
+			CorrelationIDHeader: job.CorrelationID,
+			RequeuesHeader:      requeues,
+			TenantIDHeader:      job.TenantID,
+		},
+		Priority: uint8(job.Priority),
+	})
+}
@@ -0,0 +1,78 @@
+package services_test
+
+import (
+	"errors"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetryJob verifica que apenas jobs que falharam têm a mensagem original reenfileirada.
+func TestRetryJob(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	failed := insertReaperJob(t, db, domain.JobStatusFailed, time.Now(), 1)
+	failed.Priority = 9
+	_, err := jobs.Update(failed)
+	require.Nil(t, err)
+	running := insertReaperJob(t, db, domain.JobStatusEncoding, time.Now(), 0)
+
+	broker := queue.NewMemoryBroker(0)
+
+	job, err := services.RetryJob(jobs, broker, "", "videos", failed.ID)
+	require.Nil(t, err)
+	require.Equal(t, failed.ID, job.ID)
+
+	published := broker.Published()
+	require.Len(t, published, 1)
+	require.Equal(t, "videos", published[0].RoutingKey)
+	require.Equal(t, failed.Message, string(published[0].Publishing.Body))
+	require.Equal(t, failed.CorrelationID, published[0].Publishing.Headers[services.CorrelationIDHeader])
+	require.Equal(t, 1, published[0].Publishing.Headers[services.RequeuesHeader])
+	require.Equal(t, uint8(9), published[0].Publishing.Priority)
+
+	_, err = services.RetryJob(jobs, broker, "", "videos", running.ID)
+	require.True(t, errors.Is(err, services.ErrJobNotRetryable))
+
+	_, err = services.RetryJob(jobs, broker, "", "videos", "00000000-0000-0000-0000-000000000000")
+	require.True(t, errors.Is(err, repositories.ErrNotFound))
+
+	require.Len(t, broker.Published(), 1)
+}
+
+/*
+TestRetryJobKeepsTenant verifica que a mensagem reenfileirada mantém o tenant do job
+quando ele foi informado apenas no cabeçalho da mensagem original.
+*/
+func TestRetryJobKeepsTenant(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	failed := insertReaperJob(t, db, domain.JobStatusFailed, time.Now(), 0)
+	failed.TenantID = "news"
+	_, err := jobs.Update(failed)
+	require.Nil(t, err)
+
+	broker := queue.NewMemoryBroker(0)
+
+	_, err = services.RetryJob(jobs, broker, "", "videos", failed.ID)
+	require.Nil(t, err)
+
+	published := broker.Published()
+	require.Len(t, published, 1)
+	require.Equal(t, "news", published[0].Publishing.Headers[services.TenantIDHeader])
+
+	message := broker.Enqueue(published[0].Publishing.Body, published[0].Publishing.Headers)
+	jobMessage, err := services.ParseJobMessage(message)
+	require.Nil(t, err)
+	require.Equal(t, "news", jobMessage.TenantID)
+}
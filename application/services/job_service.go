@@ -1,31 +1,74 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
 	"microsservico-encoder/application/repositories"
 	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/storage"
 	"os"
 	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 type JobService struct {
-	Job           *domain.Job
-	JobRepository repositories.JobRepository
-	VideoService  VideoService
+	Job               *domain.Job
+	JobRepository     repositories.JobRepository
+	VideoService      VideoService
+	Notifier          *JobNotifier  // Publica os eventos do ciclo de vida do job (opcional)
+	HeartbeatInterval time.Duration // Intervalo entre os heartbeats do job em processamento (0 desativa)
+	Tenants           Tenants       // Tenants configurados, com as cotas e o isolamento de cada um
+
+	lastProgress atomic.Int64 // Momento (UnixNano) da última atividade do job, registrado pelo heartbeat
+}
+
+// maxJobUpdateAttempts limita as releituras do Job após conflitos de versão.
+const maxJobUpdateAttempts = 3
+
+// ErrJobFinalized indica que outro processo finalizou o Job (por exemplo, um cancelamento) durante o processamento.
+var ErrJobFinalized = errors.New("job was finalized by another process")
+
+// jobProgress define o percentual de conclusão associado a cada status do job.
+var jobProgress = map[string]int{
+	domain.JobStatusStarting:    0,
+	domain.JobStatusDownloading: 10,
+	domain.JobStatusConverting:  20,
+	domain.JobStatusFragmenting: 30,
+	domain.JobStatusEncoding:    50,
+	domain.JobStatusUploading:   70,
+	domain.JobStatusFinishing:   90,
+	domain.JobStatusCompleted:   100,
+}
+
+// jobErrorCodes define o código de erro de uma falha ocorrida em cada status do job.
+var jobErrorCodes = map[string]string{
+	domain.JobStatusStarting:    domain.ErrorCodeInternal,
+	domain.JobStatusDownloading: domain.ErrorCodeDownload,
+	domain.JobStatusConverting:  domain.ErrorCodeConvert,
+	domain.JobStatusFragmenting: domain.ErrorCodeFragment,
+	domain.JobStatusEncoding:    domain.ErrorCodeEncode,
+	domain.JobStatusUploading:   domain.ErrorCodeUpload,
+	domain.JobStatusFinishing:   domain.ErrorCodeFinish,
 }
 
 /*
 Start inicia o processamento do Job. Segue as etapas:
-1. Atualiza status para "DOWNLOADING" e faz o download do vídeo.
-2. Atualiza status para "FRAGMENTING" e fragmenta o vídeo.
-3. Atualiza status para "ENCODING" e codifica o vídeo.
-4. Realiza o upload e atualiza o status para "UPLOADING".
-5. Finaliza o processamento e atualiza status para "COMPLETED".
+1. Atualiza status para "DOWNLOADING" e faz o download do vídeo, das entradas concatenadas,
+dos áudios alternativos, das legendas e da marca d'água.
+2. Atualiza status para "CONVERTING" e converte a entrada para MP4, se necessário,
+concatenando as entradas e aplicando o corte, a normalização de loudness e a marca d'água.
+3. Atualiza status para "FRAGMENTING" e fragmenta o vídeo.
+4. Atualiza status para "ENCODING" e codifica o vídeo.
+5. Realiza o upload e atualiza o status para "UPLOADING".
+6. Finaliza o processamento e atualiza status para "COMPLETED".
 Se qualquer etapa falhar, o job é marcado como "FAILED".
 */
 func (j *JobService) Start() error {
 
-	err := j.changeJobStatus("DOWNLOADING")
+	err := j.changeJobStatus(domain.JobStatusDownloading)
 
 	if err != nil {
 		return j.failJob(err)
@@ -37,7 +80,56 @@ func (j *JobService) Start() error {
 		return j.failJob(err)
 	}
 
-	err = j.changeJobStatus("FRAGMENTING")
+	err = j.VideoService.DownloadInputs(os.Getenv("inputBucketName"))
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	err = j.VideoService.DownloadAudioTracks(os.Getenv("inputBucketName"))
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	err = j.VideoService.DownloadSubtitles(os.Getenv("inputBucketName"))
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	err = j.VideoService.DownloadWatermark(os.Getenv("inputBucketName"))
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	j.Job.SourceContainer = j.VideoService.SourceContainer
+
+	err = j.changeJobStatus(domain.JobStatusConverting)
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	err = j.VideoService.Convert()
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	if j.VideoService.Loudnorm != nil {
+		err = j.VideoService.UpdateVideo()
+
+		if err != nil {
+			return j.failJob(err)
+		}
+	}
+
+	j.Job.Conversion = j.VideoService.Conversion
+	j.Job.DurationSeconds = j.VideoService.Duration
+
+	err = j.changeJobStatus(domain.JobStatusFragmenting)
 
 	if err != nil {
 		return j.failJob(err)
@@ -49,7 +141,7 @@ func (j *JobService) Start() error {
 		return j.failJob(err)
 	}
 
-	err = j.changeJobStatus("ENCODING")
+	err = j.changeJobStatus(domain.JobStatusEncoding)
 
 	if err != nil {
 		return j.failJob(err)
@@ -61,13 +153,16 @@ func (j *JobService) Start() error {
 		return j.failJob(err)
 	}
 
+	// Apenas o KID é registrado no job; a chave de conteúdo nunca é persistida.
+	j.Job.KeyID = j.VideoService.KeyID
+
 	err = j.performUpload()
 
 	if err != nil {
 		return j.failJob(err)
 	}
 
-	err = j.changeJobStatus("FINISHING")
+	err = j.changeJobStatus(domain.JobStatusFinishing)
 
 	if err != nil {
 		return j.failJob(err)
@@ -79,7 +174,7 @@ func (j *JobService) Start() error {
 		return j.failJob(err)
 	}
 
-	err = j.changeJobStatus("COMPLETED")
+	err = j.changeJobStatus(domain.JobStatusCompleted)
 
 	if err != nil {
 		return j.failJob(err)
@@ -90,62 +185,118 @@ func (j *JobService) Start() error {
 
 /*
 performUpload executa o upload do vídeo fragmentado para o bucket de saída.
-Atualiza o status do Job para "UPLOADING" e aguarda o canal `doneUpload` para validar a conclusão.
-Se o resultado não for "upload completed", o Job é marcado como "FAILED".
+Atualiza o status do Job para "UPLOADING" e aguarda o fim do upload de todos os arquivos.
+Se algum arquivo falhar após todas as tentativas, o Job é marcado como "FAILED".
 */
 func (j *JobService) performUpload() error {
 
-	err := j.changeJobStatus("UPLOADING")
+	err := j.changeJobStatus(domain.JobStatusUploading)
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	acl, err := storage.ParseACLPolicy(os.Getenv("UPLOAD_ACL"))
 
 	if err != nil {
 		return j.failJob(err)
 	}
 
 	videoUpload := NewVideoUpload()
-	videoUpload.OutputBucket = os.Getenv("outputBucketName")
+	videoUpload.OutputBucket = j.Job.OutputBucket
+	videoUpload.ObjectPrefix = j.Job.OutputBucketPath
+	videoUpload.ACL = acl
 	videoUpload.VideoPath = os.Getenv("localStoragePath") + "/" + j.VideoService.Video.ID
+	videoUpload.Client = j.VideoService.Storage
+	videoUpload.Progress = j.VideoService.Progress
 	concurrency, _ := strconv.Atoi(os.Getenv("CONCURRENCY_UPLOAD"))
-	doneUpload := make(chan string)
 
-	go videoUpload.ProcessUpload(concurrency, doneUpload)
+	// O upload é interrompido junto com o job, como os downloads.
+	ctx := j.VideoService.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	var uploadResult string
-	uploadResult = <-doneUpload
+	uploadResult, err := videoUpload.ProcessUpload(ctx, concurrency)
 
-	if uploadResult != "upload completed" {
-		return j.failJob(errors.New(uploadResult))
+	if err != nil {
+		return j.failJob(err)
 	}
 
-	return err
+	log.Printf("video %v uploaded: %v files, %v bytes in %v (%v failed)",
+		j.VideoService.Video.ID, len(uploadResult.Uploaded), uploadResult.Bytes, uploadResult.Duration, len(uploadResult.Failed))
+
+	err = uploadResult.Err()
+
+	if err != nil {
+		return j.failJob(err)
+	}
+
+	return nil
 }
 
 /*
-changeJobStatus atualiza o status do Job no banco de dados para o valor informado.
+changeJobStatus atualiza o status do Job no banco de dados para o valor informado
+e publica os eventos job.stage_changed e job.progress.
 Se ocorrer erro ao atualizar, o Job é marcado como "FAILED".
 */
 func (j *JobService) changeJobStatus(status string) error {
 	var err error
 
+	previousStatus := j.Job.Status
 	j.Job.Status = status
-	j.Job, err = j.JobRepository.Update(j.Job)
+	err = j.updateJob()
+
+	if errors.Is(err, ErrJobFinalized) {
+		return err
+	}
 
 	if err != nil {
+		j.Job.ErrorCode = domain.ErrorCodePersistence
 		return j.failJob(err)
 	}
+	j.markProgress()
+
+	stageChanged := domain.NewJobEvent(domain.JobStageChanged, j.Job)
+	stageChanged.PreviousStatus = previousStatus
+	j.notify(stageChanged)
+
+	if progress, ok := jobProgress[status]; ok {
+		progressEvent := domain.NewJobEvent(domain.JobProgress, j.Job)
+		progressEvent.Progress = &progress
+		j.notify(progressEvent)
+	}
 
 	return nil
 }
 
 /*
-failJob marca o Job como "FAILED" e registra a mensagem de erro.
+failJob marca o Job como "FAILED" e registra a mensagem e o código do erro,
+definido a partir da etapa em que a falha ocorreu.
 A atualização é salva no banco de dados. Retorna o erro original.
+Se o Job já foi finalizado por outro processo, o status do banco é mantido.
 */
 func (j *JobService) failJob(error error) error {
 
-	j.Job.Status = "FAILED"
+	if errors.Is(error, ErrJobFinalized) {
+		return error
+	}
+
+	if j.Job.ErrorCode == "" {
+		j.Job.ErrorCode = domain.ErrorCodeInternal
+		if code, ok := jobErrorCodes[j.Job.Status]; ok {
+			j.Job.ErrorCode = code
+		}
+	}
+
+	j.Job.Status = domain.JobStatusFailed
 	j.Job.Error = error.Error()
 
-	_, err := j.JobRepository.Update(j.Job)
+	err := j.updateJob()
+
+	if errors.Is(err, ErrJobFinalized) {
+		return error
+	}
 
 	if err != nil {
 		return err
@@ -153,3 +304,115 @@ func (j *JobService) failJob(error error) error {
 
 	return error
 }
+
+/*
+updateJob salva o Job com controle de concorrência otimista. Em um conflito de versão,
+o Job é relido do banco e reconciliado:
+  - se outro processo o finalizou (COMPLETED ou FAILED), a versão do banco prevalece,
+    j.Job passa a refletir o banco e ErrJobFinalized é retornado para interromper o processamento;
+  - caso contrário, o worker é o responsável pelo andamento do Job e a gravação é
+    repetida sobre a versão atual.
+*/
+func (j *JobService) updateJob() error {
+
+	for attempt := 1; ; attempt++ {
+		_, err := j.JobRepository.Update(j.Job)
+
+		if !errors.Is(err, repositories.ErrStaleVersion) || attempt == maxJobUpdateAttempts {
+			return err
+		}
+
+		stored, findErr := j.JobRepository.Find(j.Job.ID)
+		if findErr != nil {
+			return findErr
+		}
+
+		if stored.Finished() {
+			log.Printf("job %v was finalized by another process with status %v", stored.ID, stored.Status)
+			stored.Video = j.Job.Video
+			j.Job = stored
+			return fmt.Errorf("%w: status %v", ErrJobFinalized, stored.Status)
+		}
+
+		log.Printf("job %v was updated by another process, retrying with version %d", j.Job.ID, stored.Version)
+		j.Job.Version = stored.Version
+	}
+}
+
+/*
+notify publica um evento do ciclo de vida do job, se houver um Notifier configurado.
+Falhas na publicação são apenas registradas em log para não interromper o processamento.
+*/
+func (j *JobService) notify(event *domain.JobEvent) {
+	if j.Notifier == nil {
+		return
+	}
+
+	err := j.Notifier.Notify(event)
+	if err != nil {
+		log.Printf("error publishing event %v for job %v: %v", event.Type, event.JobID, err)
+	}
+}
+
+/*
+markProgress registra que o job avançou: mudanças de status, bytes baixados, saída das
+ferramentas externas ou objetos enviados. É seguro chamá-lo de várias goroutines.
+*/
+func (j *JobService) markProgress() {
+	j.lastProgress.Store(time.Now().UnixNano())
+}
+
+/*
+startHeartbeat registra periodicamente o sinal de vida do job enquanto ele é processado,
+permitindo que o JobReaper identifique jobs travados. O heartbeat acompanha o progresso
+do job (ver markProgress), e não apenas o processo: a cada intervalo, o momento da última
+atividade é registrado se tiver mudado. Assim, um ffmpeg travado deixa de renovar o
+heartbeat e o job é tratado pelo reaper depois de REAPER_STALE_AFTER. Se não houver
+outro, markProgress é usado como o Progress do VideoService.
+Retorna a função que interrompe os heartbeats e aguarda o último terminar.
+*/
+func (j *JobService) startHeartbeat() func() {
+	if j.HeartbeatInterval <= 0 {
+		return func() {}
+	}
+
+	jobID := j.Job.ID
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	j.markProgress()
+	if j.VideoService.Progress == nil {
+		j.VideoService.Progress = j.markProgress
+	}
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(j.HeartbeatInterval)
+		defer ticker.Stop()
+
+		var recorded int64
+		for {
+			lastProgress := j.lastProgress.Load()
+			if lastProgress != recorded {
+				err := j.JobRepository.Heartbeat(jobID, time.Unix(0, lastProgress))
+				if err != nil {
+					log.Printf("error recording heartbeat of job %v: %v", jobID, err)
+				} else {
+					recorded = lastProgress
+				}
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
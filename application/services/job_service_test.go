@@ -0,0 +1,224 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestJobServicePublishesStageEvents verifica que cada mudança de status publica
+os eventos job.stage_changed e job.progress e que uma falha no download
+marca o job como FAILED com o código DOWNLOAD_FAILED.
+*/
+func TestJobServicePublishesStageEvents(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = "resource"
+	video.FilePath = "missing-" + uuid.NewV4().String() + ".mp4"
+	video.CreatedAt = time.Now()
+
+	videoRepository := repositories.VideoRepositoryDb{Db: db}
+	_, err := videoRepository.Insert(video)
+	require.Nil(t, err)
+
+	job, err := domain.NewJob("bucket", domain.JobStatusStarting, video)
+	require.Nil(t, err)
+	job.CorrelationID = "corr-1"
+
+	jobRepository := repositories.JobRepositoryDb{Db: db}
+	_, err = jobRepository.Insert(job)
+	require.Nil(t, err)
+
+	broker := queue.NewMemoryBroker(0)
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = videoRepository
+
+	jobService := services.JobService{
+		Job:           job,
+		JobRepository: jobRepository,
+		VideoService:  videoService,
+		Notifier:      &services.JobNotifier{Publisher: broker, Exchange: "ex", RoutingKey: "jobs"},
+	}
+
+	err = jobService.Start()
+	require.Error(t, err)
+	require.Equal(t, domain.JobStatusFailed, job.Status)
+	require.Equal(t, domain.ErrorCodeDownload, job.ErrorCode)
+
+	published := broker.Published()
+	require.Len(t, published, 2)
+	require.Equal(t, "jobs.stage_changed", published[0].RoutingKey)
+	require.Equal(t, "jobs.progress", published[1].RoutingKey)
+
+	var stageChanged domain.JobEvent
+	require.Nil(t, json.Unmarshal(published[0].Publishing.Body, &stageChanged))
+	require.Equal(t, domain.JobStatusDownloading, stageChanged.Status)
+	require.Equal(t, domain.JobStatusStarting, stageChanged.PreviousStatus)
+	require.Equal(t, "corr-1", stageChanged.CorrelationID)
+	require.Equal(t, video.ResourceID, stageChanged.ResourceID)
+
+	var progress domain.JobEvent
+	require.Nil(t, json.Unmarshal(published[1].Publishing.Body, &progress))
+	require.Equal(t, 10, *progress.Progress)
+}
+
+/*
+TestJobServiceReconcilesConcurrentUpdates verifica que, em um conflito de versão,
+o job é relido: um job finalizado por outro processo (ex.: cancelado) não é
+sobrescrito, e uma alteração não final é reconciliada e o processamento continua.
+*/
+func TestJobServiceReconcilesConcurrentUpdates(t *testing.T) {
+	store := repositories.NewMemoryStore()
+	videoRepository := repositories.VideoRepositoryMemory{Store: store}
+	jobRepository := repositories.JobRepositoryMemory{Store: store}
+
+	newJobService := func() (*services.JobService, *queue.MemoryBroker) {
+		video := domain.NewVideo()
+		video.ResourceID = "resource"
+		video.FilePath = "missing-" + uuid.NewV4().String() + ".mp4"
+		_, err := videoRepository.Insert(video)
+		require.Nil(t, err)
+
+		job, err := domain.NewJob("bucket", domain.JobStatusStarting, video)
+		require.Nil(t, err)
+		_, err = jobRepository.Insert(job)
+		require.Nil(t, err)
+
+		videoService := services.NewVideoService()
+		videoService.Video = video
+		videoService.VideoRepository = videoRepository
+
+		broker := queue.NewMemoryBroker(0)
+		return &services.JobService{
+			Job:           job,
+			JobRepository: jobRepository,
+			VideoService:  videoService,
+			Notifier:      &services.JobNotifier{Publisher: broker, Exchange: "ex", RoutingKey: "jobs"},
+		}, broker
+	}
+
+	// Outro processo cancela o job antes do worker gravar a próxima etapa.
+	jobService, broker := newJobService()
+	cancelled, err := jobRepository.Find(jobService.Job.ID)
+	require.Nil(t, err)
+	cancelled.Status = domain.JobStatusFailed
+	cancelled.Error = "cancelled"
+	_, err = jobRepository.Update(cancelled)
+	require.Nil(t, err)
+
+	err = jobService.Start()
+	require.True(t, errors.Is(err, services.ErrJobFinalized))
+	require.Equal(t, "cancelled", jobService.Job.Error)
+	require.Empty(t, broker.Published())
+
+	stored, err := jobRepository.Find(jobService.Job.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusFailed, stored.Status)
+	require.Equal(t, "cancelled", stored.Error)
+
+	// Outro processo altera o job sem finalizá-lo: o worker continua a partir da nova versão.
+	jobService, _ = newJobService()
+	concurrent, err := jobRepository.Find(jobService.Job.ID)
+	require.Nil(t, err)
+	_, err = jobRepository.Update(concurrent)
+	require.Nil(t, err)
+
+	err = jobService.Start()
+	require.Error(t, err)
+	require.False(t, errors.Is(err, services.ErrJobFinalized))
+	require.Equal(t, domain.ErrorCodeDownload, jobService.Job.ErrorCode)
+
+	stored, err = jobRepository.Find(jobService.Job.ID)
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusFailed, stored.Status)
+	require.Equal(t, domain.ErrorCodeDownload, stored.ErrorCode)
+	require.Equal(t, 4, stored.Version)
+}
+
+// jobContextKey identifica o contexto do job nos testes.
+type jobContextKey struct{}
+
+// jobContextClient recusa os uploads feitos fora do contexto do job.
+type jobContextClient struct {
+	*storage.MemoryClient
+}
+
+func (c jobContextClient) Upload(ctx context.Context, bucket string, object string, r io.Reader, acl storage.ACLPolicy) (int64, error) {
+	if ctx.Value(jobContextKey{}) == nil {
+		return 0, errors.New("upload outside the job context")
+	}
+
+	return c.MemoryClient.Upload(ctx, bucket, object, r, acl)
+}
+
+// TestJobServiceUploadsWithJobContext verifica que o upload usa o contexto do job, e não um contexto novo.
+func TestJobServiceUploadsWithJobContext(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "CONCURRENCY_UPLOAD", "1")
+	setEnv(t, "UPLOAD_MAX_ATTEMPTS", "1")
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = "resource"
+	video.FilePath = "context.mp4"
+	video.CreatedAt = time.Now()
+
+	videoRepository := repositories.VideoRepositoryDb{Db: db}
+	_, err = videoRepository.Insert(video)
+	require.Nil(t, err)
+
+	job, err := domain.NewJob("bucket", domain.JobStatusStarting, video)
+	require.Nil(t, err)
+
+	jobRepository := repositories.JobRepositoryDb{Db: db}
+	_, err = jobRepository.Insert(job)
+	require.Nil(t, err)
+
+	client := jobContextClient{MemoryClient: storage.NewMemoryClient()}
+	client.Put(os.Getenv("inputBucketName"), video.FilePath, fakeMp4("context"))
+
+	videoService := services.NewVideoService()
+	videoService.Context = context.WithValue(context.Background(), jobContextKey{}, job.ID)
+	videoService.Video = video
+	videoService.VideoRepository = videoRepository
+	videoService.Storage = client
+	videoService.Runner = fakeRunner{}
+
+	jobService := services.JobService{
+		Job:           job,
+		JobRepository: jobRepository,
+		VideoService:  videoService,
+	}
+
+	require.Nil(t, jobService.Start())
+	require.Equal(t, domain.JobStatusCompleted, job.Status)
+
+	_, ok := client.Get(job.OutputBucket, job.OutputBucketPath+"/stream.mpd")
+	require.True(t, ok)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"microsservico-encoder/framework/queue"
+)
+
+// ErrInvalidJobMessage indica que a mensagem enviada para SubmitJob não é um job válido.
+var ErrInvalidJobMessage = errors.New("invalid job message")
+
+/*
+SubmitJob valida a mensagem de um job e a publica com a prioridade AMQP igual ao campo
+priority do corpo, para que os jobs urgentes sejam entregues antes dos demais pela fila
+de consumo (criada com RABBITMQ_PRIORITY_QUEUE=true).
+O campo priority só é lido pelo worker depois da entrega: produtores que publicam
+diretamente no broker, sem SubmitJob, devem definir a prioridade AMQP da mensagem
+para que ela altere a ordem da fila.
+tenantID é o tenant autenticado do produtor (ex.: o da chave da API, ver api.TenantFor):
+quando informado, é gravado no corpo e no cabeçalho, substituindo o que a mensagem
+declara. Vazio, vale o tenant_id do corpo, para produtores confiáveis como a CLI.
+Retorna a mensagem interpretada, com o correlation ID gerado quando ausente.
+*/
+func SubmitJob(publisher queue.Publisher, exchange string, routingKey string, body []byte, tenantID string) (*JobMessage, error) {
+	if tenantID != "" {
+		var err error
+		body, err = stampTenant(body, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJobMessage, err)
+		}
+	}
+
+	jobMessage, err := ParseJobMessage(&localMessage{body: body})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobMessage, err)
+	}
+
+	err = publisher.Publish(exchange, routingKey, queue.Publishing{
+		ContentType: "application/json",
+		Body:        body,
+		Headers: map[string]interface{}{
+			CorrelationIDHeader: jobMessage.CorrelationID,
+			TenantIDHeader:      jobMessage.TenantID,
+		},
+		Priority: uint8(*jobMessage.Priority),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobMessage, nil
+}
+
+// stampTenant grava o tenant informado no campo tenant_id do corpo da mensagem.
+func stampTenant(body []byte, tenantID string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(body, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if fields == nil {
+		return nil, errors.New("message body must be a JSON object")
+	}
+
+	fields["tenant_id"], err = json.Marshal(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
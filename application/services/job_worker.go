@@ -1,119 +1,218 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/queue"
 	"microsservico-encoder/framework/utils"
-	"os"
-	"sync"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
-	"github.com/streadway/amqp"
 )
 
 // JobWorkerResult representa o resultado de um trabalho executado pelo worker,
 // incluindo o job processado, a mensagem da fila e um possível erro.
+// ErrorCode identifica falhas ocorridas antes da criação do job.
+// Requeue indica que a mensagem não foi processada e deve voltar à fila após um atraso, sem eventos.
 type JobWorkerResult struct {
-	Job     domain.Job
-	Message *amqp.Delivery
-	Error   error
+	Job       domain.Job
+	Message   queue.Message
+	Error     error
+	ErrorCode string
+	Requeue   bool
 }
 
-// Mutex é utilizado para evitar condições de corrida ao acessar
-// recursos compartilhados, como inserção de vídeo e job no banco de dados.
-var Mutex = &sync.Mutex{}
-
 // JobWorker é responsável por processar mensagens recebidas da fila,
 // validar e inserir vídeos e jobs no sistema, e iniciar o processamento do job.
+// Cada mensagem é processada com um JobService novo, obtido de newJobService,
+// para que jobs concorrentes não compartilhem vídeo, job ou serviços.
 func JobWorker(
-	messageChannel chan amqp.Delivery, // canal de mensagens recebidas da fila
+	messageChannel chan queue.Message, // canal de mensagens recebidas da fila
 	returnChan chan JobWorkerResult, // canal de retorno com o resultado do job
-	jobService JobService, // serviço que executa operações com vídeos e jobs
-	job domain.Job, // estrutura base do job a ser processado
+	newJobService func() *JobService, // cria o serviço que executa operações com vídeos e jobs
 	workerID int, // identificador do worker
 ) {
+	for message := range messageChannel {
+		returnChan <- processMessage(message, newJobService())
+	}
+}
 
-	// Exemplo esperado do corpo da mensagem:
-	// {
-	//     "resource_id":"id do video da pessoa que enviou para nossa fila",
-	//     "file_path": "convite.mp4"
-	// }
+/*
+processMessage valida a mensagem, cria e insere o vídeo e o job e executa o
+processamento com o JobService informado, retornando o resultado.
 
-	for message := range messageChannel {
+Exemplo esperado do corpo da mensagem:
 
-		// Verifica se o corpo da mensagem é um JSON válido.
-		err := utils.IsJson(string(message.Body))
-		if err != nil {
-			returnChan <- returnJobResult(domain.Job{}, message, err)
-			continue
-		}
+	{
+	    "resource_id":"id do video da pessoa que enviou para nossa fila",
+	    "file_path": "convite.mp4"
+	}
+*/
+func processMessage(message queue.Message, jobService *JobService) JobWorkerResult {
 
-		// Faz o parse da mensagem JSON para o objeto Video.
-		// Bloqueia a execução concorrente com Mutex.
-		Mutex.Lock()
-		err = json.Unmarshal(message.Body, &jobService.VideoService.Video)
-		jobService.VideoService.Video.ID = uuid.NewV4().String()
-		Mutex.Unlock()
+	// Verifica se o corpo da mensagem é um JSON válido.
+	err := utils.IsJson(string(message.Body()))
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
+	}
+
+	// Faz o parse da mensagem JSON e cria o objeto Video.
+	jobMessage, err := ParseJobMessage(message)
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
+	}
+
+	// Aplica as cotas do tenant: no limite de jobs simultâneos, a mensagem é adiada pelo JobManager.
+	tenant, err := jobService.Tenants.Resolve(jobMessage.TenantID)
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
+	}
+
+	err = tenant.checkQuotas(jobService.JobRepository, time.Now())
+	if errors.Is(err, ErrTenantBusy) {
+		return JobWorkerResult{Message: message, Error: err, Requeue: true}
+	}
+
+	if errors.Is(err, ErrQuotaExceeded) {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeQuotaExceeded)
+	}
+
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodePersistence)
+	}
+
+	jobService.VideoService.Video = jobMessage.Video()
+	jobService.VideoService.Inputs = jobMessage.ConcatInputs()
+	jobService.VideoService.Clip = jobMessage.Clip()
+	jobService.VideoService.AudioTracks = jobMessage.AudioTracks
+	jobService.VideoService.Subtitles = jobMessage.Subtitles
+	jobService.VideoService.Watermark = jobMessage.Watermark
+	jobService.VideoService.Loudnorm = jobMessage.Loudnorm
+	jobService.VideoService.Encryption = jobMessage.Encryption
+
+	// Valida o vídeo recebido.
+	err = jobService.VideoService.Video.Validate()
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidVideo)
+	}
 
+	// Cria o job com as informações do vídeo processado.
+	job := &domain.Job{
+		ID:               uuid.NewV4().String(),
+		Status:           domain.JobStatusStarting,
+		Version:          1,
+		Video:            jobService.VideoService.Video,
+		Profile:          jobMessage.Profile,
+		EncryptionScheme: encryptionScheme(jobMessage.Encryption),
+		CorrelationID:    jobMessage.CorrelationID,
+		CallbackURL:      jobMessage.CallbackURL,
+		Message:          string(message.Body()),
+		Requeues:         requeuesFromHeaders(message),
+		Priority:         *jobMessage.Priority,
+		TenantID:         tenant.ID,
+		CreatedAt:        time.Now(),
+	}
+
+	// Registra as entradas concatenadas e o corte para que a saída possa ser reproduzida.
+	if len(jobMessage.Inputs) > 1 {
+		inputs, err := json.Marshal(jobMessage.Inputs)
 		if err != nil {
-			returnChan <- returnJobResult(domain.Job{}, message, err)
-			continue
+			return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
 		}
+		job.Inputs = string(inputs)
+	}
+	job.ClipStart = jobMessage.Start
+	job.ClipEnd = jobMessage.End
 
-		// Valida o vídeo recebido.
-		err = jobService.VideoService.Video.Validate()
+	// Registra a configuração da marca d'água para que a saída possa ser reproduzida.
+	if jobMessage.Watermark != nil {
+		watermark, err := json.Marshal(jobMessage.Watermark)
 		if err != nil {
-			returnChan <- returnJobResult(domain.Job{}, message, err)
-			continue
+			return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
 		}
+		job.Watermark = string(watermark)
+	}
 
-		// Insere o vídeo no banco de dados (ou outro meio persistente).
-		Mutex.Lock()
-		err = jobService.VideoService.InsertVideo()
-		Mutex.Unlock()
+	// Registra os alvos da normalização de loudness.
+	if jobMessage.Loudnorm != nil {
+		loudnorm, err := json.Marshal(jobMessage.Loudnorm)
 		if err != nil {
-			returnChan <- returnJobResult(domain.Job{}, message, err)
-			continue
+			return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
 		}
+		job.Loudnorm = string(loudnorm)
+	}
 
-		// Preenche os dados do job com as informações do vídeo processado.
-		job.Video = jobService.VideoService.Video
-		job.OutputBucketPath = os.Getenv("outputBucketName") // nome do bucket de saída
-		job.ID = uuid.NewV4().String()
-		job.Status = "STARTING"
-		job.CreatedAt = time.Now()
+	// Define o bucket e o caminho de saída a partir da mensagem, isolados por tenant quando configurado.
+	job.OutputBucket, err = tenant.outputBucket(jobMessage.OutputBucket)
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
+	}
 
-		// Insere o job no repositório (banco de dados, por exemplo).
-		Mutex.Lock()
-		_, err = jobService.JobRepository.Insert(&job)
-		Mutex.Unlock()
+	job.OutputBucketPath, err = ResolveOutputPath(jobMessage.OutputPath, OutputPathVariables{
+		ResourceID: jobMessage.ResourceID,
+		VideoID:    job.Video.ID,
+		JobID:      job.ID,
+		Profile:    job.Profile,
+		Date:       job.CreatedAt,
+	})
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodeInvalidMessage)
+	}
+	job.OutputBucketPath = tenant.outputPath(job.OutputBucketPath)
 
-		if err != nil {
-			returnChan <- returnJobResult(domain.Job{}, message, err)
-			continue
-		}
+	// Insere o vídeo no banco de dados (ou outro meio persistente).
+	err = jobService.VideoService.InsertVideo()
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodePersistence)
+	}
 
-		// Inicia o processamento do job.
-		jobService.Job = &job
-		err = jobService.Start()
-		if err != nil {
-			returnChan <- returnJobResult(domain.Job{}, message, err)
-			continue
-		}
+	// Insere o job no repositório (banco de dados, por exemplo).
+	_, err = jobService.JobRepository.Insert(job)
+	if err != nil {
+		return returnJobResult(domain.Job{}, message, err, domain.ErrorCodePersistence)
+	}
+
+	// Os downloads do job são interrompidos quando o processamento termina, inclusive por falha.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jobService.VideoService.Context = ctx
+
+	// Mantém o heartbeat do job enquanto ele progride, para que o reaper identifique jobs travados.
+	jobService.Job = job
+	stopHeartbeat := jobService.startHeartbeat()
+	defer stopHeartbeat()
+
+	// Notifica que o job foi aceito e será processado.
+	jobService.notify(domain.NewJobEvent(domain.JobAccepted, job))
+
+	// Inicia o processamento do job.
+	err = jobService.Start()
+	if err != nil {
+		return returnJobResult(*jobService.Job, message, err, jobService.Job.ErrorCode)
+	}
+
+	// Retorna o resultado do job processado com sucesso.
+	return returnJobResult(*jobService.Job, message, nil, "")
+}
 
-		// Envia o resultado do job processado com sucesso.
-		returnChan <- returnJobResult(job, message, nil)
+// encryptionScheme retorna o esquema de criptografia do job, ou vazio se o conteúdo não for criptografado.
+func encryptionScheme(encryption *Encryption) string {
+	if encryption == nil {
+		return ""
 	}
+	return encryption.Scheme
 }
 
 // returnJobResult encapsula o resultado da execução de um job,
 // retornando uma estrutura com o job, a mensagem e o erro, se houver.
-func returnJobResult(job domain.Job, message amqp.Delivery, err error) JobWorkerResult {
+func returnJobResult(job domain.Job, message queue.Message, err error, errorCode string) JobWorkerResult {
 	result := JobWorkerResult{
-		Job:     job,
-		Message: &message,
-		Error:   err,
+		Job:       job,
+		Message:   message,
+		Error:     err,
+		ErrorCode: errorCode,
 	}
 	return result
 }
@@ -1,16 +1,20 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"log"
 	"microsservico-encoder/application/repositories"
 	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/drm"
 	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/jinzhu/gorm"
-	"github.com/streadway/amqp"
 )
 
 /*
@@ -19,50 +23,116 @@ recepção de mensagens, execução de jobs e envio de notificações.
 */
 type JobManager struct {
 	Db               *gorm.DB             // Conexão com o banco de dados
-	Domain           domain.Job           // Estrutura do job que será processado
-	MessageChannel   chan amqp.Delivery   // Canal com mensagens recebidas da fila
+	MessageChannel   chan queue.Message   // Canal com mensagens recebidas da fila
 	JobReturnChannel chan JobWorkerResult // Canal de retorno dos resultados dos workers
-	RabbitMQ         *queue.RabbitMQ      // Cliente para comunicação com RabbitMQ
-}
-
-/*
-JobNotificationError representa a estrutura da notificação de erro
-que será enviada para outra fila via RabbitMQ.
-*/
-type JobNotificationError struct {
-	Message string `json:"message"`
-	Error   string `json:"error"`
+	Publisher        queue.Publisher      // Broker utilizado para publicar as notificações
+	Notifier         *JobNotifier         // Publica os eventos do ciclo de vida dos jobs
+	Webhooks         *WebhookNotifier     // Entrega os eventos finais na callback_url dos jobs (nil sem WEBHOOK_SECRET)
+	Signer           *storage.URLSigner   // Assina as URLs dos manifestos quando a ACL de upload é privada
+	Storage          storage.Client       // Cliente de armazenamento dos jobs (se nil, cada job usa o GCS)
+	Runner           CommandRunner        // Executa as ferramentas externas (se nil, ExecRunner)
+	KeyProvider      drm.KeyProvider      // Fornece as chaves dos jobs com criptografia (se nil, esses jobs falham)
+	Heartbeat        time.Duration        // Intervalo dos heartbeats dos jobs em processamento (0 desativa)
+	Tenants          Tenants              // Tenants configurados, com as cotas e o isolamento de cada um
+	QuotaBackoff     time.Duration        // Atraso até a mensagem de um tenant no limite de jobs simultâneos voltar à fila de consumo
+	DelayRoutingKey  string               // Fila de espera, na exchange padrão, das mensagens adiadas (ver queue.DelayQueueName)
+	webhooks         sync.WaitGroup       // Entregas de webhook em andamento
 }
 
 /*
 NewJobManager cria e retorna uma nova instância de JobManager
 com todos os canais e conexões necessárias para operação.
+O intervalo dos heartbeats é lido de JOB_HEARTBEAT_INTERVAL (padrão: 30s), os tenants
+de TENANTS e o atraso das mensagens de tenants no limite de TENANT_QUOTA_BACKOFF (padrão: 5s).
 */
-func NewJobManager(db *gorm.DB, rabbitMQ *queue.RabbitMQ, jobReturnChannel chan JobWorkerResult, messageChannel chan amqp.Delivery) *JobManager {
+func NewJobManager(db *gorm.DB, publisher queue.Publisher, jobReturnChannel chan JobWorkerResult, messageChannel chan queue.Message) *JobManager {
+	heartbeat, err := time.ParseDuration(os.Getenv("JOB_HEARTBEAT_INTERVAL"))
+	if err != nil || heartbeat < 0 {
+		heartbeat = 30 * time.Second
+	}
+
+	tenants, err := LoadTenants()
+	if err != nil {
+		log.Fatalf("error loading var: TENANTS: %v", err)
+	}
+
+	quotaBackoff, err := time.ParseDuration(os.Getenv("TENANT_QUOTA_BACKOFF"))
+	if err != nil || quotaBackoff < 0 {
+		quotaBackoff = 5 * time.Second
+	}
+
+	webhooks, err := NewWebhookNotifier(repositories.WebhookDeliveryRepositoryDb{Db: db})
+	if err != nil {
+		log.Fatalf("error loading webhooks: %v", err)
+	}
+
+	if webhooks == nil {
+		log.Printf("WEBHOOK_SECRET is not set, webhooks are disabled and callback_url will not be notified")
+	}
+
 	return &JobManager{
 		Db:               db,
-		Domain:           domain.Job{},
 		MessageChannel:   messageChannel,
 		JobReturnChannel: jobReturnChannel,
-		RabbitMQ:         rabbitMQ,
+		Publisher:        publisher,
+		Notifier:         NewJobNotifier(publisher, tenants),
+		Webhooks:         webhooks,
+		Signer:           newURLSigner(),
+		KeyProvider:      newKeyProvider(),
+		Heartbeat:        heartbeat,
+		Tenants:          tenants,
+		QuotaBackoff:     quotaBackoff,
+		DelayRoutingKey:  queue.DelayQueueName(),
 	}
 }
 
 /*
-Start inicializa os workers de acordo com a variável de ambiente CONCURRENCY_WORKERS.
-Cada worker processa mensagens da fila e envia o resultado via canal.
-Ao final do processamento, as mensagens são confirmadas ou rejeitadas.
+newKeyProvider cria o KeyProvider configurado em DRM_KEY_PROVIDER.
+Sem um KeyProvider, os jobs que pedem criptografia falham na etapa de encoding.
 */
-func (j *JobManager) Start(ch *amqp.Channel) {
+func newKeyProvider() drm.KeyProvider {
+	provider, err := drm.NewKeyProviderFromEnv()
+	if err != nil {
+		log.Printf("error loading DRM key provider, encrypted jobs will fail: %v", err)
+		return nil
+	}
 
-	videoService := NewVideoService()
-	videoService.VideoRepository = repositories.VideoRepositoryDb{Db: j.Db}
+	return provider
+}
+
+/*
+newURLSigner cria o URLSigner quando a política de upload é privada.
+Sem credenciais de assinatura, os eventos trazem as URLs sem assinatura;
+um SIGNED_URL_TTL inválido interrompe a inicialização.
+*/
+func newURLSigner() *storage.URLSigner {
+	acl, err := storage.ParseACLPolicy(os.Getenv("UPLOAD_ACL"))
+	if err != nil || !acl.IsPrivate() {
+		return nil
+	}
+
+	signer, err := storage.NewURLSignerFromEnv()
+	if errors.Is(err, storage.ErrInvalidSignedURLTTL) {
+		log.Fatalf("error loading URL signer: %v", err)
+	}
 
-	jobService := JobService{
-		JobRepository: repositories.JobRepositoryDb{Db: j.Db},
-		VideoService:  videoService,
+	if err != nil {
+		log.Printf("error loading URL signer, manifest URLs will not be signed: %v", err)
+		return nil
 	}
 
+	return signer
+}
+
+/*
+Start inicializa os workers de acordo com a variável de ambiente CONCURRENCY_WORKERS.
+Cada worker processa mensagens da fila e envia o resultado via canal.
+Ao final do processamento, as mensagens são confirmadas ou rejeitadas.
+Start retorna quando o canal de mensagens é fechado, todos os workers terminam
+e as entregas de webhook pendentes são concluídas.
+*/
+func (j *JobManager) Start() {
+
 	concurrency, err := strconv.Atoi(os.Getenv("CONCURRENCY_WORKERS"))
 
 	if err != nil {
@@ -70,45 +140,91 @@ func (j *JobManager) Start(ch *amqp.Channel) {
 	}
 
 	// Inicializa os workers concorrentes com base no valor de CONCURRENCY_WORKERS.
+	var workers sync.WaitGroup
 	for qtdProcesses := 0; qtdProcesses < concurrency; qtdProcesses++ {
-		go JobWorker(j.MessageChannel, j.JobReturnChannel, jobService, j.Domain, qtdProcesses)
+		workers.Add(1)
+		go func(workerID int) {
+			defer workers.Done()
+			JobWorker(j.MessageChannel, j.JobReturnChannel, j.newJobService, workerID)
+		}(qtdProcesses)
 	}
 
+	// Fecha o canal de retorno quando todos os workers terminarem.
+	go func() {
+		workers.Wait()
+		close(j.JobReturnChannel)
+	}()
+
 	// Processa os resultados recebidos dos workers.
 	for jobResult := range j.JobReturnChannel {
-		if jobResult.Error != nil {
+		switch {
+		case jobResult.Requeue:
+			err = j.delay(jobResult)
+		case jobResult.Error != nil:
 			err = j.checkParseErrors(jobResult)
-		} else {
-			err = j.notifySuccess(jobResult, ch)
+		default:
+			err = j.notifySuccess(jobResult)
 		}
 
 		if err != nil {
-			jobResult.Message.Reject(false)
+			jobResult.Message.Nack(false)
 		}
 	}
+
+	j.webhooks.Wait()
 }
 
 /*
-notifySuccess envia uma notificação de sucesso contendo o job serializado em JSON.
+newJobService cria um JobService isolado para um único job, com o seu próprio
+VideoService. Apenas as dependências seguras para uso concorrente (conexão com o
+banco, notificador, cliente de armazenamento, runner e provedor de chaves)
+são compartilhadas, além do intervalo de heartbeat e da configuração dos tenants.
+*/
+func (j *JobManager) newJobService() *JobService {
+	videoService := NewVideoService()
+	videoService.VideoRepository = repositories.VideoRepositoryDb{Db: j.Db}
+	videoService.Storage = j.Storage
+	videoService.Runner = j.Runner
+	videoService.KeyProvider = j.KeyProvider
+	videoService.SegmentList = j.Signer != nil
+
+	return &JobService{
+		JobRepository:     repositories.JobRepositoryDb{Db: j.Db},
+		VideoService:      videoService,
+		Notifier:          j.Notifier,
+		HeartbeatInterval: j.Heartbeat,
+		Tenants:           j.Tenants,
+	}
+}
+
+/*
+notifySuccess publica o evento job.completed com as URLs dos manifestos gerados.
 Em seguida, confirma a mensagem na fila com `Ack`.
+O job já está COMPLETED no banco: se as URLs não puderem ser geradas (ex.: falha ao
+assiná-las), o evento é publicado sem elas, com o motivo em outputs_error.
 */
-func (j *JobManager) notifySuccess(jobResult JobWorkerResult, ch *amqp.Channel) error {
+func (j *JobManager) notifySuccess(jobResult JobWorkerResult) error {
+
+	var err error
 
-	Mutex.Lock()
-	jobJson, err := json.Marshal(jobResult.Job)
-	Mutex.Unlock()
+	event := domain.NewJobEvent(domain.JobCompleted, &jobResult.Job)
+	event.Outputs, err = j.outputsFor(&jobResult.Job)
 
 	if err != nil {
-		return err
+		log.Printf("MessageID: %v. Error generating output URLs of job %v: %v", jobResult.Message.ID(), jobResult.Job.ID, err)
+		event.Outputs = nil
+		event.OutputsError = &domain.JobEventError{Code: domain.ErrorCodeOutputs, Message: err.Error()}
 	}
 
-	err = j.notify(jobJson)
+	j.deliverWebhook(jobResult.Job, event)
+
+	err = j.Notifier.Notify(event)
 
 	if err != nil {
 		return err
 	}
 
-	err = jobResult.Message.Ack(false)
+	err = jobResult.Message.Ack()
 
 	if err != nil {
 		return err
@@ -118,31 +234,78 @@ func (j *JobManager) notifySuccess(jobResult JobWorkerResult, ch *amqp.Channel)
 }
 
 /*
-checkParseErrors trata mensagens com erro, imprimindo logs,
-criando uma estrutura de erro e enviando uma notificação para outra fila.
+delay adia uma mensagem que não pôde ser processada agora (tenant no limite de jobs
+simultâneos): ela é publicada na fila de espera, expirando após QuotaBackoff, e confirmada.
+Ao expirar, volta para a fila de consumo, sem ocupar o worker nem atrasar as mensagens
+dos demais tenants. Se a publicação falhar, a mensagem volta imediatamente à fila.
+*/
+func (j *JobManager) delay(jobResult JobWorkerResult) error {
+	message := jobResult.Message
+	log.Printf("MessageID: %v. Delaying message for %v: %v", message.ID(), j.QuotaBackoff, jobResult.Error)
+
+	backoff := j.QuotaBackoff
+	if backoff < time.Millisecond {
+		backoff = time.Millisecond
+	}
+
+	err := j.Publisher.Publish("", j.DelayRoutingKey, queue.Publishing{
+		ContentType: "application/json",
+		Body:        message.Body(),
+		Headers:     message.Headers(),
+		Priority:    message.Priority(),
+		Expiration:  backoff,
+	})
+
+	if err != nil {
+		log.Printf("MessageID: %v. Error delaying message, returning it to the queue: %v", message.ID(), err)
+		return message.Nack(true)
+	}
+
+	return message.Ack()
+}
+
+/*
+checkParseErrors trata mensagens com erro, imprimindo logs e publicando
+o evento job.failed com o código do erro e o corpo original da mensagem.
+Sem job criado, o tenant da mensagem não foi validado: o evento vai para o tenant
+padrão, para que uma mensagem inválida não publique na routing key de outro tenant.
 */
 func (j *JobManager) checkParseErrors(jobResult JobWorkerResult) error {
+	var event *domain.JobEvent
+
 	if jobResult.Job.ID != "" {
 		log.Printf("MessageID: %v. Error during the job: %v with video: %v. Error: %v",
-			jobResult.Message.DeliveryTag, jobResult.Job.ID, jobResult.Job.Video.ID, jobResult.Error.Error())
+			jobResult.Message.ID(), jobResult.Job.ID, jobResult.Job.Video.ID, jobResult.Error.Error())
+		event = domain.NewJobEvent(domain.JobFailed, &jobResult.Job)
 	} else {
-		log.Printf("MessageID: %v. Error parsing message: %v", jobResult.Message.DeliveryTag, jobResult.Error)
+		log.Printf("MessageID: %v. Error parsing message: %v", jobResult.Message.ID(), jobResult.Error)
+		event = domain.NewJobEvent(domain.JobFailed, nil)
+		event.Message = string(jobResult.Message.Body())
+		event.CorrelationID = correlationIDFromHeaders(jobResult.Message)
+		event.TenantID = DefaultTenantID
 	}
 
-	errorMsg := JobNotificationError{
-		Message: string(jobResult.Message.Body),
-		Error:   jobResult.Error.Error(),
+	errorCode := jobResult.ErrorCode
+	if errorCode == "" {
+		errorCode = domain.ErrorCodeInternal
 	}
 
-	jobJson, err := json.Marshal(errorMsg)
+	event.Error = &domain.JobEventError{
+		Code:    errorCode,
+		Message: jobResult.Error.Error(),
+	}
 
-	err = j.notify(jobJson)
+	if jobResult.Job.ID != "" {
+		j.deliverWebhook(jobResult.Job, event)
+	}
+
+	err := j.Notifier.Notify(event)
 
 	if err != nil {
 		return err
 	}
 
-	err = jobResult.Message.Reject(false)
+	err = jobResult.Message.Nack(false)
 
 	if err != nil {
 		return err
@@ -152,21 +315,74 @@ func (j *JobManager) checkParseErrors(jobResult JobWorkerResult) error {
 }
 
 /*
-notify envia mensagens para uma exchange do RabbitMQ com base
-nas variáveis de ambiente RABBITMQ_NOTIFICATION_EX e RABBITMQ_NOTIFICATION_ROUTING_KEY.
+deliverWebhook entrega o evento final na callback_url do job, quando informada.
+A entrega ocorre em segundo plano para que as repetições não atrasem o processamento
+dos demais resultados; a exchange continua sendo notificada normalmente.
 */
-func (j *JobManager) notify(jobJson []byte) error {
+func (j *JobManager) deliverWebhook(job domain.Job, event *domain.JobEvent) {
+	if job.CallbackURL == "" || j.Webhooks == nil {
+		return
+	}
 
-	err := j.RabbitMQ.Notify(
-		string(jobJson),
-		"application/json",
-		os.Getenv("RABBITMQ_NOTIFICATION_EX"),
-		os.Getenv("RABBITMQ_NOTIFICATION_ROUTING_KEY"),
-	)
+	j.webhooks.Add(1)
+	go func() {
+		defer j.webhooks.Done()
 
-	if err != nil {
-		return err
+		err := j.Webhooks.Deliver(&job, event)
+		if err != nil {
+			log.Printf("error delivering webhook for job %v: %v", job.ID, err)
+		}
+	}()
+}
+
+/*
+outputsFor monta as URLs dos manifestos gerados por um job.
+Com um Signer configurado (ACL privada), são publicadas cópias dos manifestos com
+as URLs dos segmentos assinadas, e as URLs retornadas, também assinadas, apontam
+para essas cópias e expiram; caso contrário, são montadas a partir de OUTPUT_BASE_URL
+(por padrão, o endpoint público do Google Cloud Storage).
+*/
+func (j *JobManager) outputsFor(job *domain.Job) (*domain.JobOutputs, error) {
+	if job.Video == nil {
+		return nil, nil
 	}
 
-	return nil
+	manifest := job.OutputBucketPath + "/stream.mpd"
+	hlsManifest := job.OutputBucketPath + "/master.m3u8"
+
+	if j.Signer != nil {
+		ctx := context.Background()
+
+		client := j.Storage
+		if client == nil {
+			gcsClient, err := storage.NewGCSClient(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer gcsClient.Close()
+			client = gcsClient
+		}
+
+		url, expires, err := j.Signer.SignManifest(ctx, client, job.OutputBucket, manifest)
+		if err != nil {
+			return nil, err
+		}
+
+		hlsURL, _, err := j.Signer.SignManifest(ctx, client, job.OutputBucket, hlsManifest)
+		if err != nil {
+			return nil, err
+		}
+
+		return &domain.JobOutputs{ManifestURL: url, HLSManifestURL: hlsURL, ExpiresAt: &expires}, nil
+	}
+
+	baseURL := os.Getenv("OUTPUT_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://storage.googleapis.com"
+	}
+
+	return &domain.JobOutputs{
+		ManifestURL:    baseURL + "/" + job.OutputBucket + "/" + manifest,
+		HLSManifestURL: baseURL + "/" + job.OutputBucket + "/" + hlsManifest,
+	}, nil
 }
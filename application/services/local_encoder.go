@@ -0,0 +1,97 @@
+package services
+
+import (
+	"encoding/json"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/drm"
+	"microsservico-encoder/framework/storage"
+	"path/filepath"
+	"strings"
+)
+
+/*
+LocalEncoder executa o pipeline completo de um job para um arquivo local, sem broker,
+banco ou bucket: o arquivo é lido via file://, o vídeo e o job ficam em memória e a
+saída é gravada em OutputDir/<bucket de saída>/<caminho de saída>.
+Os arquivos intermediários usam a pasta localStoragePath, como nos jobs da fila.
+*/
+type LocalEncoder struct {
+	OutputDir   string
+	Runner      CommandRunner   // Executa as ferramentas externas (se nil, ExecRunner)
+	KeyProvider drm.KeyProvider // Fornece as chaves quando a mensagem pede criptografia
+}
+
+/*
+Encode processa o arquivo com as opções da mensagem (perfil, corte, caminho de saída...).
+FilePath é substituído pelo arquivo informado e, sem ResourceID, o nome do arquivo é usado.
+Retorna o job ao final, com o status COMPLETED ou FAILED.
+*/
+func (e *LocalEncoder) Encode(file string, message JobMessage) (*domain.Job, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+
+	message.FilePath = "file://" + filepath.ToSlash(path)
+	if message.ResourceID == "" {
+		message.ResourceID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &storage.DirClient{Root: e.OutputDir}
+	fetchers := storage.NewFetchers(client)
+	fetchers["file"] = &storage.FileFetcher{Root: filepath.Dir(path)}
+
+	store := repositories.NewMemoryStore()
+	videoService := NewVideoService()
+	videoService.VideoRepository = repositories.VideoRepositoryMemory{Store: store}
+	videoService.Storage = client
+	videoService.Fetchers = fetchers
+	videoService.Runner = e.Runner
+	videoService.KeyProvider = e.KeyProvider
+
+	result := processMessage(&localMessage{body: body}, &JobService{
+		JobRepository: repositories.JobRepositoryMemory{Store: store},
+		VideoService:  videoService,
+	})
+
+	if result.Job.ID == "" {
+		return nil, result.Error
+	}
+
+	return &result.Job, result.Error
+}
+
+// localMessage é a mensagem de um job executado localmente, sem broker.
+type localMessage struct {
+	body []byte
+}
+
+func (m *localMessage) ID() string {
+	return "local"
+}
+
+func (m *localMessage) Body() []byte {
+	return m.body
+}
+
+func (m *localMessage) Headers() map[string]interface{} {
+	return nil
+}
+
+func (m *localMessage) Priority() uint8 {
+	return 0
+}
+
+func (m *localMessage) Ack() error {
+	return nil
+}
+
+func (m *localMessage) Nack(requeue bool) error {
+	return nil
+}
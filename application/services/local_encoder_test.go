@@ -0,0 +1,44 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLocalEncoderEncodesFile verifica que o pipeline completo grava a saída no diretório local.
+func TestLocalEncoderEncodesFile(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	inputDir, err := ioutil.TempDir("", "input")
+	require.Nil(t, err)
+	defer os.RemoveAll(inputDir)
+
+	input := filepath.Join(inputDir, "convite.mp4")
+	require.Nil(t, ioutil.WriteFile(input, fakeMp4("convite"), 0644))
+
+	outputDir, err := ioutil.TempDir("", "output")
+	require.Nil(t, err)
+	defer os.RemoveAll(outputDir)
+
+	encoder := &services.LocalEncoder{OutputDir: outputDir, Runner: fakeRunner{}}
+	job, err := encoder.Encode(input, services.JobMessage{})
+	require.Nil(t, err)
+	require.Equal(t, domain.JobStatusCompleted, job.Status)
+	require.Equal(t, "convite", job.Video.ResourceID)
+
+	manifest, err := ioutil.ReadFile(filepath.Join(outputDir, job.OutputBucket, job.OutputBucketPath, "stream.mpd"))
+	require.Nil(t, err)
+	require.Equal(t, fakeMp4("convite"), manifest)
+
+	_, err = encoder.Encode(filepath.Join(inputDir, "missing.mp4"), services.JobMessage{})
+	require.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+Loudnorm define a normalização de loudness do áudio (EBU R128), feita em duas
+passagens com o filtro loudnorm do ffmpeg: a primeira mede o áudio de entrada e a
+segunda aplica a correção linear com os valores medidos.
+- Integrated: loudness integrado alvo, em LUFS (padrão: LOUDNORM_INTEGRATED ou -23).
+- TruePeak: pico real máximo, em dBTP (padrão: LOUDNORM_TRUE_PEAK ou -2).
+- LRA: faixa de loudness alvo, em LU (padrão: LOUDNORM_LRA ou 7).
+*/
+type Loudnorm struct {
+	Integrated *float64 `json:"integrated,omitempty"`
+	TruePeak   *float64 `json:"true_peak,omitempty"`
+	LRA        *float64 `json:"lra,omitempty"`
+}
+
+/*
+LoudnessMeasurement representa os valores medidos pela primeira passagem do loudnorm.
+*/
+type LoudnessMeasurement struct {
+	Integrated float64 // Loudness integrado, em LUFS
+	TruePeak   float64 // Pico real, em dBTP
+	LRA        float64 // Faixa de loudness, em LU
+	Threshold  float64 // Limiar de gating, em LUFS
+	Offset     float64 // Ganho de compensação calculado pelo filtro, em LU
+}
+
+/*
+Validate define os valores padrão e verifica se os alvos estão nos intervalos
+aceitos pelo filtro loudnorm.
+*/
+func (l *Loudnorm) Validate() error {
+	l.Integrated = defaultFloat(l.Integrated, "LOUDNORM_INTEGRATED", -23)
+	l.TruePeak = defaultFloat(l.TruePeak, "LOUDNORM_TRUE_PEAK", -2)
+	l.LRA = defaultFloat(l.LRA, "LOUDNORM_LRA", 7)
+
+	if *l.Integrated < -70 || *l.Integrated > -5 {
+		return fmt.Errorf("invalid loudnorm integrated target: %v", *l.Integrated)
+	}
+
+	if *l.TruePeak < -9 || *l.TruePeak > 0 {
+		return fmt.Errorf("invalid loudnorm true peak: %v", *l.TruePeak)
+	}
+
+	if *l.LRA < 1 || *l.LRA > 50 {
+		return fmt.Errorf("invalid loudnorm LRA: %v", *l.LRA)
+	}
+
+	return nil
+}
+
+// targets retorna os parâmetros de alvo do filtro loudnorm.
+func (l *Loudnorm) targets() string {
+	return fmt.Sprintf("loudnorm=I=%v:TP=%v:LRA=%v", formatFloat(*l.Integrated), formatFloat(*l.TruePeak), formatFloat(*l.LRA))
+}
+
+// filter retorna o filtro da segunda passagem, com os valores medidos na primeira.
+func (l *Loudnorm) filter(measured *LoudnessMeasurement) string {
+	return fmt.Sprintf("%v:measured_I=%v:measured_TP=%v:measured_LRA=%v:measured_thresh=%v:offset=%v:linear=true,aresample=48000",
+		l.targets(), formatFloat(measured.Integrated), formatFloat(measured.TruePeak),
+		formatFloat(measured.LRA), formatFloat(measured.Threshold), formatFloat(measured.Offset))
+}
+
+/*
+measureLoudness executa a primeira passagem do loudnorm sobre o áudio audioLabel,
+usando as mesmas entradas (cmdArgs) e filtros da conversão, e registra os valores
+medidos no vídeo. Retorna nil, sem erro, quando o áudio é silencioso e não pode
+ser normalizado.
+*/
+func (v *VideoService) measureLoudness(cmdArgs []string, filters []string, audioLabel string) (*LoudnessMeasurement, error) {
+	args := []string{"-hide_banner", "-nostats"}
+	for i := 0; i < len(cmdArgs)-1; i++ {
+		if cmdArgs[i] == "-i" {
+			args = append(args, "-i", cmdArgs[i+1])
+		}
+	}
+
+	graph := append(append([]string{}, filters...), filterInput(audioLabel)+v.Loudnorm.targets()+":print_format=json[measure]")
+	args = append(args, "-filter_complex", strings.Join(graph, ";"), "-map", "[measure]")
+	if v.Clip != nil {
+		args = append(args, v.Clip.args()...)
+	}
+	args = append(args, "-f", "null", "-")
+
+	output, err := v.run("ffmpeg", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error measuring loudness: %v", err)
+	}
+
+	measured, err := parseLoudnessMeasurement(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if measured == nil {
+		log.Printf("video %v has silent audio, skipping loudness normalization", v.Video.ID)
+		return nil, nil
+	}
+
+	v.Video.InputLoudness = &measured.Integrated
+	v.Video.InputTruePeak = &measured.TruePeak
+	v.Video.InputLoudnessRange = &measured.LRA
+	v.Video.InputLoudnessThreshold = &measured.Threshold
+
+	log.Printf("video %v loudness: %v LUFS, %v dBTP, %v LU", v.Video.ID, measured.Integrated, measured.TruePeak, measured.LRA)
+
+	return measured, nil
+}
+
+/*
+parseLoudnessMeasurement extrai o JSON impresso pelo loudnorm (print_format=json)
+ao final da saída do ffmpeg. Retorna nil se algum valor não for finito (áudio silencioso).
+*/
+func parseLoudnessMeasurement(output []byte) (*LoudnessMeasurement, error) {
+	text := string(output)
+	start, end := strings.LastIndex(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("loudness measurement not found in ffmpeg output")
+	}
+
+	var values struct {
+		InputI       string `json:"input_i"`
+		InputTP      string `json:"input_tp"`
+		InputLRA     string `json:"input_lra"`
+		InputThresh  string `json:"input_thresh"`
+		TargetOffset string `json:"target_offset"`
+	}
+
+	err := json.Unmarshal([]byte(text[start:end+1]), &values)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing loudness measurement: %v", err)
+	}
+
+	parsed := make([]float64, 0, 5)
+	for _, value := range []string{values.InputI, values.InputTP, values.InputLRA, values.InputThresh, values.TargetOffset} {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			return nil, nil
+		}
+		parsed = append(parsed, number)
+	}
+
+	return &LoudnessMeasurement{
+		Integrated: parsed[0],
+		TruePeak:   parsed[1],
+		LRA:        parsed[2],
+		Threshold:  parsed[3],
+		Offset:     parsed[4],
+	}, nil
+}
+
+// defaultFloat retorna value ou, se nil, o valor da variável de ambiente env ou fallback.
+func defaultFloat(value *float64, env string, fallback float64) *float64 {
+	if value != nil {
+		return value
+	}
+
+	parsed, err := strconv.ParseFloat(os.Getenv(env), 64)
+	if err != nil {
+		parsed = fallback
+	}
+
+	return &parsed
+}
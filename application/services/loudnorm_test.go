@@ -0,0 +1,123 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestVideoServiceLoudnorm verifica as duas passagens do loudnorm: a medição é
+registrada no vídeo e os valores medidos são usados na conversão.
+*/
+func TestVideoServiceLoudnorm(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	video, _ := prepare()
+	video.FilePath = "video.mp4"
+	repo := repositories.VideoRepositoryDb{Db: db}
+	_, err = repo.Insert(video)
+	require.Nil(t, err)
+
+	integrated := -16.0
+	loudnorm := &services.Loudnorm{Integrated: &integrated}
+	require.Nil(t, loudnorm.Validate())
+
+	runner := &recordingRunner{}
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.Loudnorm = loudnorm
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.Convert())
+	require.Equal(t, services.ConversionTranscode, videoService.Conversion)
+
+	measure := runner.commands[len(runner.commands)-2]
+	require.Contains(t, measure, "[0:a:0]loudnorm=I=-16:TP=-2:LRA=7:print_format=json[measure]")
+	require.True(t, strings.HasSuffix(measure, "-f null -"))
+
+	convert := runner.commands[len(runner.commands)-1]
+	require.Contains(t, convert, "[0:a:0]loudnorm=I=-16:TP=-2:LRA=7:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.2:offset=0.58:linear=true")
+	require.Contains(t, convert, "-map 0:v:0 -map [na]")
+	require.Contains(t, convert, "-c:v copy")
+	require.Contains(t, convert, "-c:a aac")
+
+	require.Equal(t, -27.61, *video.InputLoudness)
+	require.Equal(t, -4.47, *video.InputTruePeak)
+	require.Equal(t, 18.06, *video.InputLoudnessRange)
+
+	require.Nil(t, videoService.UpdateVideo())
+	stored, err := repo.Find(video.ID)
+	require.Nil(t, err)
+	require.Equal(t, -27.61, *stored.InputLoudness)
+
+	require.Nil(t, videoService.Finish())
+}
+
+/*
+TestVideoServiceLoudnormSilentAudio verifica que o áudio silencioso não é normalizado.
+*/
+func TestVideoServiceLoudnormSilentAudio(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+
+	video, repo := prepare()
+	video.FilePath = "video.mp4"
+
+	loudnorm := &services.Loudnorm{}
+	require.Nil(t, loudnorm.Validate())
+
+	runner := &recordingRunner{fakeRunner: fakeRunner{
+		Loudness: `{"input_i" : "-inf", "input_tp" : "-inf", "input_lra" : "0.00", "input_thresh" : "-70.00", "target_offset" : "inf"}`,
+	}}
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.Loudnorm = loudnorm
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.Convert())
+	require.Nil(t, video.InputLoudness)
+	require.NotContains(t, runner.commands[len(runner.commands)-1], "measured_I")
+	require.Nil(t, videoService.Finish())
+}
+
+func TestLoudnormValidate(t *testing.T) {
+	setEnv(t, "LOUDNORM_INTEGRATED", "-24")
+
+	loudnorm := &services.Loudnorm{}
+	require.Nil(t, loudnorm.Validate())
+	require.Equal(t, -24.0, *loudnorm.Integrated)
+
+	tooLoud, peak := 0.0, 3.0
+	require.Error(t, (&services.Loudnorm{Integrated: &tooLoud}).Validate())
+	require.Error(t, (&services.Loudnorm{TruePeak: &peak}).Validate())
+}
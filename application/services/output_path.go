@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultOutputPathTemplate mantém o layout original: os arquivos ficam em <videoID>/...
+const DefaultOutputPathTemplate = "{video_id}"
+
+// DefaultProfile é o perfil de encoding utilizado quando a mensagem não informa um.
+const DefaultProfile = "default"
+
+var (
+	outputPathVariable = regexp.MustCompile(`\{[^{}]*\}`)
+	outputPathSegment  = regexp.MustCompile(`^[A-Za-z0-9._=-]+$`)
+	bucketName         = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,220}[a-z0-9]$`)
+)
+
+// OutputPathVariables são os valores disponíveis para os templates de caminho de saída.
+type OutputPathVariables struct {
+	ResourceID string
+	VideoID    string
+	JobID      string
+	Profile    string
+	Date       time.Time
+}
+
+/*
+ResolveOutputPath substitui as variáveis do template de caminho de saída
+({resource_id}, {video_id}, {job_id}, {profile} e {date}, no formato AAAA/MM/DD)
+e valida o resultado: o caminho deve ser relativo, sem segmentos vazios, "." ou "..",
+e cada segmento só pode conter letras, números e os caracteres . _ = -.
+A validação ocorre após a substituição, pois os valores vêm da mensagem.
+O template deve conter {video_id} ou {job_id}: os manifestos têm nomes fixos
+(stream.mpd, master.m3u8), e jobs com o mesmo caminho sobrescreveriam os arquivos uns dos outros.
+*/
+func ResolveOutputPath(template string, variables OutputPathVariables) (string, error) {
+	if template == "" {
+		template = DefaultOutputPathTemplate
+	}
+
+	if !strings.Contains(template, "{video_id}") && !strings.Contains(template, "{job_id}") {
+		return "", fmt.Errorf("output path template %q must contain {video_id} or {job_id}", template)
+	}
+
+	values := map[string]string{
+		"{resource_id}": variables.ResourceID,
+		"{video_id}":    variables.VideoID,
+		"{job_id}":      variables.JobID,
+		"{profile}":     variables.Profile,
+		"{date}":        variables.Date.UTC().Format("2006/01/02"),
+	}
+
+	var unknown error
+	path := outputPathVariable.ReplaceAllStringFunc(template, func(variable string) string {
+		value, ok := values[variable]
+		if !ok {
+			unknown = fmt.Errorf("unknown variable %v in output path template", variable)
+		}
+		return value
+	})
+
+	if unknown != nil {
+		return "", unknown
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "." || segment == ".." || !outputPathSegment.MatchString(segment) {
+			return "", fmt.Errorf("invalid output path %q resolved from template %q", path, template)
+		}
+	}
+
+	return path, nil
+}
+
+/*
+ResolveOutputBucket retorna o bucket de saída do job: o informado na mensagem ou,
+se vazio, o padrão outputBucketName. Quando OUTPUT_BUCKETS_ALLOWED estiver definida
+(lista separada por vírgulas), apenas os buckets listados e o padrão são aceitos.
+*/
+func ResolveOutputBucket(bucket string) (string, error) {
+	defaultBucket := os.Getenv("outputBucketName")
+
+	if bucket == "" || bucket == defaultBucket {
+		return defaultBucket, nil
+	}
+
+	if !bucketName.MatchString(bucket) {
+		return "", fmt.Errorf("invalid output bucket: %v", bucket)
+	}
+
+	allowed := os.Getenv("OUTPUT_BUCKETS_ALLOWED")
+	if allowed == "" {
+		return bucket, nil
+	}
+
+	for _, name := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(name) == bucket {
+			return bucket, nil
+		}
+	}
+
+	return "", fmt.Errorf("output bucket %v is not allowed", bucket)
+}
@@ -0,0 +1,77 @@
+package services_test
+
+import (
+	"microsservico-encoder/application/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOutputPath(t *testing.T) {
+	variables := services.OutputPathVariables{
+		ResourceID: "resource-1",
+		VideoID:    "video-1",
+		JobID:      "job-1",
+		Profile:    "hd",
+		Date:       time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+
+	path, err := services.ResolveOutputPath("", variables)
+	require.Nil(t, err)
+	require.Equal(t, "video-1", path)
+
+	path, err = services.ResolveOutputPath("tenant-a/{date}/{resource_id}/{profile}/{video_id}", variables)
+	require.Nil(t, err)
+	require.Equal(t, "tenant-a/2026/10/18/resource-1/hd/video-1", path)
+
+	path, err = services.ResolveOutputPath("{profile}/{job_id}", variables)
+	require.Nil(t, err)
+	require.Equal(t, "hd/job-1", path)
+
+	for _, template := range []string{
+		"../{video_id}",
+		"/{video_id}",
+		"a//{video_id}",
+		"a\\{video_id}",
+		"{unknown}/{video_id}",
+		"{video_id}/",
+	} {
+		_, err = services.ResolveOutputPath(template, variables)
+		require.Error(t, err, template)
+	}
+
+	// Templates sem {video_id} ou {job_id} fariam jobs concorrentes gravarem no mesmo caminho.
+	for _, template := range []string{
+		"{resource_id}",
+		"{profile}/{date}",
+		"videos",
+	} {
+		_, err = services.ResolveOutputPath(template, variables)
+		require.Error(t, err, template)
+	}
+
+	// Valores vindos da mensagem também são validados após a substituição.
+	variables.ResourceID = "../../other-tenant"
+	_, err = services.ResolveOutputPath("{resource_id}/{video_id}", variables)
+	require.Error(t, err)
+}
+
+func TestResolveOutputBucket(t *testing.T) {
+	setEnv(t, "outputBucketName", "default-bucket")
+	setEnv(t, "OUTPUT_BUCKETS_ALLOWED", "tenant-a-videos, tenant-b-videos")
+
+	bucket, err := services.ResolveOutputBucket("")
+	require.Nil(t, err)
+	require.Equal(t, "default-bucket", bucket)
+
+	bucket, err = services.ResolveOutputBucket("tenant-b-videos")
+	require.Nil(t, err)
+	require.Equal(t, "tenant-b-videos", bucket)
+
+	_, err = services.ResolveOutputBucket("someone-elses-bucket")
+	require.Error(t, err)
+
+	_, err = services.ResolveOutputBucket("Invalid/Bucket")
+	require.Error(t, err)
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// purgerLockName é o nome do lock que garante uma única instância do purger em execução.
+const purgerLockName = "retention-purger"
+
+// purgerBatchSize é o tamanho das páginas de registros avaliadas pelo purger.
+const purgerBatchSize = 100
+
+// errSharedOutputPath indica que o caminho de saída do job também é usado por outro job que não está sendo removido.
+var errSharedOutputPath = errors.New("output path is shared with another job")
+
+/*
+Purger aplica as regras de retenção: remove os registros de jobs e vídeos pelos
+repositórios e os arquivos de saída pelo cliente de armazenamento.
+Cada remoção é registrada no log de auditoria (purge_audits). Em modo de simulação
+(DryRun), as ações são apenas registradas, sem remover nada.
+Um lock no banco garante que apenas uma instância execute o purger por vez.
+*/
+type Purger struct {
+	Rules           []RetentionRule
+	JobRepository   repositories.JobRepository
+	VideoRepository repositories.VideoRepository
+	Audit           repositories.PurgeAuditRepository
+	Storage         storage.Client // Cliente usado para listar e remover os arquivos de saída
+	Locks           repositories.LockRepository
+	DryRun          bool
+	Interval        time.Duration
+	Owner           string // Identificador desta instância no lock
+}
+
+/*
+NewPurger cria um Purger configurado pelas variáveis de ambiente RETENTION_RULES
+(lista de regras em JSON, ver RetentionRule), RETENTION_DRY_RUN e RETENTION_INTERVAL.
+*/
+func NewPurger(db *gorm.DB, client storage.Client) (*Purger, error) {
+	rules, err := ParseRetentionRules(os.Getenv("RETENTION_RULES"))
+	if err != nil {
+		return nil, err
+	}
+
+	dryRun, _ := strconv.ParseBool(os.Getenv("RETENTION_DRY_RUN"))
+
+	interval, err := time.ParseDuration(os.Getenv("RETENTION_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = time.Hour
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Purger{
+		Rules:           rules,
+		JobRepository:   repositories.JobRepositoryDb{Db: db},
+		VideoRepository: repositories.VideoRepositoryDb{Db: db},
+		Audit:           repositories.PurgeAuditRepositoryDb{Db: db},
+		Storage:         client,
+		Locks:           repositories.LockRepositoryDb{Db: db},
+		DryRun:          dryRun,
+		Interval:        interval,
+		Owner:           hostname + "-" + uuid.NewV4().String(),
+	}, nil
+}
+
+// Run executa o purger a cada Interval até que o contexto seja cancelado.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Purge()
+		if err != nil {
+			log.Printf("error applying retention rules: %v", err)
+		} else if purged > 0 {
+			log.Printf("retention: %d item(s) purged (dry run: %v)", purged, p.DryRun)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+Purge aplica todas as regras, se esta instância obtiver o lock, e retorna a
+quantidade de itens removidos (ou que seriam removidos, em modo de simulação).
+Falhas em um item são registradas em log e não interrompem a regra.
+*/
+func (p *Purger) Purge() (int, error) {
+	acquired, err := p.Locks.Acquire(purgerLockName, p.Owner, p.Interval)
+	if err != nil {
+		return 0, err
+	}
+
+	if !acquired {
+		return 0, nil
+	}
+	defer p.Locks.Release(purgerLockName, p.Owner)
+
+	now := time.Now()
+	total := 0
+
+	for _, rule := range p.Rules {
+		purged, err := p.purgeRule(rule, rule.cutoff(now))
+		total += purged
+
+		if err != nil {
+			return total, fmt.Errorf("retention rule %v: %w", rule.Name, err)
+		}
+	}
+
+	return total, nil
+}
+
+/*
+purgeRule percorre, em páginas, os jobs ou vídeos criados antes de cutoff que
+atendem à regra. Os registros removidos saem da listagem, então o deslocamento
+avança apenas pelos que permanecem.
+*/
+func (p *Purger) purgeRule(rule RetentionRule, cutoff time.Time) (int, error) {
+	filter := repositories.ListFilter{Status: rule.Status, Tag: rule.Tag, CreatedTo: cutoff, Limit: purgerBatchSize}
+	purged := 0
+
+	for {
+		var kept, found int
+
+		if rule.Target == RetentionTargetVideos {
+			videos, err := p.VideoRepository.List(filter)
+			if err != nil {
+				return purged, err
+			}
+
+			found = len(videos)
+			for _, video := range videos {
+				ok := p.purgeVideo(rule, video)
+				if ok {
+					purged++
+				}
+
+				if !ok || p.DryRun {
+					kept++
+				}
+			}
+		} else {
+			jobs, err := p.JobRepository.List(filter)
+			if err != nil {
+				return purged, err
+			}
+
+			found = len(jobs)
+			for _, job := range jobs {
+				var ok bool
+				if rule.Target == RetentionTargetJobs {
+					ok = p.purgeJob(rule, job)
+				} else {
+					ok = p.purgeJobOutputs(rule, job)
+				}
+
+				if ok {
+					purged++
+				}
+
+				// Os jobs cujos arquivos foram removidos continuam na listagem.
+				if !ok || p.DryRun || rule.Target == RetentionTargetOutputs {
+					kept++
+				}
+			}
+		}
+
+		if found < purgerBatchSize {
+			return purged, nil
+		}
+
+		filter.Offset += kept
+	}
+}
+
+// purgeJob remove o registro de um job finalizado.
+func (p *Purger) purgeJob(rule RetentionRule, job *domain.Job) bool {
+	if !job.Finished() {
+		return false
+	}
+
+	if !p.DryRun {
+		err := p.JobRepository.Delete(job.ID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			log.Printf("retention rule %v: error deleting job %v: %v", rule.Name, job.ID, err)
+			return false
+		}
+	}
+
+	p.audit(rule, domain.PurgeActionDeleteJob, job.ID, fmt.Sprintf("status %v, created at %v", job.Status, job.CreatedAt.Format(time.RFC3339)))
+
+	return true
+}
+
+/*
+purgeJobOutputs remove os arquivos de saída de um job finalizado e registra a data
+da remoção no job, para que ele não seja avaliado novamente.
+*/
+func (p *Purger) purgeJobOutputs(rule RetentionRule, job *domain.Job) bool {
+	if !job.Finished() || job.OutputsPurgedAt != nil {
+		return false
+	}
+
+	objects, err := p.deleteOutputs(job, job)
+	if err != nil {
+		log.Printf("retention rule %v: error deleting outputs of job %v: %v", rule.Name, job.ID, err)
+		return false
+	}
+
+	if !p.DryRun {
+		now := time.Now()
+		job.OutputsPurgedAt = &now
+
+		_, err = p.JobRepository.Update(job)
+		if err != nil {
+			log.Printf("retention rule %v: error updating job %v: %v", rule.Name, job.ID, err)
+		}
+	}
+
+	p.audit(rule, domain.PurgeActionDeleteOutputs, job.ID, outputsDetail(job, objects))
+
+	return true
+}
+
+/*
+purgeVideo remove um vídeo cujos jobs estão todos finalizados, junto com os jobs e
+os arquivos de saída desses jobs. Se algum arquivo não puder ser removido, o vídeo
+é mantido para que a remoção seja tentada novamente na próxima execução.
+*/
+func (p *Purger) purgeVideo(rule RetentionRule, video *domain.Video) bool {
+	for _, job := range video.Jobs {
+		if !job.Finished() {
+			return false
+		}
+	}
+
+	objects := 0
+	for _, job := range video.Jobs {
+		if job.OutputsPurgedAt != nil {
+			continue
+		}
+
+		deleted, err := p.deleteOutputs(job, video.Jobs...)
+		if err != nil {
+			log.Printf("retention rule %v: error deleting outputs of job %v: %v", rule.Name, job.ID, err)
+			return false
+		}
+		objects += deleted
+	}
+
+	if !p.DryRun {
+		err := p.VideoRepository.Delete(video.ID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			log.Printf("retention rule %v: error deleting video %v: %v", rule.Name, video.ID, err)
+			return false
+		}
+	}
+
+	p.audit(rule, domain.PurgeActionDeleteVideo, video.ID,
+		fmt.Sprintf("resource %v, %d job(s), %d output object(s)", video.ResourceID, len(video.Jobs), objects))
+
+	return true
+}
+
+/*
+deleteOutputs remove os objetos sob o caminho de saída do job e retorna a quantidade
+de objetos removidos (ou que seriam removidos, em modo de simulação).
+Jobs sem bucket ou caminho de saída não têm arquivos a remover.
+Os objetos de cada job não são registrados, então o prefixo inteiro é removido: se
+outro job fora de purged (os jobs removidos nesta ação) gravar no mesmo bucket e
+caminho, nada é removido e errSharedOutputPath é retornado.
+*/
+func (p *Purger) deleteOutputs(job *domain.Job, purged ...*domain.Job) (int, error) {
+	if job.OutputBucket == "" || job.OutputBucketPath == "" {
+		return 0, nil
+	}
+
+	sharing, err := p.JobRepository.FindByOutput(job.OutputBucket, job.OutputBucketPath)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, other := range sharing {
+		if !containsJob(purged, other.ID) {
+			log.Printf("warning: retention: outputs of job %v in %v/%v are also used by job %v, refusing to delete them",
+				job.ID, job.OutputBucket, job.OutputBucketPath, other.ID)
+			return 0, fmt.Errorf("%w: %v", errSharedOutputPath, other.ID)
+		}
+	}
+
+	ctx := context.Background()
+
+	objects, err := p.Storage.List(ctx, job.OutputBucket, job.OutputBucketPath+"/")
+	if err != nil {
+		return 0, err
+	}
+
+	if p.DryRun {
+		return len(objects), nil
+	}
+
+	for _, object := range objects {
+		err = p.Storage.Delete(ctx, job.OutputBucket, object)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(objects), nil
+}
+
+// containsJob verifica se o job com o ID informado está na lista.
+func containsJob(jobs []*domain.Job, id string) bool {
+	for _, job := range jobs {
+		if job.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outputsDetail descreve os arquivos de saída removidos de um job, para a auditoria.
+func outputsDetail(job *domain.Job, objects int) string {
+	return fmt.Sprintf("%d object(s) in %v/%v", objects, job.OutputBucket, job.OutputBucketPath)
+}
+
+// audit registra a ação no log da aplicação e na tabela de auditoria; falhas são apenas registradas em log.
+func (p *Purger) audit(rule RetentionRule, action string, targetID string, detail string) {
+	log.Printf("retention rule %v: %v %v (%v, dry run: %v)", rule.Name, action, targetID, detail, p.DryRun)
+
+	if p.Audit == nil {
+		return
+	}
+
+	_, err := p.Audit.Insert(domain.NewPurgeAudit(rule.Name, action, targetID, detail, p.DryRun))
+	if err != nil {
+		log.Printf("error recording purge audit for %v: %v", targetID, err)
+	}
+}
@@ -0,0 +1,267 @@
+package services_test
+
+import (
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// insertPurgeJob insere um vídeo com a tag informada e um job criado há days dias, com dois arquivos de saída.
+func insertPurgeJob(t *testing.T, db *gorm.DB, client *storage.MemoryClient, tag string, status string, days int) *domain.Job {
+	createdAt := time.Now().AddDate(0, 0, -days)
+
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = "resource"
+	video.FilePath = "video.mp4"
+	video.CreatedAt = createdAt
+	video.SetTags([]string{tag})
+	_, err := repositories.VideoRepositoryDb{Db: db}.Insert(video)
+	require.Nil(t, err)
+
+	job, err := domain.NewJob(video.ID, status, video)
+	require.Nil(t, err)
+	job.OutputBucket = "out"
+	job.CreatedAt = createdAt
+	_, err = repositories.JobRepositoryDb{Db: db}.Insert(job)
+	require.Nil(t, err)
+
+	client.Put("out", video.ID+"/stream.mpd", []byte("mpd"))
+	client.Put("out", video.ID+"/master.m3u8", []byte("m3u8"))
+
+	return job
+}
+
+func newTestPurger(db *gorm.DB, client storage.Client, rules ...services.RetentionRule) *services.Purger {
+	return &services.Purger{
+		Rules:           rules,
+		JobRepository:   repositories.JobRepositoryDb{Db: db},
+		VideoRepository: repositories.VideoRepositoryDb{Db: db},
+		Audit:           repositories.PurgeAuditRepositoryDb{Db: db},
+		Storage:         client,
+		Locks:           repositories.LockRepositoryDb{Db: db},
+		Interval:        time.Minute,
+		Owner:           "test",
+	}
+}
+
+/*
+TestPurgerDeletesJobsAndOutputs verifica que as regras removem apenas os jobs
+finalizados, antigos e com o status ou a tag da regra, registrando cada ação.
+*/
+func TestPurgerDeletesJobsAndOutputs(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	oldFailed := insertPurgeJob(t, db, client, "", domain.JobStatusFailed, 40)
+	recentFailed := insertPurgeJob(t, db, client, "", domain.JobStatusFailed, 10)
+	oldPromo := insertPurgeJob(t, db, client, "promo", domain.JobStatusCompleted, 100)
+	runningPromo := insertPurgeJob(t, db, client, "promo", domain.JobStatusEncoding, 100)
+
+	purger := newTestPurger(db, client,
+		services.RetentionRule{Name: "failed-jobs", Target: services.RetentionTargetJobs, Status: domain.JobStatusFailed, OlderThanDays: 30},
+		services.RetentionRule{Name: "promo-outputs", Target: services.RetentionTargetOutputs, Tag: "promo", OlderThanDays: 90},
+	)
+
+	purged, err := purger.Purge()
+	require.Nil(t, err)
+	require.Equal(t, 2, purged)
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	_, err = jobs.Find(oldFailed.ID)
+	require.ErrorIs(t, err, repositories.ErrNotFound)
+
+	_, err = jobs.Find(recentFailed.ID)
+	require.Nil(t, err)
+
+	job, err := jobs.Find(oldPromo.ID)
+	require.Nil(t, err)
+	require.NotNil(t, job.OutputsPurgedAt)
+
+	// Apenas os arquivos do job promo finalizado são removidos.
+	require.ElementsMatch(t, []string{
+		"out/" + oldFailed.OutputBucketPath + "/master.m3u8",
+		"out/" + oldFailed.OutputBucketPath + "/stream.mpd",
+		"out/" + recentFailed.OutputBucketPath + "/master.m3u8",
+		"out/" + recentFailed.OutputBucketPath + "/stream.mpd",
+		"out/" + runningPromo.OutputBucketPath + "/master.m3u8",
+		"out/" + runningPromo.OutputBucketPath + "/stream.mpd",
+	}, client.Objects())
+
+	audits, err := repositories.PurgeAuditRepositoryDb{Db: db}.FindByRule("promo-outputs")
+	require.Nil(t, err)
+	require.Len(t, audits, 1)
+	require.Equal(t, domain.PurgeActionDeleteOutputs, audits[0].Action)
+	require.Equal(t, oldPromo.ID, audits[0].TargetID)
+	require.Contains(t, audits[0].Detail, "2 object(s)")
+	require.False(t, audits[0].DryRun)
+
+	// Os jobs já tratados não são tratados de novo.
+	purged, err = purger.Purge()
+	require.Nil(t, err)
+	require.Equal(t, 0, purged)
+}
+
+/*
+TestPurgerDeletesVideos verifica que a regra de vídeos remove os vídeos com todos os
+jobs finalizados, junto com os jobs e os arquivos de saída.
+*/
+func TestPurgerDeletesVideos(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	finished := insertPurgeJob(t, db, client, "promo", domain.JobStatusCompleted, 100)
+	running := insertPurgeJob(t, db, client, "promo", domain.JobStatusUploading, 100)
+
+	purger := newTestPurger(db, client,
+		services.RetentionRule{Name: "promo-videos", Target: services.RetentionTargetVideos, Tag: "promo", OlderThanDays: 90},
+	)
+
+	purged, err := purger.Purge()
+	require.Nil(t, err)
+	require.Equal(t, 1, purged)
+
+	videos := repositories.VideoRepositoryDb{Db: db}
+	_, err = videos.Find(finished.VideoID)
+	require.ErrorIs(t, err, repositories.ErrNotFound)
+
+	_, err = repositories.JobRepositoryDb{Db: db}.Find(finished.ID)
+	require.ErrorIs(t, err, repositories.ErrNotFound)
+
+	_, err = videos.Find(running.VideoID)
+	require.Nil(t, err)
+	require.Len(t, client.Objects(), 2)
+}
+
+// insertSharedJob insere outro job do vídeo do job informado, criado há days dias, que grava no mesmo caminho de saída.
+func insertSharedJob(t *testing.T, db *gorm.DB, job *domain.Job, status string, days int) *domain.Job {
+	shared, err := domain.NewJob(job.OutputBucketPath, status, job.Video)
+	require.Nil(t, err)
+	shared.OutputBucket = job.OutputBucket
+	shared.CreatedAt = time.Now().AddDate(0, 0, -days)
+	_, err = repositories.JobRepositoryDb{Db: db}.Insert(shared)
+	require.Nil(t, err)
+
+	return shared
+}
+
+/*
+TestPurgerKeepsSharedOutputs verifica que os arquivos de um prefixo compartilhado por
+outro job que a retenção mantém não são removidos, e que os de um vídeo removido com
+todos os jobs que compartilham o prefixo são.
+*/
+func TestPurgerKeepsSharedOutputs(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	old := insertPurgeJob(t, db, client, "promo", domain.JobStatusCompleted, 100)
+	recent := insertSharedJob(t, db, old, domain.JobStatusCompleted, 10)
+
+	purger := newTestPurger(db, client,
+		services.RetentionRule{Name: "promo-outputs", Target: services.RetentionTargetOutputs, Tag: "promo", OlderThanDays: 90},
+	)
+
+	purged, err := purger.Purge()
+	require.Nil(t, err)
+	require.Equal(t, 0, purged)
+	require.Len(t, client.Objects(), 2)
+
+	jobs := repositories.JobRepositoryDb{Db: db}
+	for _, id := range []string{old.ID, recent.ID} {
+		job, err := jobs.Find(id)
+		require.Nil(t, err)
+		require.Nil(t, job.OutputsPurgedAt)
+	}
+
+	purger.Rules = []services.RetentionRule{
+		{Name: "promo-videos", Target: services.RetentionTargetVideos, Tag: "promo", OlderThanDays: 90},
+	}
+
+	purged, err = purger.Purge()
+	require.Nil(t, err)
+	require.Equal(t, 1, purged)
+	require.Empty(t, client.Objects())
+
+	_, err = jobs.Find(recent.ID)
+	require.ErrorIs(t, err, repositories.ErrNotFound)
+}
+
+// TestPurgerDryRun verifica que o modo de simulação apenas registra as ações.
+func TestPurgerDryRun(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	job := insertPurgeJob(t, db, client, "promo", domain.JobStatusCompleted, 100)
+
+	purger := newTestPurger(db, client,
+		services.RetentionRule{Name: "old-jobs", Target: services.RetentionTargetJobs, OlderThanDays: 30},
+		services.RetentionRule{Name: "promo-outputs", Target: services.RetentionTargetOutputs, Tag: "promo", OlderThanDays: 30},
+	)
+	purger.DryRun = true
+
+	purged, err := purger.Purge()
+	require.Nil(t, err)
+	require.Equal(t, 2, purged)
+
+	found, err := repositories.JobRepositoryDb{Db: db}.Find(job.ID)
+	require.Nil(t, err)
+	require.Nil(t, found.OutputsPurgedAt)
+	require.Len(t, client.Objects(), 2)
+
+	audits, err := repositories.PurgeAuditRepositoryDb{Db: db}.FindByRule("old-jobs")
+	require.Nil(t, err)
+	require.Len(t, audits, 1)
+	require.Equal(t, domain.PurgeActionDeleteJob, audits[0].Action)
+	require.True(t, audits[0].DryRun)
+}
+
+// TestParseRetentionRules verifica o parse e a validação das regras de retenção.
+func TestParseRetentionRules(t *testing.T) {
+	rules, err := services.ParseRetentionRules(`[{"name": "failed", "target": "jobs", "status": "FAILED", "older_than_days": 30}]`)
+	require.Nil(t, err)
+	require.Equal(t, []services.RetentionRule{{Name: "failed", Target: "jobs", Status: "FAILED", OlderThanDays: 30}}, rules)
+
+	rules, err = services.ParseRetentionRules("")
+	require.Nil(t, err)
+	require.Empty(t, rules)
+
+	invalid := []string{
+		`{"name": "x"}`,
+		`[{"target": "jobs", "older_than_days": 1}]`,
+		`[{"name": "x", "target": "files", "older_than_days": 1}]`,
+		`[{"name": "x", "target": "jobs", "status": "ENCODING", "older_than_days": 1}]`,
+		`[{"name": "x", "target": "jobs", "tag": "Not A Tag", "older_than_days": 1}]`,
+		`[{"name": "x", "target": "jobs"}]`,
+		`[{"name": "x", "target": "jobs", "older_than_days": 1}, {"name": "x", "target": "videos", "older_than_days": 1}]`,
+	}
+
+	for _, data := range invalid {
+		_, err = services.ParseRetentionRules(data)
+		require.Error(t, err, data)
+	}
+}
+
+// TestParseJobMessageTags verifica que as tags da mensagem são validadas e levadas ao vídeo.
+func TestParseJobMessageTags(t *testing.T) {
+	broker := queue.NewMemoryBroker(2)
+
+	jobMessage, err := services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tags": ["promo", "q4-2024"]}`), nil))
+	require.Nil(t, err)
+	require.True(t, jobMessage.Video().HasTag("q4-2024"))
+
+	_, err = services.ParseJobMessage(broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tags": ["a,b"]}`), nil))
+	require.Error(t, err)
+}
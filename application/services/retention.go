@@ -0,0 +1,92 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"microsservico-encoder/domain"
+	"time"
+)
+
+// Alvos das regras de retenção.
+const (
+	RetentionTargetJobs    = "jobs"    // Remove os registros dos jobs
+	RetentionTargetVideos  = "videos"  // Remove os vídeos, seus jobs e os arquivos de saída desses jobs
+	RetentionTargetOutputs = "outputs" // Remove apenas os arquivos de saída dos jobs, mantendo os registros
+)
+
+/*
+RetentionRule define o que o Purger remove: os registros (ou os arquivos de saída)
+criados há mais de OlderThanDays dias, opcionalmente filtrados pelo status do job e
+pela tag do vídeo. Apenas jobs finalizados (COMPLETED ou FAILED) são removidos.
+Exemplo (RETENTION_RULES):
+
+	[
+	    {"name": "failed-jobs", "target": "jobs", "status": "FAILED", "older_than_days": 30},
+	    {"name": "promo-outputs", "target": "outputs", "tag": "promo", "older_than_days": 90}
+	]
+*/
+type RetentionRule struct {
+	Name          string `json:"name"`
+	Target        string `json:"target"`
+	Status        string `json:"status"` // Status do job (para vídeos: possui algum job com o status)
+	Tag           string `json:"tag"`
+	OlderThanDays int    `json:"older_than_days"`
+}
+
+// Validate verifica se a regra está completa e se refere apenas a jobs finalizados.
+func (r RetentionRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("retention rule without name")
+	}
+
+	if r.Target != RetentionTargetJobs && r.Target != RetentionTargetVideos && r.Target != RetentionTargetOutputs {
+		return fmt.Errorf("retention rule %v: invalid target: %q", r.Name, r.Target)
+	}
+
+	if r.Status != "" && r.Status != domain.JobStatusCompleted && r.Status != domain.JobStatusFailed {
+		return fmt.Errorf("retention rule %v: status must be %v or %v", r.Name, domain.JobStatusCompleted, domain.JobStatusFailed)
+	}
+
+	if r.Tag != "" && !tagPattern.MatchString(r.Tag) {
+		return fmt.Errorf("retention rule %v: invalid tag: %q", r.Name, r.Tag)
+	}
+
+	if r.OlderThanDays <= 0 {
+		return fmt.Errorf("retention rule %v: older_than_days must be positive", r.Name)
+	}
+
+	return nil
+}
+
+// cutoff retorna a data limite da regra: são removidos os registros criados antes dela.
+func (r RetentionRule) cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -r.OlderThanDays)
+}
+
+// ParseRetentionRules faz o parse e valida a lista de regras em JSON; uma lista vazia não tem regras.
+func ParseRetentionRules(data string) ([]RetentionRule, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var rules []RetentionRule
+	err := json.Unmarshal([]byte(data), &rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention rules: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, rule := range rules {
+		err = rule.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate retention rule: %v", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	return rules, nil
+}
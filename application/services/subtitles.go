@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// Papéis aceitos para as faixas de legenda, sinalizados no manifesto (DASH Role).
+const (
+	SubtitleRoleSubtitle = "subtitle"
+	SubtitleRoleCaption  = "caption"
+)
+
+// languageCode aceita códigos de idioma BCP 47 simples, como pt, en-US ou pt-BR.
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+/*
+SubtitleTrack representa uma legenda (SRT ou WebVTT) anexada ao job.
+FilePath segue as mesmas regras do arquivo de vídeo (gs://, s3://, file://, http(s):// ou
+caminho no bucket de entrada); LocalPath é o arquivo WebVTT gerado no download.
+*/
+type SubtitleTrack struct {
+	FilePath  string `json:"file_path"`
+	Language  string `json:"language"`
+	Role      string `json:"role,omitempty"`
+	LocalPath string `json:"-"`
+}
+
+/*
+Validate verifica se a legenda informa o arquivo e um código de idioma válido
+e define o papel padrão (subtitle) quando ele não é informado.
+*/
+func (s *SubtitleTrack) Validate() error {
+	if s.FilePath == "" {
+		return fmt.Errorf("subtitle file_path is required")
+	}
+
+	if !languageCode.MatchString(s.Language) {
+		return fmt.Errorf("invalid subtitle language: %q", s.Language)
+	}
+
+	if s.Role == "" {
+		s.Role = SubtitleRoleSubtitle
+	}
+
+	if s.Role != SubtitleRoleSubtitle && s.Role != SubtitleRoleCaption {
+		return fmt.Errorf("invalid subtitle role: %q", s.Role)
+	}
+
+	return nil
+}
+
+/*
+DownloadSubtitles baixa as legendas do job e as converte para WebVTT, o formato
+aceito pelo mp4dash. Legendas SRT são convertidas; arquivos que não são legendas
+são recusados. Os arquivos gerados são removidos em Finish.
+*/
+func (v *VideoService) DownloadSubtitles(bucketName string) error {
+
+	for i := range v.Subtitles {
+		subtitle := &v.Subtitles[i]
+
+		format := ""
+		source, err := v.fetch(subtitle.FilePath, bucketName, func(header []byte) (string, error) {
+			format = utils.DetectSubtitleFormat(header)
+			if format == "" {
+				return "", fmt.Errorf("unsupported subtitle content type: %v", http.DetectContentType(header))
+			}
+
+			return v.localPath(fmt.Sprintf(".subtitle%d.%v", i, format)), nil
+		})
+		if err != nil {
+			return fmt.Errorf("error downloading subtitle %v: %v", subtitle.FilePath, err)
+		}
+
+		subtitle.LocalPath = source
+
+		if format == "srt" {
+			subtitle.LocalPath, err = v.convertSRT(source, v.localPath(fmt.Sprintf(".subtitle%d.vtt", i)))
+			if err != nil {
+				return fmt.Errorf("error converting subtitle %v: %v", subtitle.FilePath, err)
+			}
+		}
+
+		log.Printf("subtitle %v (%v) of video %v has been stored", subtitle.FilePath, subtitle.Language, v.Video.ID)
+	}
+
+	return nil
+}
+
+// convertSRT converte o arquivo SRT em source para WebVTT em target.
+func (v *VideoService) convertSRT(source string, target string) (string, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	v.addTemporaryFile(target)
+
+	err = utils.SRTToWebVTT(in, out)
+	if err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+/*
+subtitleInputs monta as entradas de legenda do mp4dash, no formato
+[+format=webvtt,+language=<idioma>,+role=<papel>]<arquivo>.
+*/
+func (v *VideoService) subtitleInputs() []string {
+	inputs := make([]string, 0, len(v.Subtitles))
+
+	for _, subtitle := range v.Subtitles {
+		inputs = append(inputs, fmt.Sprintf("[+format=webvtt,+language=%v,+role=%v]%v",
+			subtitle.Language, subtitle.Role, subtitle.LocalPath))
+	}
+
+	return inputs
+}
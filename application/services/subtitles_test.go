@@ -0,0 +1,97 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestVideoServiceSubtitles verifica que as legendas são baixadas, que o SRT é
+convertido para WebVTT e que o mp4dash recebe cada faixa com o idioma e o papel.
+*/
+func TestVideoServiceSubtitles(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+	client.Put("bucket", "video.pt.srt", []byte("1\r\n00:00:01,000 --> 00:00:02,000\r\nOlá\r\n"))
+	client.Put("bucket", "video.en.vtt", []byte("WEBVTT\n\n00:01.000 --> 00:02.000\nHello\n"))
+	client.Put("bucket", "video.es.srt", fakeMp4("not a subtitle"))
+
+	video, repo := prepare()
+	video.FilePath = "video.mp4"
+
+	runner := &recordingRunner{}
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.Subtitles = []services.SubtitleTrack{
+		{FilePath: "video.pt.srt", Language: "pt-BR", Role: services.SubtitleRoleSubtitle},
+		{FilePath: "gs://bucket/video.en.vtt", Language: "en", Role: services.SubtitleRoleCaption},
+	}
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.DownloadSubtitles("bucket"))
+
+	for _, subtitle := range videoService.Subtitles {
+		require.True(t, strings.HasSuffix(subtitle.LocalPath, ".vtt"))
+		content, err := ioutil.ReadFile(subtitle.LocalPath)
+		require.Nil(t, err)
+		require.True(t, strings.HasPrefix(string(content), "WEBVTT"))
+	}
+
+	content, err := ioutil.ReadFile(videoService.Subtitles[0].LocalPath)
+	require.Nil(t, err)
+	require.Contains(t, string(content), "00:00:01.000 --> 00:00:02.000")
+
+	require.Nil(t, videoService.Fragment())
+	require.Nil(t, videoService.Encode())
+
+	mp4dash := runner.commands[len(runner.commands)-1]
+	require.Contains(t, mp4dash, "[+format=webvtt,+language=pt-BR,+role=subtitle]"+videoService.Subtitles[0].LocalPath)
+	require.Contains(t, mp4dash, "[+format=webvtt,+language=en,+role=caption]"+videoService.Subtitles[1].LocalPath)
+	require.Contains(t, mp4dash, "--hls")
+
+	require.Nil(t, videoService.Finish())
+	files, err := ioutil.ReadDir(localStoragePath)
+	require.Nil(t, err)
+	require.Empty(t, files)
+
+	videoService.Subtitles = []services.SubtitleTrack{{FilePath: "video.es.srt", Language: "es"}}
+	err = videoService.DownloadSubtitles("bucket")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported subtitle content type")
+}
+
+/*
+TestParseJobMessageValidatesSubtitles verifica o idioma e o papel das legendas da mensagem.
+*/
+func TestParseJobMessageValidatesSubtitles(t *testing.T) {
+	broker := queue.NewMemoryBroker(3)
+
+	valid := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "subtitles": [{"file_path": "a.srt", "language": "pt-BR"}]}`), nil)
+	jobMessage, err := services.ParseJobMessage(valid)
+	require.Nil(t, err)
+	require.Equal(t, services.SubtitleRoleSubtitle, jobMessage.Subtitles[0].Role)
+
+	invalidLanguage := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "subtitles": [{"file_path": "a.srt", "language": "Portuguese"}]}`), nil)
+	_, err = services.ParseJobMessage(invalidLanguage)
+	require.Error(t, err)
+
+	invalidRole := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "subtitles": [{"file_path": "a.srt", "language": "pt", "role": "karaoke"}]}`), nil)
+	_, err = services.ParseJobMessage(invalidRole)
+	require.Error(t, err)
+}
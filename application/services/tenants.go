@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/framework/queue"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTenantID é o tenant dos jobs cuja mensagem não informa um.
+const DefaultTenantID = "default"
+
+// TenantIDHeader é o cabeçalho opcional que informa o tenant quando o corpo da mensagem não o traz.
+const TenantIDHeader = "x-tenant-id"
+
+// tenantIDPattern define o formato aceito para os identificadores de tenant.
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ErrTenantBusy indica que o tenant atingiu o limite de jobs simultâneos; a mensagem deve voltar à fila.
+var ErrTenantBusy = errors.New("tenant reached its concurrent jobs limit")
+
+// ErrQuotaExceeded indica que o tenant esgotou os minutos de processamento do dia.
+var ErrQuotaExceeded = errors.New("tenant exceeded its daily minutes quota")
+
+/*
+Tenant define as cotas e o isolamento de um tenant. Cotas com valor 0 não limitam.
+Exemplo (TENANTS):
+
+	[
+	    {"id": "acme", "max_concurrent_jobs": 2, "daily_minutes": 600,
+	     "output_bucket": "acme-videos", "output_prefix": "acme", "routing_key": "acme.jobs"}
+	]
+*/
+type Tenant struct {
+	ID                string  `json:"id"`
+	MaxConcurrentJobs int     `json:"max_concurrent_jobs"` // Jobs não finalizados ao mesmo tempo
+	DailyMinutes      float64 `json:"daily_minutes"`       // Minutos de saída processados por dia (UTC)
+	OutputBucket      string  `json:"output_bucket"`       // Bucket de saída dos jobs, em vez do informado na mensagem
+	OutputPrefix      string  `json:"output_prefix"`       // Prefixo do caminho de saída dos jobs
+	RoutingKey        string  `json:"routing_key"`         // Prefixo das routing keys dos eventos dos jobs
+}
+
+// Validate verifica o identificador, as cotas e os destinos do tenant.
+func (t Tenant) Validate() error {
+	if !tenantIDPattern.MatchString(t.ID) {
+		return fmt.Errorf("invalid tenant id: %q", t.ID)
+	}
+
+	if t.MaxConcurrentJobs < 0 || t.DailyMinutes < 0 {
+		return fmt.Errorf("tenant %v: quotas cannot be negative", t.ID)
+	}
+
+	if t.OutputBucket != "" && !bucketName.MatchString(t.OutputBucket) {
+		return fmt.Errorf("tenant %v: invalid output bucket: %v", t.ID, t.OutputBucket)
+	}
+
+	if t.OutputPrefix != "" {
+		for _, segment := range strings.Split(t.OutputPrefix, "/") {
+			if segment == "" || segment == "." || segment == ".." || !outputPathSegment.MatchString(segment) {
+				return fmt.Errorf("tenant %v: invalid output prefix: %q", t.ID, t.OutputPrefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+outputBucket retorna o bucket de saída de um job do tenant. Com OutputBucket configurado,
+a mensagem só pode omitir o bucket ou informar o próprio bucket do tenant; caso contrário,
+valem as regras de ResolveOutputBucket.
+*/
+func (t Tenant) outputBucket(bucket string) (string, error) {
+	if t.OutputBucket == "" {
+		return ResolveOutputBucket(bucket)
+	}
+
+	if bucket != "" && bucket != t.OutputBucket {
+		return "", fmt.Errorf("output bucket %v is not allowed for tenant %v", bucket, t.ID)
+	}
+
+	return t.OutputBucket, nil
+}
+
+// outputPath acrescenta o prefixo do tenant ao caminho de saída resolvido do job.
+func (t Tenant) outputPath(path string) string {
+	if t.OutputPrefix == "" {
+		return path
+	}
+
+	return t.OutputPrefix + "/" + path
+}
+
+/*
+checkQuotas verifica as cotas do tenant antes da criação de um job, retornando
+ErrTenantBusy ou ErrQuotaExceeded. A duração de um job só é conhecida após a
+conversão, então os jobs em andamento ainda não contam nos minutos do dia.
+A verificação não é atômica: workers concorrentes podem ultrapassar o limite de
+jobs simultâneos por alguns jobs.
+*/
+func (t Tenant) checkQuotas(jobs repositories.JobRepository, now time.Time) error {
+	if t.MaxConcurrentJobs > 0 {
+		active, err := jobs.CountActive(t.ID)
+		if err != nil {
+			return err
+		}
+
+		if active >= t.MaxConcurrentJobs {
+			return fmt.Errorf("tenant %v: %w (%d)", t.ID, ErrTenantBusy, t.MaxConcurrentJobs)
+		}
+	}
+
+	if t.DailyMinutes > 0 {
+		usage, err := jobs.Usage(repositories.ListFilter{TenantID: t.ID, CreatedFrom: now.UTC().Truncate(24 * time.Hour)})
+		if err != nil {
+			return err
+		}
+
+		if len(usage) > 0 && usage[0].Minutes() >= t.DailyMinutes {
+			return fmt.Errorf("tenant %v: %w (%v minutes)", t.ID, ErrQuotaExceeded, t.DailyMinutes)
+		}
+	}
+
+	return nil
+}
+
+// Tenants são os tenants configurados, indexados pelo ID.
+type Tenants map[string]Tenant
+
+/*
+Resolve retorna a configuração do tenant informado (DefaultTenantID, se vazio).
+Sem tenants configurados, qualquer tenant é aceito, sem cotas nem isolamento;
+com tenants configurados, apenas eles e o tenant padrão são aceitos.
+*/
+func (t Tenants) Resolve(id string) (Tenant, error) {
+	if id == "" {
+		id = DefaultTenantID
+	}
+
+	tenant, ok := t[id]
+	if ok {
+		return tenant, nil
+	}
+
+	if len(t) > 0 && id != DefaultTenantID {
+		return Tenant{}, fmt.Errorf("unknown tenant: %v", id)
+	}
+
+	return Tenant{ID: id}, nil
+}
+
+// routingKeys retorna os prefixos de routing key configurados, indexados pelo tenant.
+func (t Tenants) routingKeys() map[string]string {
+	keys := map[string]string{}
+	for id, tenant := range t {
+		if tenant.RoutingKey != "" {
+			keys[id] = tenant.RoutingKey
+		}
+	}
+
+	return keys
+}
+
+// ParseTenants faz o parse e valida a lista de tenants em JSON; uma lista vazia não tem tenants.
+func ParseTenants(data string) (Tenants, error) {
+	tenants := Tenants{}
+	if data == "" {
+		return tenants, nil
+	}
+
+	var list []Tenant
+	err := json.Unmarshal([]byte(data), &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tenants: %w", err)
+	}
+
+	for _, tenant := range list {
+		err = tenant.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := tenants[tenant.ID]; ok {
+			return nil, fmt.Errorf("duplicated tenant: %v", tenant.ID)
+		}
+
+		tenants[tenant.ID] = tenant
+	}
+
+	return tenants, nil
+}
+
+// LoadTenants lê os tenants da variável de ambiente TENANTS (ver Tenant).
+func LoadTenants() (Tenants, error) {
+	return ParseTenants(os.Getenv("TENANTS"))
+}
+
+// tenantIDFromHeaders retorna o tenant do cabeçalho TenantIDHeader ou DefaultTenantID, se ausente.
+func tenantIDFromHeaders(message queue.Message) string {
+	if value, ok := message.Headers()[TenantIDHeader]; ok {
+		if id := fmt.Sprint(value); id != "" {
+			return id
+		}
+	}
+
+	return DefaultTenantID
+}
@@ -0,0 +1,236 @@
+package services_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+runTenantJobs processa as mensagens informadas com o JobManager e retorna os eventos publicados,
+pela routing key. As mensagens adiadas (publicadas na fila de espera) não são eventos e ficam de fora.
+*/
+func runTenantJobs(t *testing.T, db *gorm.DB, client *storage.MemoryClient, broker *queue.MemoryBroker) map[string][]domain.JobEvent {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(localStoragePath) })
+
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "CONCURRENCY_WORKERS", "1")
+	setEnv(t, "TENANT_QUOTA_BACKOFF", "1ms")
+
+	broker.Close()
+
+	messageChannel := make(chan queue.Message)
+	broker.Consume(messageChannel)
+
+	jobManager := services.NewJobManager(db, broker, make(chan services.JobWorkerResult), messageChannel)
+	jobManager.Storage = client
+	jobManager.Runner = fakeRunner{}
+	jobManager.Start()
+
+	events := map[string][]domain.JobEvent{}
+	for _, published := range broker.Published() {
+		if published.RoutingKey == jobManager.DelayRoutingKey {
+			continue
+		}
+
+		var event domain.JobEvent
+		require.Nil(t, json.Unmarshal(published.Publishing.Body, &event))
+		events[published.RoutingKey] = append(events[published.RoutingKey], event)
+	}
+
+	return events
+}
+
+/*
+TestJobManagerIsolatesTenants verifica que os jobs de um tenant usam o bucket, o
+prefixo de saída e a routing key do tenant, que os demais usam os padrões e que as
+falhas de mensagens inválidas vão para o tenant padrão.
+*/
+func TestJobManagerIsolatesTenants(t *testing.T) {
+	setEnv(t, "TENANTS", `[{"id": "news", "output_bucket": "news-videos", "output_prefix": "news", "routing_key": "news.jobs"}]`)
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	client := storage.NewMemoryClient()
+	client.Put(os.Getenv("inputBucketName"), "a.mp4", fakeMp4("a"))
+
+	broker := queue.NewMemoryBroker(5)
+	news := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tenant_id": "news"}`), nil)
+	fromHeader := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4"}`), map[string]interface{}{services.TenantIDHeader: "news"})
+	otherBucket := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tenant_id": "news", "output_bucket": "other"}`), nil)
+	unknown := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tenant_id": "ghost"}`), nil)
+	standard := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4"}`), nil)
+
+	events := runTenantJobs(t, db, client, broker)
+
+	for _, message := range []*queue.MemoryMessage{news, fromHeader, standard} {
+		require.True(t, message.Acked())
+	}
+
+	for _, message := range []*queue.MemoryMessage{otherBucket, unknown} {
+		nacked, requeued := message.Nacked()
+		require.True(t, nacked)
+		require.False(t, requeued)
+	}
+
+	require.Len(t, events["news.jobs.completed"], 2)
+	for _, event := range events["news.jobs.completed"] {
+		require.Equal(t, "news", event.TenantID)
+		require.Equal(t, "https://storage.googleapis.com/news-videos/news/"+event.VideoID+"/stream.mpd", event.Outputs.ManifestURL)
+
+		_, ok := client.Get("news-videos", "news/"+event.VideoID+"/stream.mpd")
+		require.True(t, ok)
+
+		job, err := repositories.JobRepositoryDb{Db: db}.Find(event.JobID)
+		require.Nil(t, err)
+		require.Equal(t, "news", job.TenantID)
+		require.Equal(t, "news", job.Video.TenantID)
+		require.Equal(t, 60.0, job.DurationSeconds)
+	}
+
+	// As mensagens inválidas não publicam na routing key do tenant que apenas declaram.
+	require.Empty(t, events["news.jobs.failed"])
+	require.Len(t, events["jobs.failed"], 2)
+
+	failures := map[string]string{}
+	for _, event := range events["jobs.failed"] {
+		require.Equal(t, services.DefaultTenantID, event.TenantID)
+		require.Equal(t, domain.ErrorCodeInvalidMessage, event.Error.Code)
+		failures[event.Message] = event.Error.Message
+	}
+	require.Contains(t, failures[string(otherBucket.Body())], "not allowed for tenant news")
+	require.Contains(t, failures[string(unknown.Body())], "unknown tenant")
+
+	require.Len(t, events["jobs.completed"], 1)
+	require.Equal(t, services.DefaultTenantID, events["jobs.completed"][0].TenantID)
+	_, ok := client.Get(os.Getenv("outputBucketName"), events["jobs.completed"][0].VideoID+"/stream.mpd")
+	require.True(t, ok)
+}
+
+// insertTenantJob insere um job do tenant com o status e a duração informados, criado agora.
+func insertTenantJob(t *testing.T, db *gorm.DB, tenantID string, status string, seconds float64) {
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = "resource"
+	video.FilePath = "video.mp4"
+	video.TenantID = tenantID
+	_, err := repositories.VideoRepositoryDb{Db: db}.Insert(video)
+	require.Nil(t, err)
+
+	job, err := domain.NewJob("output", status, video)
+	require.Nil(t, err)
+	job.TenantID = tenantID
+	job.DurationSeconds = seconds
+	_, err = repositories.JobRepositoryDb{Db: db}.Insert(job)
+	require.Nil(t, err)
+}
+
+/*
+TestJobManagerEnforcesTenantQuotas verifica que a mensagem de um tenant no limite de
+jobs simultâneos é adiada pela fila de espera sem criar o job, e que a de um tenant sem
+minutos disponíveis no dia é rejeitada com QUOTA_EXCEEDED.
+*/
+func TestJobManagerEnforcesTenantQuotas(t *testing.T) {
+	setEnv(t, "TENANTS", `[{"id": "busy", "max_concurrent_jobs": 1}, {"id": "metered", "daily_minutes": 1.5}]`)
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	insertTenantJob(t, db, "busy", domain.JobStatusEncoding, 0)
+	insertTenantJob(t, db, "metered", domain.JobStatusCompleted, 60)
+	insertTenantJob(t, db, "metered", domain.JobStatusFailed, 30)
+
+	client := storage.NewMemoryClient()
+	client.Put(os.Getenv("inputBucketName"), "a.mp4", fakeMp4("a"))
+
+	broker := queue.NewMemoryBroker(2)
+	busy := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tenant_id": "busy"}`), nil)
+	metered := broker.Enqueue([]byte(`{"resource_id": "a", "file_path": "a.mp4", "tenant_id": "metered"}`), nil)
+
+	events := runTenantJobs(t, db, client, broker)
+
+	nacked, _ := busy.Nacked()
+	require.False(t, nacked)
+	require.True(t, busy.Acked())
+
+	var delayed []queue.PublishedMessage
+	for _, published := range broker.Published() {
+		if published.RoutingKey == queue.DelayQueueName() {
+			delayed = append(delayed, published)
+		}
+	}
+
+	require.Len(t, delayed, 1)
+	require.Equal(t, "", delayed[0].Exchange)
+	require.Equal(t, busy.Body(), delayed[0].Publishing.Body)
+	require.Equal(t, time.Millisecond, delayed[0].Publishing.Expiration)
+
+	nacked, requeued := metered.Nacked()
+	require.True(t, nacked)
+	require.False(t, requeued)
+
+	require.Len(t, events, 1)
+	require.Len(t, events["jobs.failed"], 1)
+	require.Equal(t, services.DefaultTenantID, events["jobs.failed"][0].TenantID)
+	require.Equal(t, domain.ErrorCodeQuotaExceeded, events["jobs.failed"][0].Error.Code)
+	require.Contains(t, events["jobs.failed"][0].Error.Message, "tenant metered")
+
+	usage, err := repositories.JobRepositoryDb{Db: db}.Usage(repositories.ListFilter{CreatedFrom: time.Now().Add(-time.Hour)})
+	require.Nil(t, err)
+	require.Len(t, usage, 2)
+	require.Equal(t, 1, usage[0].Jobs)
+	require.Equal(t, 2, usage[1].Jobs)
+}
+
+// TestParseTenants verifica o parse, a validação e a resolução dos tenants.
+func TestParseTenants(t *testing.T) {
+	tenants, err := services.ParseTenants(`[{"id": "acme", "max_concurrent_jobs": 2, "output_prefix": "acme/videos"}]`)
+	require.Nil(t, err)
+
+	tenant, err := tenants.Resolve("acme")
+	require.Nil(t, err)
+	require.Equal(t, services.Tenant{ID: "acme", MaxConcurrentJobs: 2, OutputPrefix: "acme/videos"}, tenant)
+
+	tenant, err = tenants.Resolve("")
+	require.Nil(t, err)
+	require.Equal(t, services.Tenant{ID: services.DefaultTenantID}, tenant)
+
+	_, err = tenants.Resolve("other")
+	require.Error(t, err)
+
+	// Sem tenants configurados, qualquer tenant é aceito, sem cotas.
+	tenants, err = services.ParseTenants("")
+	require.Nil(t, err)
+	tenant, err = tenants.Resolve("other")
+	require.Nil(t, err)
+	require.Equal(t, services.Tenant{ID: "other"}, tenant)
+
+	invalid := []string{
+		`{"id": "acme"}`,
+		`[{"id": "Not A Tenant"}]`,
+		`[{"id": "acme", "max_concurrent_jobs": -1}]`,
+		`[{"id": "acme", "output_bucket": "Bucket"}]`,
+		`[{"id": "acme", "output_prefix": "../acme"}]`,
+		`[{"id": "acme"}, {"id": "acme"}]`,
+	}
+
+	for _, data := range invalid {
+		_, err = services.ParseTenants(data)
+		require.Error(t, err, data)
+	}
+}
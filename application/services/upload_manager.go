@@ -2,160 +2,282 @@ package services
 
 import (
 	"context"
-	"io"
+	"fmt"
 	"log"
+	"microsservico-encoder/framework/storage"
 	"os"
 	"path/filepath"
-	"runtime"
+	"strconv"
 	"strings"
-
-	"cloud.google.com/go/storage"
+	"sync"
+	"time"
 )
 
 /*
 Struct que representa o processo de upload de um vídeo para um bucket.
-- Paths: caminhos dos arquivos gerados (ex.: fragmentos).
 - VideoPath: caminho base onde os arquivos estão localizados.
 - OutputBucket: nome do bucket de destino.
-- Errors: lista de caminhos que falharam no upload.
+- ObjectPrefix: prefixo dos objetos no bucket; se vazio, o nome da pasta VideoPath.
+- ACL: política de permissão aplicada aos objetos enviados.
+- Client: cliente de armazenamento; se nil, um cliente do GCS é criado e fechado pelo upload.
+- MaxAttempts: quantidade máxima de tentativas por objeto.
+- Backoff: espera antes da segunda tentativa de um objeto; dobra a cada nova tentativa.
+- Progress: chamado ao fim do envio de cada objeto, com sucesso ou não (opcional).
 */
 type VideoUpload struct {
-	Paths        []string
 	VideoPath    string
 	OutputBucket string
-	Errors       []string
+	ObjectPrefix string
+	ACL          storage.ACLPolicy
+	Client       storage.Client
+	MaxAttempts  int
+	Backoff      time.Duration
+	Progress     func()
+}
+
+/*
+UploadResult resume o resultado de um upload:
+os objetos enviados, as falhas, o total de bytes e a duração do processo.
+*/
+type UploadResult struct {
+	Uploaded []string
+	Failed   []UploadFailure
+	Bytes    int64
+	Duration time.Duration
+}
+
+// UploadFailure descreve um arquivo que não pôde ser enviado após todas as tentativas.
+type UploadFailure struct {
+	Path     string
+	Attempts int
+	Error    error
+}
+
+/*
+Err retorna um erro descrevendo todas as falhas do upload, ou nil se todos
+os arquivos foram enviados.
+*/
+func (r *UploadResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(r.Failed))
+	for _, failure := range r.Failed {
+		messages = append(messages, fmt.Sprintf("%v: %v", failure.Path, failure.Error))
+	}
+
+	return fmt.Errorf("%d of %d files failed to upload: %v",
+		len(r.Failed), len(r.Failed)+len(r.Uploaded), strings.Join(messages, "; "))
 }
 
 /*
 Construtor para a struct VideoUpload.
-Retorna uma instância vazia da estrutura.
+Retorna uma instância com a política de ACL pública, o comportamento original,
+e com as tentativas definidas em UPLOAD_MAX_ATTEMPTS e UPLOAD_BACKOFF.
 */
 func NewVideoUpload() *VideoUpload {
-	return &VideoUpload{}
+	maxAttempts, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts < 1 {
+		maxAttempts = 3
+	}
+
+	backoff, err := time.ParseDuration(os.Getenv("UPLOAD_BACKOFF"))
+	if err != nil {
+		backoff = 500 * time.Millisecond
+	}
+
+	return &VideoUpload{
+		ACL:         storage.ACLPublic,
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+	}
 }
 
 /*
 Realiza o upload de um único arquivo (objectPath) para o bucket definido em OutputBucket.
-Cria o objeto no bucket com permissão pública de leitura.
+O nome do objeto é ObjectPrefix seguido do caminho do arquivo relativo a VideoPath
+(ex.: <prefixo>/stream.mpd). Retorna a quantidade de bytes enviados.
 */
-func (vu *VideoUpload) UploadObject(objectPath string, client *storage.Client, ctx context.Context) error {
-	path := strings.Split(objectPath, os.Getenv("localStoragePath")+"/")
-
-	f, err := os.Open(objectPath)
+func (vu *VideoUpload) UploadObject(ctx context.Context, objectPath string) (int64, error) {
+	relative, err := filepath.Rel(vu.VideoPath, objectPath)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer f.Close()
-
-	wc := client.Bucket(vu.OutputBucket).Object(path[1]).NewWriter(ctx)
-	wc.ACL = []storage.ACLRule{{Entity: storage.AllUsers, Role: storage.RoleReader}}
 
-	if _, err = io.Copy(wc, f); err != nil {
-		return err
+	prefix := vu.ObjectPrefix
+	if prefix == "" {
+		prefix = filepath.Base(vu.VideoPath)
 	}
+	object := prefix + "/" + filepath.ToSlash(relative)
 
-	if err := wc.Close(); err != nil {
-		return err
+	f, err := os.Open(objectPath)
+	if err != nil {
+		return 0, err
 	}
+	defer f.Close()
 
-	return nil
+	return vu.Client.Upload(ctx, vu.OutputBucket, object, f, vu.ACL)
 }
 
 /*
-Carrega todos os caminhos de arquivos contidos dentro de VideoPath e
-os armazena no slice Paths, ignorando diretórios.
+Carrega todos os caminhos de arquivos contidos dentro de VideoPath,
+ignorando diretórios.
 */
-func (vu *VideoUpload) loadPaths() error {
+func (vu *VideoUpload) loadPaths() ([]string, error) {
+	var paths []string
+
 	err := filepath.Walk(vu.VideoPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if !info.IsDir() {
-			vu.Paths = append(vu.Paths, path)
+			paths = append(paths, path)
 		}
 		return nil
 	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return paths, nil
 }
 
 /*
 Controla o processo de upload em paralelo dos arquivos encontrados no diretório.
-Cria workers com base no nível de concorrência especificado.
-Envia sinal via doneUpload assim que um erro ocorrer ou todos os uploads forem concluídos.
+Cria workers com base no nível de concorrência especificado; cada arquivo é
+enviado com até MaxAttempts tentativas. Todos os arquivos são processados mesmo
+quando algum falha, e as falhas são reunidas no UploadResult.
+O erro retornado indica apenas problemas para iniciar o upload (listar os arquivos
+ou criar o cliente); falhas de envio devem ser verificadas com UploadResult.Err.
 */
-func (vu *VideoUpload) ProcessUpload(concurrency int, doneUpload chan string) error {
-	in := make(chan int, runtime.NumCPU())
-	returnChannel := make(chan string)
+func (vu *VideoUpload) ProcessUpload(ctx context.Context, concurrency int) (*UploadResult, error) {
+	startedAt := time.Now()
 
-	err := vu.loadPaths()
+	paths, err := vu.loadPaths()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	uploadClient, ctx, err := getClientUpload()
-	if err != nil {
-		return err
+	if vu.Client == nil {
+		client, err := storage.NewGCSClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+
+		// Não altera vu.Client para que o cliente fechado não seja reutilizado.
+		upload := *vu
+		upload.Client = client
+		vu = &upload
 	}
 
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	in := make(chan string)
+	results := make(chan uploadAttempt)
+
+	var workers sync.WaitGroup
 	for process := 0; process < concurrency; process++ {
-		go vu.uploadWorker(in, returnChannel, uploadClient, ctx)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			vu.uploadWorker(ctx, in, results)
+		}()
 	}
 
 	go func() {
-		for x := 0; x < len(vu.Paths); x++ {
-			in <- x
+		defer close(in)
+		for _, path := range paths {
+			in <- path
 		}
 	}()
 
-	countDoneWorker := 0
-	for r := range returnChannel {
-		countDoneWorker++
+	go func() {
+		workers.Wait()
+		close(results)
+	}()
 
-		if r != "" {
-			doneUpload <- r
-			break
+	result := &UploadResult{}
+	for attempt := range results {
+		if vu.Progress != nil {
+			vu.Progress()
 		}
 
-		if countDoneWorker == len(vu.Paths) {
-			close(in)
+		if attempt.err != nil {
+			result.Failed = append(result.Failed, UploadFailure{
+				Path:     attempt.path,
+				Attempts: attempt.attempts,
+				Error:    attempt.err,
+			})
+			continue
 		}
+
+		result.Uploaded = append(result.Uploaded, attempt.path)
+		result.Bytes += attempt.bytes
 	}
 
-	return nil
+	result.Duration = time.Since(startedAt)
+
+	return result, nil
+}
+
+// uploadAttempt é o resultado do envio de um arquivo por um worker.
+type uploadAttempt struct {
+	path     string
+	bytes    int64
+	attempts int
+	err      error
 }
 
 /*
-Worker responsável por fazer o upload dos arquivos indicados pelo canal `in`.
-Se ocorrer erro durante o upload, ele é registrado e enviado via returnChan.
-Após terminar, envia uma mensagem final de conclusão.
+Worker responsável por fazer o upload dos arquivos indicados pelo canal `in`,
+enviando o resultado de cada arquivo pelo canal `results`.
+Encerra quando o canal `in` é fechado.
 */
-func (vu *VideoUpload) uploadWorker(in chan int, returnChan chan string, uploadClient *storage.Client, ctx context.Context) {
-	for x := range in {
-		err := vu.UploadObject(vu.Paths[x], uploadClient, ctx)
-
-		if err != nil {
-			vu.Errors = append(vu.Errors, vu.Paths[x])
-			log.Printf("error during the upload: %v. Error: %v", vu.Paths[x], err)
-			returnChan <- err.Error()
-		}
-
-		returnChan <- ""
+func (vu *VideoUpload) uploadWorker(ctx context.Context, in chan string, results chan uploadAttempt) {
+	for path := range in {
+		results <- vu.uploadWithRetry(ctx, path)
 	}
-
-	returnChan <- "upload completed"
 }
 
 /*
-Cria e retorna um cliente autenticado para fazer upload de arquivos
-utilizando a biblioteca da Google Cloud Storage.
+uploadWithRetry envia um arquivo repetindo as falhas com backoff exponencial,
+até MaxAttempts tentativas ou até o contexto ser cancelado.
 */
-func getClientUpload() (*storage.Client, context.Context, error) {
-	ctx := context.Background()
+func (vu *VideoUpload) uploadWithRetry(ctx context.Context, path string) uploadAttempt {
+	maxAttempts := vu.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
 
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		return nil, nil, err
+	backoff := vu.Backoff
+	attempt := uploadAttempt{path: path}
+
+	for attempt.attempts < maxAttempts {
+		attempt.attempts++
+		attempt.bytes, attempt.err = vu.UploadObject(ctx, path)
+
+		if attempt.err == nil {
+			return attempt
+		}
+
+		log.Printf("error during the upload: %v (attempt %v/%v). Error: %v", path, attempt.attempts, maxAttempts, attempt.err)
+
+		if attempt.attempts == maxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			attempt.err = ctx.Err()
+			return attempt
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
-	return client, ctx, nil
+
+	return attempt
 }
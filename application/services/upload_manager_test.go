@@ -1,10 +1,18 @@
 package services_test
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
 	"log"
 	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/storage"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/require"
@@ -27,7 +35,7 @@ Testa todo o fluxo de upload de um vídeo:
 - Realiza download do vídeo.
 - Fragmenta e codifica.
 - Inicializa o upload com concorrência.
-- Espera a confirmação de finalização.
+- Verifica que todos os arquivos foram enviados.
 - Finaliza o processo do serviço.
 Verifica se não há erros em nenhuma das etapas.
 */
@@ -52,12 +60,112 @@ func TestVideoServiceUpload(t *testing.T) {
 	videoUpload.OutputBucket = "codeeducationtest"
 	videoUpload.VideoPath = os.Getenv("localStoragePath") + "/" + video.ID
 
-	doneUpload := make(chan string)
-	go videoUpload.ProcessUpload(50, doneUpload)
-
-	result := <-doneUpload
-	require.Equal(t, result, "upload completed")
+	result, err := videoUpload.ProcessUpload(context.Background(), 50)
+	require.Nil(t, err)
+	require.Nil(t, result.Err())
+	require.NotEmpty(t, result.Uploaded)
 
 	err = videoService.Finish()
 	require.Nil(t, err)
 }
+
+/*
+flakyStorage é um storage.Client que falha nas primeiras tentativas de upload
+de cada objeto listado em failures, delegando o restante para um MemoryClient.
+*/
+type flakyStorage struct {
+	*storage.MemoryClient
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+func (f *flakyStorage) Upload(ctx context.Context, bucket string, object string, r io.Reader, acl storage.ACLPolicy) (int64, error) {
+	f.mu.Lock()
+	remaining := f.failures[object]
+	if remaining > 0 {
+		f.failures[object] = remaining - 1
+	}
+	f.mu.Unlock()
+
+	if remaining > 0 {
+		return 0, errors.New("temporary failure")
+	}
+
+	return f.MemoryClient.Upload(ctx, bucket, object, r, acl)
+}
+
+// prepareUploadDir cria uma pasta de vídeo com os arquivos informados e retorna seu caminho.
+func prepareUploadDir(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "upload")
+	require.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	videoPath := filepath.Join(root, "video-id")
+	for name, content := range files {
+		path := filepath.Join(videoPath, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+		require.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	return videoPath
+}
+
+/*
+TestVideoUploadRetriesAndReportsFailures verifica que os objetos são repetidos com backoff,
+que falhas definitivas não interrompem os demais uploads e que o resultado
+reúne os objetos enviados, as falhas e o total de bytes.
+*/
+func TestVideoUploadRetriesAndReportsFailures(t *testing.T) {
+	videoPath := prepareUploadDir(t, map[string]string{
+		"stream.mpd":           "manifest",
+		"video/avc1/seg-1.m4s": "segment-1",
+		"video/avc1/seg-2.m4s": "segment-2",
+		"audio/mp4a/seg-1.m4s": "audio-1",
+	})
+
+	client := &flakyStorage{
+		MemoryClient: storage.NewMemoryClient(),
+		failures: map[string]int{
+			"video-id/stream.mpd":           1,
+			"video-id/video/avc1/seg-2.m4s": 10,
+		},
+	}
+
+	videoUpload := services.NewVideoUpload()
+	videoUpload.VideoPath = videoPath
+	videoUpload.OutputBucket = "bucket"
+	videoUpload.ACL = storage.ACLPrivate
+	videoUpload.Client = client
+	videoUpload.MaxAttempts = 3
+	videoUpload.Backoff = time.Millisecond
+
+	result, err := videoUpload.ProcessUpload(context.Background(), 2)
+	require.Nil(t, err)
+
+	require.Len(t, result.Uploaded, 3)
+	require.Len(t, result.Failed, 1)
+	require.Equal(t, filepath.Join(videoPath, "video/avc1/seg-2.m4s"), result.Failed[0].Path)
+	require.Equal(t, 3, result.Failed[0].Attempts)
+	require.Equal(t, int64(len("manifest")+len("segment-1")+len("audio-1")), result.Bytes)
+	require.Error(t, result.Err())
+
+	content, ok := client.Get("bucket", "video-id/stream.mpd")
+	require.True(t, ok)
+	require.Equal(t, "manifest", string(content))
+	require.Equal(t, storage.ACLPrivate, client.ACL("bucket", "video-id/stream.mpd"))
+}
+
+// TestVideoUploadWithMoreWorkersThanFiles garante que o upload termina sem bloquear os workers.
+func TestVideoUploadWithMoreWorkersThanFiles(t *testing.T) {
+	videoPath := prepareUploadDir(t, map[string]string{"stream.mpd": "manifest"})
+
+	videoUpload := services.NewVideoUpload()
+	videoUpload.VideoPath = videoPath
+	videoUpload.OutputBucket = "bucket"
+	videoUpload.Client = storage.NewMemoryClient()
+
+	result, err := videoUpload.ProcessUpload(context.Background(), 50)
+	require.Nil(t, err)
+	require.Nil(t, result.Err())
+	require.Len(t, result.Uploaded, 1)
+}
@@ -1,25 +1,60 @@
 package services
 
 import (
+	"bytes"
 	"context"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"log"
 	"microsservico-encoder/application/repositories"
 	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/drm"
+	"microsservico-encoder/framework/storage"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
-
-	"cloud.google.com/go/storage"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 /*
 VideoService é uma estrutura que encapsula a lógica de serviço
 relacionada a vídeos, incluindo operações como download, fragmentação,
-codificação e limpeza. Ela depende de um repositório de vídeos para persistência.
+codificação e limpeza. Ela depende de um repositório de vídeos para persistência,
+de um cliente de armazenamento para download e upload (se nil, o Google Cloud Storage
+é utilizado) e de um CommandRunner para as ferramentas externas (se nil, ExecRunner).
+Cada job deve utilizar a sua própria instância de VideoService.
 */
 type VideoService struct {
+	Context         context.Context // Contexto do job: cancelado, interrompe os downloads (se nil, context.Background())
 	Video           *domain.Video
 	VideoRepository repositories.VideoRepository
+	Storage         storage.Client
+	Fetchers        storage.Fetchers // Origens dos arquivos de entrada (se nil, storage.NewFetchers(Storage))
+	Runner          CommandRunner
+	Inputs          []string        // Entradas concatenadas após o arquivo principal, em ordem (opcional)
+	Clip            *Clip           // Trecho mantido na conversão (opcional)
+	Subtitles       []SubtitleTrack // Legendas empacotadas junto com o vídeo
+	AudioTracks     []AudioTrack    // Faixas de áudio alternativas empacotadas junto com o vídeo
+	Watermark       *Watermark      // Marca d'água aplicada na conversão (opcional)
+	Loudnorm        *Loudnorm       // Normalização de loudness aplicada na conversão (opcional)
+	Encryption      *Encryption     // Criptografia CENC do conteúdo (opcional)
+	KeyProvider     drm.KeyProvider // Fornece as chaves de conteúdo quando Encryption é informado
+	SegmentList     bool            // Lista cada segmento no manifesto DASH, para que as URLs possam ser assinadas
+	Progress        func()          // Sinaliza atividade do processamento (bytes baixados, saída das ferramentas), usado pelo heartbeat (opcional)
+
+	SourcePath      string   // Arquivo baixado, com a extensão original
+	SourceContainer string   // Contêiner detectado no arquivo baixado (mp4, mov, mkv...)
+	InputPaths      []string // Arquivos baixados das entradas adicionais (Inputs)
+	MediaPath       string   // Arquivo MP4 fragmentado pelo mp4fragment (o original ou o convertido)
+	Conversion      string   // Conversão realizada em Convert: none, remux ou transcode
+	Duration        float64  // Duração da saída, em segundos, calculada em Convert (0 se desconhecida)
+	KeyID           string   // KID da chave utilizada em Encode, quando o conteúdo é criptografado
+
+	temporaryFiles []string // Arquivos locais removidos em Finish
 }
 
 /*
@@ -30,93 +65,190 @@ func NewVideoService() VideoService {
 }
 
 /*
-Download baixa o arquivo de vídeo do Google Cloud Storage,
-com base no nome do bucket e no caminho do arquivo presente em Video.FilePath,
-e o armazena localmente como um arquivo .mp4.
+Download baixa o arquivo de vídeo indicado em Video.FilePath e o armazena localmente
+como <id>.<extensão original>. O esquema da URI seleciona a origem (gs://, s3://,
+file://, http(s)://); caminhos sem esquema são lidos do bucket bucketName.
+Em todas as origens, o conteúdo é verificado pelos primeiros bytes (deve ser um
+contêiner de vídeo conhecido) e limitado ao tamanho máximo de MAX_INPUT_SIZE bytes.
 */
 func (v *VideoService) Download(bucketName string) error {
 
-	ctx := context.Background()
+	path, err := v.fetch(v.Video.FilePath, bucketName, func(header []byte) (string, error) {
+		v.SourceContainer = utils.DetectContainer(header)
+		if v.SourceContainer == "" {
+			return "", fmt.Errorf("unsupported input content type: %v", http.DetectContentType(header))
+		}
 
-	client, err := storage.NewClient(ctx)
+		return v.localPath(sourceExtension(v.Video.FilePath, v.SourceContainer)), nil
+	})
 	if err != nil {
 		return err
 	}
 
-	bkt := client.Bucket(bucketName)
-	obj := bkt.Object(v.Video.FilePath)
+	v.SourcePath = path
+	v.MediaPath = path
+
+	log.Printf("video %v has been stored", v.Video.ID)
+
+	return nil
+}
 
-	r, err := obj.NewReader(ctx)
+/*
+fetch baixa o arquivo da URI para o disco local. A função target recebe os primeiros
+bytes do arquivo, para validar o conteúdo, e retorna o caminho local de destino.
+O arquivo baixado é registrado para remoção em Finish.
+*/
+func (v *VideoService) fetch(uri string, bucketName string, target func(header []byte) (string, error)) (string, error) {
+	ctx := v.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	fetchers := v.Fetchers
+	if fetchers == nil {
+		fetchers = storage.NewFetchers(v.Storage)
+	}
+
+	r, err := fetchers.Open(ctx, uri, bucketName)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer r.Close()
 
-	body, err := ioutil.ReadAll(r)
+	header := make([]byte, utils.SniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	path, err := target(header)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	f, err := os.Create(os.Getenv("localStoragePath") + "/" + v.Video.ID + ".mp4")
+	f, err := os.Create(path)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer f.Close()
+	v.addTemporaryFile(path)
+
+	var body io.Reader = &progressReader{Reader: io.MultiReader(bytes.NewReader(header), r), Progress: v.Progress}
+	maxSize := maxInputSize()
+	if maxSize > 0 {
+		// Lê um byte além do limite para detectar arquivos maiores que o permitido.
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	written, err := io.Copy(f, body)
+	if err == nil && maxSize > 0 && written > maxSize {
+		err = fmt.Errorf("input exceeds the maximum size of %d bytes", maxSize)
 	}
 
-	_, err = f.Write(body)
 	if err != nil {
-		return err
+		f.Close()
+		os.Remove(path)
+		return "", err
 	}
 
-	defer f.Close()
+	return path, nil
+}
 
-	log.Printf("video %v has been stored", v.Video.ID)
+// maxInputSize retorna o tamanho máximo dos arquivos de entrada (MAX_INPUT_SIZE), ou 0 se não houver limite.
+func maxInputSize() int64 {
+	size, err := strconv.ParseInt(os.Getenv("MAX_INPUT_SIZE"), 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
 
-	return nil
+/*
+sourceExtension retorna a extensão original do arquivo (ex.: .mov) ou, se ela não
+existir ou não for válida, a extensão do contêiner detectado.
+*/
+func sourceExtension(filePath string, container string) string {
+	if parsed, err := url.Parse(filePath); err == nil && parsed.Path != "" {
+		filePath = parsed.Path
+	}
+
+	extension := strings.ToLower(filepath.Ext(filePath))
+	if validExtension.MatchString(extension) {
+		return extension
+	}
+
+	return "." + container
 }
 
+var validExtension = regexp.MustCompile(`^\.[a-z0-9]{1,5}$`)
+
 /*
 Fragment cria uma pasta para armazenar os fragmentos do vídeo e,
-em seguida, usa o comando `mp4fragment` para fragmentar o vídeo .mp4
-em um arquivo .frag, necessário para a próxima etapa de codificação.
+em seguida, usa o comando `mp4fragment` para fragmentar o vídeo MP4
+(o arquivo original ou o convertido em Convert) e as faixas de áudio
+alternativas em arquivos .frag, necessários para a próxima etapa de codificação.
 */
 func (v *VideoService) Fragment() error {
 
-	err := os.Mkdir(os.Getenv("localStoragePath")+"/"+v.Video.ID, os.ModePerm)
+	err := os.Mkdir(v.localPath(""), os.ModePerm)
 	if err != nil {
 		return err
 	}
+	v.addTemporaryFile(v.localPath(""))
 
-	source := os.Getenv("localStoragePath") + "/" + v.Video.ID + ".mp4"
-	target := os.Getenv("localStoragePath") + "/" + v.Video.ID + ".frag"
+	source := v.MediaPath
+	if source == "" {
+		source = v.localPath(".mp4")
+	}
+	target := v.localPath(".frag")
+	v.addTemporaryFile(target)
 
-	cmd := exec.Command("mp4fragment", source, target)
-	output, err := cmd.CombinedOutput()
+	output, err := v.run("mp4fragment", source, target)
 	if err != nil {
 		return err
 	}
 
 	printOutput(output)
 
-	return nil
+	return v.fragmentAudioTracks()
 }
 
 /*
 Encode utiliza o comando `mp4dash` para codificar o vídeo fragmentado
-(.frag) em múltiplos segmentos e manifestos, preparando-o para
-streaming adaptativo (DASH).
+(.frag), as faixas de áudio alternativas e as legendas em múltiplos segmentos e manifestos, preparando-o para
+streaming adaptativo (DASH e HLS).
+Com SegmentList, o manifesto DASH usa SegmentList em vez de SegmentTemplate, o que
+permite assinar a URL de cada segmento de uma saída privada.
+Quando Encryption é informado, o conteúdo é criptografado (CENC/cbcs) com a chave
+do KeyProvider e os sistemas de DRM são sinalizados no manifesto. A chave não é
+registrada em log.
 */
 func (v *VideoService) Encode() error {
+	encryptionArgs, key, err := v.encryptionArgs()
+	if err != nil {
+		return err
+	}
+
 	cmdArgs := []string{}
-	cmdArgs = append(cmdArgs, os.Getenv("localStoragePath")+"/"+v.Video.ID+".frag")
-	cmdArgs = append(cmdArgs, "--use-segment-timeline")
+	cmdArgs = append(cmdArgs, v.localPath(".frag"))
+	cmdArgs = append(cmdArgs, v.audioTrackInputs()...)
+	cmdArgs = append(cmdArgs, v.subtitleInputs()...)
+	if v.SegmentList {
+		cmdArgs = append(cmdArgs, "--use-segment-list")
+	} else {
+		cmdArgs = append(cmdArgs, "--use-segment-timeline")
+	}
+	cmdArgs = append(cmdArgs, "--hls")
 	cmdArgs = append(cmdArgs, "-o")
-	cmdArgs = append(cmdArgs, os.Getenv("localStoragePath")+"/"+v.Video.ID)
+	cmdArgs = append(cmdArgs, v.localPath(""))
 	cmdArgs = append(cmdArgs, "-f")
 	cmdArgs = append(cmdArgs, "--exec-dir")
 	cmdArgs = append(cmdArgs, "/opt/bento4/bin/")
-	cmd := exec.Command("mp4dash", cmdArgs...)
+	cmdArgs = append(cmdArgs, encryptionArgs...)
 
-	output, err := cmd.CombinedOutput()
+	output, err := v.run("mp4dash", cmdArgs...)
+	output = redactKey(output, key)
 
 	if err != nil {
 		return err
@@ -129,27 +261,19 @@ func (v *VideoService) Encode() error {
 
 /*
 Finish remove todos os arquivos temporários gerados durante o processo
-(mp4 original, arquivo .frag e pasta de saída), liberando espaço em disco.
+(arquivo original, arquivo convertido, arquivo .frag e pasta de saída),
+liberando espaço em disco.
 */
 func (v *VideoService) Finish() error {
 
-	err := os.Remove(os.Getenv("localStoragePath") + "/" + v.Video.ID + ".mp4")
-	if err != nil {
-		log.Println("error removing mp4 ", v.Video.ID, ".mp4")
-		return err
-	}
-
-	err = os.Remove(os.Getenv("localStoragePath") + "/" + v.Video.ID + ".frag")
-	if err != nil {
-		log.Println("error removing frag ", v.Video.ID, ".frag")
-		return err
-	}
-
-	err = os.RemoveAll(os.Getenv("localStoragePath") + "/" + v.Video.ID)
-	if err != nil {
-		log.Println("error removing mp4 ", v.Video.ID, ".mp4")
-		return err
+	for _, path := range v.temporaryFiles {
+		err := os.RemoveAll(path)
+		if err != nil {
+			log.Println("error removing ", path)
+			return err
+		}
 	}
+	v.temporaryFiles = nil
 
 	log.Println("files have been removed: ", v.Video.ID)
 
@@ -157,6 +281,21 @@ func (v *VideoService) Finish() error {
 
 }
 
+// localPath retorna o caminho local de um arquivo do vídeo: <localStoragePath>/<id><suffix>.
+func (v *VideoService) localPath(suffix string) string {
+	return os.Getenv("localStoragePath") + "/" + v.Video.ID + suffix
+}
+
+// addTemporaryFile registra um arquivo local para remoção em Finish.
+func (v *VideoService) addTemporaryFile(path string) {
+	for _, existing := range v.temporaryFiles {
+		if existing == path {
+			return
+		}
+	}
+	v.temporaryFiles = append(v.temporaryFiles, path)
+}
+
 /*
 InsertVideo insere as informações do vídeo no repositório,
 armazenando seus metadados em uma base de dados, por exemplo.
@@ -171,6 +310,58 @@ func (v *VideoService) InsertVideo() error {
 	return nil
 }
 
+/*
+UpdateVideo salva no repositório as informações do vídeo obtidas durante o
+processamento, como o loudness medido na conversão.
+*/
+func (v *VideoService) UpdateVideo() error {
+	_, err := v.VideoRepository.Update(v.Video)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+run executa uma ferramenta externa com o CommandRunner configurado.
+Com o ExecRunner, cada saída do comando sinaliza Progress; com outros runners,
+apenas o fim do comando.
+*/
+func (v *VideoService) run(name string, args ...string) ([]byte, error) {
+	if v.Runner == nil {
+		return ExecRunner{Progress: v.Progress}.Run(name, args...)
+	}
+
+	output, err := v.Runner.Run(name, args...)
+	v.progress()
+
+	return output, err
+}
+
+// progress sinaliza atividade do processamento em Progress, se configurado.
+func (v *VideoService) progress() {
+	if v.Progress != nil {
+		v.Progress()
+	}
+}
+
+// progressReader sinaliza em Progress cada leitura que retorna dados.
+type progressReader struct {
+	io.Reader
+	Progress func()
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && r.Progress != nil {
+		r.Progress()
+	}
+
+	return n, err
+}
+
 /*
 printOutput imprime a saída dos comandos executados no terminal,
 se houver alguma mensagem ou erro retornado.
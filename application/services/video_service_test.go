@@ -1,11 +1,15 @@
 package services_test
 
 import (
+	"io/ioutil"
 	"log"
 	"microsservico-encoder/application/repositories"
 	"microsservico-encoder/application/services"
 	"microsservico-encoder/domain"
 	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,7 +49,7 @@ func prepare() (*domain.Video, repositories.VideoRepositoryDb) {
 
 /*
 Função de teste principal que valida o fluxo completo do serviço de vídeo:
-download, fragmentação, codificação e finalização.
+download, conversão, fragmentação, codificação e finalização.
 Usa require.Nil para garantir que nenhum erro ocorra em cada etapa.
 */
 func TestVideoServiceDownload(t *testing.T) {
@@ -58,6 +62,9 @@ func TestVideoServiceDownload(t *testing.T) {
 	err := videoService.Download("codeeducationtest")
 	require.Nil(t, err)
 
+	err = videoService.Convert()
+	require.Nil(t, err)
+
 	err = videoService.Fragment()
 	require.Nil(t, err)
 
@@ -67,3 +74,85 @@ func TestVideoServiceDownload(t *testing.T) {
 	err = videoService.Finish()
 	require.Nil(t, err)
 }
+
+/*
+TestVideoServiceDownloadValidatesInput verifica que o download recusa arquivos que
+não são vídeos e arquivos maiores que MAX_INPUT_SIZE, independente da origem.
+*/
+func TestVideoServiceDownloadValidatesInput(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+	setEnv(t, "MAX_INPUT_SIZE", "64")
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("small"))
+	client.Put("bucket", "page.html", []byte("<html><body>not a video</body></html>"))
+	client.Put("bucket", "big.mp4", fakeMp4(strings.Repeat("x", 64)))
+
+	download := func(filePath string) error {
+		video, repo := prepare()
+		video.FilePath = filePath
+
+		videoService := services.NewVideoService()
+		videoService.Video = video
+		videoService.VideoRepository = repo
+		videoService.Storage = client
+
+		return videoService.Download("bucket")
+	}
+
+	require.Nil(t, download("video.mp4"))
+	require.Nil(t, download("gs://bucket/video.mp4"))
+
+	err = download("page.html")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported input content type")
+
+	err = download("big.mp4")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "maximum size")
+}
+
+/*
+TestVideoServiceEncodeSegmentList verifica que, com SegmentList, o manifesto DASH
+lista cada segmento, para que as URLs de uma saída privada possam ser assinadas.
+*/
+func TestVideoServiceEncodeSegmentList(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+
+	encode := func(segmentList bool) string {
+		video, repo := prepare()
+		video.FilePath = "video.mp4"
+
+		runner := &recordingRunner{}
+		videoService := services.NewVideoService()
+		videoService.Video = video
+		videoService.VideoRepository = repo
+		videoService.Storage = client
+		videoService.Runner = runner
+		videoService.SegmentList = segmentList
+
+		require.Nil(t, videoService.Download("bucket"))
+		require.Nil(t, videoService.Fragment())
+		require.Nil(t, videoService.Encode())
+		require.Nil(t, videoService.Finish())
+
+		return runner.commands[len(runner.commands)-1]
+	}
+
+	mp4dash := encode(false)
+	require.Contains(t, mp4dash, "--use-segment-timeline")
+	require.NotContains(t, mp4dash, "--use-segment-list")
+
+	mp4dash = encode(true)
+	require.Contains(t, mp4dash, "--use-segment-list")
+	require.NotContains(t, mp4dash, "--use-segment-timeline")
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Posições aceitas para a marca d'água.
+const (
+	WatermarkTopLeft     = "top-left"
+	WatermarkTopRight    = "top-right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottomRight = "bottom-right"
+	WatermarkCenter      = "center"
+)
+
+// watermarkMargin é a distância, em pixels, entre a marca d'água e a borda do vídeo.
+const watermarkMargin = 16
+
+// watermarkPositions define as coordenadas do filtro overlay do ffmpeg para cada posição.
+var watermarkPositions = map[string]string{
+	WatermarkTopLeft:     fmt.Sprintf("x=%d:y=%d", watermarkMargin, watermarkMargin),
+	WatermarkTopRight:    fmt.Sprintf("x=main_w-overlay_w-%d:y=%d", watermarkMargin, watermarkMargin),
+	WatermarkBottomLeft:  fmt.Sprintf("x=%d:y=main_h-overlay_h-%d", watermarkMargin, watermarkMargin),
+	WatermarkBottomRight: fmt.Sprintf("x=main_w-overlay_w-%d:y=main_h-overlay_h-%d", watermarkMargin, watermarkMargin),
+	WatermarkCenter:      "x=(main_w-overlay_w)/2:y=(main_h-overlay_h)/2",
+}
+
+/*
+Watermark representa a marca d'água (logo) aplicada ao vídeo na etapa de conversão.
+- FilePath: imagem PNG ou JPEG, com as mesmas regras de origem do arquivo de vídeo.
+- Position: top-left, top-right, bottom-left, bottom-right ou center (padrão: bottom-right).
+- Opacity: opacidade entre 0 e 1 (padrão: 1).
+- Scale: largura da imagem em relação à largura do vídeo, entre 0 e 1 (padrão: 0.15).
+- Start e End: intervalo, em segundos, em que a marca d'água é exibida (opcionais).
+A configuração é registrada no job para que a saída possa ser reproduzida.
+*/
+type Watermark struct {
+	FilePath  string   `json:"file_path"`
+	Position  string   `json:"position,omitempty"`
+	Opacity   *float64 `json:"opacity,omitempty"`
+	Scale     float64  `json:"scale,omitempty"`
+	Start     *float64 `json:"start,omitempty"`
+	End       *float64 `json:"end,omitempty"`
+	LocalPath string   `json:"-"`
+}
+
+/*
+Validate verifica a configuração da marca d'água e define os valores padrão.
+*/
+func (w *Watermark) Validate() error {
+	if w.FilePath == "" {
+		return fmt.Errorf("watermark file_path is required")
+	}
+
+	if w.Position == "" {
+		w.Position = WatermarkBottomRight
+	}
+
+	if _, ok := watermarkPositions[w.Position]; !ok {
+		return fmt.Errorf("invalid watermark position: %q", w.Position)
+	}
+
+	if w.Opacity == nil {
+		opacity := 1.0
+		w.Opacity = &opacity
+	}
+
+	if *w.Opacity <= 0 || *w.Opacity > 1 {
+		return fmt.Errorf("invalid watermark opacity: %v", *w.Opacity)
+	}
+
+	if w.Scale == 0 {
+		w.Scale = 0.15
+	}
+
+	if w.Scale < 0 || w.Scale > 1 {
+		return fmt.Errorf("invalid watermark scale: %v", w.Scale)
+	}
+
+	if (w.Start != nil && *w.Start < 0) || (w.End != nil && *w.End <= 0) {
+		return fmt.Errorf("invalid watermark interval")
+	}
+
+	if w.Start != nil && w.End != nil && *w.End <= *w.Start {
+		return fmt.Errorf("watermark end must be after start")
+	}
+
+	return nil
+}
+
+/*
+DownloadWatermark baixa a imagem da marca d'água, quando configurada.
+Apenas imagens PNG e JPEG são aceitas.
+*/
+func (v *VideoService) DownloadWatermark(bucketName string) error {
+	if v.Watermark == nil {
+		return nil
+	}
+
+	path, err := v.fetch(v.Watermark.FilePath, bucketName, func(header []byte) (string, error) {
+		switch contentType := http.DetectContentType(header); contentType {
+		case "image/png":
+			return v.localPath(".watermark.png"), nil
+		case "image/jpeg":
+			return v.localPath(".watermark.jpg"), nil
+		default:
+			return "", fmt.Errorf("unsupported watermark content type: %v", contentType)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error downloading watermark %v: %v", v.Watermark.FilePath, err)
+	}
+
+	v.Watermark.LocalPath = path
+
+	log.Printf("watermark %v of video %v has been stored", v.Watermark.FilePath, v.Video.ID)
+
+	return nil
+}
+
+/*
+filter monta o filtro do ffmpeg que sobrepõe a imagem (entrada de índice
+input) ao vídeo base (ex.: [0:v:0]). A imagem é redimensionada em relação à
+largura do vídeo, recebe a opacidade e é exibida apenas no intervalo configurado.
+O vídeo resultante fica no rótulo [v].
+*/
+func (w *Watermark) filter(input int, base string) string {
+	scale := strconv.FormatFloat(w.Scale, 'f', -1, 64)
+	opacity := strconv.FormatFloat(*w.Opacity, 'f', -1, 64)
+
+	overlay := watermarkPositions[w.Position]
+	if w.Start != nil || w.End != nil {
+		start, end := "0", "1e9"
+		if w.Start != nil {
+			start = strconv.FormatFloat(*w.Start, 'f', -1, 64)
+		}
+		if w.End != nil {
+			end = strconv.FormatFloat(*w.End, 'f', -1, 64)
+		}
+		overlay += fmt.Sprintf(":enable='between(t,%v,%v)'", start, end)
+	}
+
+	return fmt.Sprintf("[%d:v]%vscale2ref=w=main_w*%v:h=ow/dar[logo][base];"+
+		"[logo]format=rgba,colorchannelmixer=aa=%v[watermark];"+
+		"[base][watermark]overlay=%v[v]", input, base, scale, opacity, overlay)
+}
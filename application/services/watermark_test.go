@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestVideoServiceWatermark verifica que a marca d'água força a recodificação do vídeo
+e que o ffmpeg recebe a imagem com a posição, a opacidade, a escala e o intervalo.
+*/
+func TestVideoServiceWatermark(t *testing.T) {
+	localStoragePath, err := ioutil.TempDir("", "encoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(localStoragePath)
+	setEnv(t, "localStoragePath", localStoragePath)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "video.mp4", fakeMp4("video"))
+	client.Put("bucket", "logo.png", []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR"))
+	client.Put("bucket", "logo.svg", []byte("<svg></svg>"))
+
+	video, repo := prepare()
+	video.FilePath = "video.mp4"
+
+	opacity, start, end := 0.5, 2.0, 8.0
+	watermark := &services.Watermark{FilePath: "logo.png", Position: services.WatermarkTopLeft, Opacity: &opacity, Start: &start, End: &end}
+	require.Nil(t, watermark.Validate())
+
+	runner := &recordingRunner{}
+
+	videoService := services.NewVideoService()
+	videoService.Video = video
+	videoService.VideoRepository = repo
+	videoService.Storage = client
+	videoService.Runner = runner
+	videoService.Watermark = watermark
+
+	require.Nil(t, videoService.Download("bucket"))
+	require.Nil(t, videoService.DownloadWatermark("bucket"))
+	require.Nil(t, videoService.Convert())
+	require.Equal(t, services.ConversionTranscode, videoService.Conversion)
+
+	ffmpeg := runner.commands[len(runner.commands)-1]
+	require.True(t, strings.HasPrefix(ffmpeg, "ffmpeg "))
+	require.Contains(t, ffmpeg, "-i "+watermark.LocalPath)
+	require.Contains(t, ffmpeg, "scale2ref=w=main_w*0.15")
+	require.Contains(t, ffmpeg, "colorchannelmixer=aa=0.5")
+	require.Contains(t, ffmpeg, "overlay=x=16:y=16:enable='between(t,2,8)'")
+	require.Contains(t, ffmpeg, "-map [v]")
+	require.Contains(t, ffmpeg, "-c:v libx264")
+	require.Contains(t, ffmpeg, "-c:a copy")
+
+	require.Nil(t, videoService.Finish())
+	files, err := ioutil.ReadDir(localStoragePath)
+	require.Nil(t, err)
+	require.Empty(t, files)
+
+	videoService.Watermark = &services.Watermark{FilePath: "logo.svg"}
+	err = videoService.DownloadWatermark("bucket")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unsupported watermark content type")
+}
+
+func TestWatermarkValidate(t *testing.T) {
+	watermark := &services.Watermark{FilePath: "logo.png"}
+	require.Nil(t, watermark.Validate())
+	require.Equal(t, services.WatermarkBottomRight, watermark.Position)
+	require.Equal(t, 1.0, *watermark.Opacity)
+	require.Equal(t, 0.15, watermark.Scale)
+
+	zero, start, end := 0.0, 5.0, 3.0
+	require.Error(t, (&services.Watermark{}).Validate())
+	require.Error(t, (&services.Watermark{FilePath: "logo.png", Position: "middle"}).Validate())
+	require.Error(t, (&services.Watermark{FilePath: "logo.png", Opacity: &zero}).Validate())
+	require.Error(t, (&services.Watermark{FilePath: "logo.png", Scale: 2}).Validate())
+	require.Error(t, (&services.Watermark{FilePath: "logo.png", Start: &start, End: &end}).Validate())
+}
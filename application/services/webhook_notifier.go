@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Cabeçalhos enviados em cada entrega de webhook.
+const (
+	WebhookSignatureHeader = "X-Encoder-Signature" // Assinatura HMAC-SHA256 do corpo: "sha256=<hex>"
+	WebhookEventHeader     = "X-Encoder-Event"     // Tipo do evento entregue
+	WebhookDeliveryHeader  = "X-Encoder-Delivery"  // Identificador do evento, igual em todas as tentativas
+)
+
+/*
+WebhookNotifier entrega os eventos finais dos jobs (job.completed e job.failed)
+na callback_url informada na mensagem, para clientes que não consomem o RabbitMQ.
+O corpo é assinado com HMAC-SHA256 usando Secret, e cada tentativa é registrada
+no repositório. Falhas são repetidas até MaxAttempts vezes, com backoff exponencial.
+*/
+type WebhookNotifier struct {
+	Client      *http.Client
+	Secret      string
+	MaxAttempts int
+	Backoff     time.Duration // Espera antes da segunda tentativa; dobra a cada nova tentativa
+	Repository  repositories.WebhookDeliveryRepository
+}
+
+// placeholderWebhookSecret é o valor de exemplo de WEBHOOK_SECRET, recusado na configuração.
+const placeholderWebhookSecret = "change-me"
+
+/*
+NewWebhookNotifier cria um WebhookNotifier configurado pelas variáveis de ambiente
+WEBHOOK_SECRET, WEBHOOK_MAX_ATTEMPTS, WEBHOOK_BACKOFF e WEBHOOK_TIMEOUT.
+As callback_url só podem apontar para endereços públicos, a menos que ALLOW_PRIVATE_TARGETS=true.
+Sem WEBHOOK_SECRET, os webhooks ficam desativados e o retorno é nil: as assinaturas não
+poderiam ser verificadas pelos clientes. Retorna erro se o segredo for o valor de exemplo.
+*/
+func NewWebhookNotifier(repository repositories.WebhookDeliveryRepository) (*WebhookNotifier, error) {
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
+
+	if secret == placeholderWebhookSecret {
+		return nil, fmt.Errorf("invalid WEBHOOK_SECRET: %q is a placeholder, set a random secret", secret)
+	}
+
+	maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts < 1 {
+		maxAttempts = 5
+	}
+
+	backoff, err := time.ParseDuration(os.Getenv("WEBHOOK_BACKOFF"))
+	if err != nil {
+		backoff = time.Second
+	}
+
+	timeout, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT"))
+	if err != nil {
+		timeout = 10 * time.Second
+	}
+
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("ALLOW_PRIVATE_TARGETS"))
+
+	return &WebhookNotifier{
+		Client:      &http.Client{Timeout: timeout, Transport: utils.NewHTTPTransport(timeout, allowPrivate)},
+		Secret:      secret,
+		MaxAttempts: maxAttempts,
+		Backoff:     backoff,
+		Repository:  repository,
+	}, nil
+}
+
+/*
+Deliver envia o evento para a callback_url do job via POST.
+Respostas 2xx encerram as tentativas; demais respostas e erros de rede são repetidos,
+exceto as URLs de endereços internos, recusadas sem novas tentativas.
+Retorna erro se todas as tentativas falharem.
+*/
+func (w *WebhookNotifier) Deliver(job *domain.Job, event *domain.JobEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	signature := SignWebhookPayload(w.Secret, body)
+	backoff := w.Backoff
+
+	for attempt := 1; attempt <= w.MaxAttempts; attempt++ {
+		delivery := domain.NewWebhookDelivery(job, event, attempt)
+
+		statusCode, err := w.post(job.CallbackURL, event, body, signature)
+		delivery.StatusCode = statusCode
+
+		if err == nil && (statusCode < 200 || statusCode > 299) {
+			err = fmt.Errorf("unexpected status code %d", statusCode)
+		}
+
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Success = true
+		}
+
+		w.record(delivery)
+
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("webhook delivery %v/%v for job %v failed: %v", attempt, w.MaxAttempts, job.ID, err)
+
+		if errors.Is(err, utils.ErrForbiddenAddress) {
+			return fmt.Errorf("webhook delivery for job %v refused: %w", job.ID, err)
+		}
+
+		if attempt < w.MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return fmt.Errorf("webhook delivery for job %v failed after %d attempts", job.ID, w.MaxAttempts)
+}
+
+// post realiza uma tentativa de entrega e retorna o status HTTP recebido.
+func (w *WebhookNotifier) post(url string, event *domain.JobEvent, body []byte, signature string) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookSignatureHeader, signature)
+	request.Header.Set(WebhookEventHeader, string(event.Type))
+	request.Header.Set(WebhookDeliveryHeader, event.ID)
+
+	response, err := w.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	return response.StatusCode, nil
+}
+
+// record salva a tentativa de entrega no repositório, se houver um configurado.
+func (w *WebhookNotifier) record(delivery *domain.WebhookDelivery) {
+	if w.Repository == nil {
+		return
+	}
+
+	_, err := w.Repository.Insert(delivery)
+	if err != nil {
+		log.Printf("error recording webhook delivery for job %v: %v", delivery.JobID, err)
+	}
+}
+
+/*
+SignWebhookPayload calcula a assinatura HMAC-SHA256 do corpo com o segredo informado,
+no formato enviado no cabeçalho X-Encoder-Signature ("sha256=<hex>").
+Os clientes devem recalcular a assinatura e compará-la com hmac.Equal.
+*/
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,148 @@
+package services_test
+
+import (
+	"io/ioutil"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestWebhookNotifierRetriesAndSigns verifica que o webhook é assinado com HMAC-SHA256,
+repetido após uma resposta de erro e que cada tentativa é registrada no banco.
+*/
+func TestWebhookNotifierRetriesAndSigns(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		require.Equal(t, services.SignWebhookPayload("secret", body), r.Header.Get(services.WebhookSignatureHeader))
+		require.Equal(t, string(domain.JobCompleted), r.Header.Get(services.WebhookEventHeader))
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	video := domain.NewVideo()
+	video.ID = uuid.NewV4().String()
+	video.ResourceID = "resource"
+	video.FilePath = "path"
+	video.CreatedAt = time.Now()
+
+	job, err := domain.NewJob("bucket", domain.JobStatusCompleted, video)
+	require.Nil(t, err)
+	job.CallbackURL = server.URL
+
+	_, err = repositories.JobRepositoryDb{Db: db}.Insert(job)
+	require.Nil(t, err)
+
+	setEnv(t, "ALLOW_PRIVATE_TARGETS", "true")
+	setEnv(t, "WEBHOOK_SECRET", "secret")
+	repo := repositories.WebhookDeliveryRepositoryDb{Db: db}
+	notifier, err := services.NewWebhookNotifier(repo)
+	require.Nil(t, err)
+	notifier.Backoff = time.Millisecond
+
+	err = notifier.Deliver(job, domain.NewJobEvent(domain.JobCompleted, job))
+	require.Nil(t, err)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	deliveries, err := repo.FindByJob(job.ID)
+	require.Nil(t, err)
+	require.Len(t, deliveries, 2)
+	require.False(t, deliveries[0].Success)
+	require.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	require.True(t, deliveries[1].Success)
+	require.Equal(t, 2, deliveries[1].Attempt)
+}
+
+// TestWebhookNotifierGivesUp verifica que a entrega falha após MaxAttempts tentativas.
+func TestWebhookNotifierGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	job := &domain.Job{ID: uuid.NewV4().String(), CallbackURL: server.URL}
+
+	setEnv(t, "ALLOW_PRIVATE_TARGETS", "true")
+	setEnv(t, "WEBHOOK_SECRET", "secret")
+	notifier, err := services.NewWebhookNotifier(nil)
+	require.Nil(t, err)
+	notifier.MaxAttempts = 3
+	notifier.Backoff = time.Millisecond
+
+	err = notifier.Deliver(job, domain.NewJobEvent(domain.JobFailed, job))
+	require.Error(t, err)
+}
+
+/*
+TestWebhookNotifierRejectsInternalAddresses verifica que callback_url de endereços internos
+são recusadas sem nenhuma requisição e sem novas tentativas.
+*/
+func TestWebhookNotifierRejectsInternalAddresses(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	db := database.NewDbTest()
+	defer db.Close()
+
+	setEnv(t, "ALLOW_PRIVATE_TARGETS", "false")
+	setEnv(t, "WEBHOOK_SECRET", "secret")
+	repo := repositories.WebhookDeliveryRepositoryDb{Db: db}
+	notifier, err := services.NewWebhookNotifier(repo)
+	require.Nil(t, err)
+	notifier.Backoff = time.Millisecond
+
+	for _, callbackURL := range []string{server.URL, "http://169.254.169.254/latest/meta-data/", "http://10.0.0.1:8080/hook"} {
+		job := &domain.Job{ID: uuid.NewV4().String(), CallbackURL: callbackURL}
+
+		err := notifier.Deliver(job, domain.NewJobEvent(domain.JobCompleted, job))
+		require.ErrorIs(t, err, utils.ErrForbiddenAddress, callbackURL)
+
+		deliveries, err := repo.FindByJob(job.ID)
+		require.Nil(t, err)
+		require.Len(t, deliveries, 1)
+	}
+
+	require.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+/*
+TestNewWebhookNotifierRequiresSecret verifica que os webhooks ficam desativados sem
+WEBHOOK_SECRET e que o valor de exemplo do segredo é recusado.
+*/
+func TestNewWebhookNotifierRequiresSecret(t *testing.T) {
+	setEnv(t, "WEBHOOK_SECRET", "")
+	notifier, err := services.NewWebhookNotifier(nil)
+	require.Nil(t, err)
+	require.Nil(t, notifier)
+
+	setEnv(t, "WEBHOOK_SECRET", "change-me")
+	_, err = services.NewWebhookNotifier(nil)
+	require.Error(t, err)
+
+	setEnv(t, "WEBHOOK_SECRET", "s3cr3t")
+	notifier, err = services.NewWebhookNotifier(nil)
+	require.Nil(t, err)
+	require.Equal(t, "s3cr3t", notifier.Secret)
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Escopos das chaves de acesso à API.
+const (
+	ScopeSubmit = "submit" // Envia novos jobs
+	ScopeRead   = "read"   // Consulta jobs, vídeos e relatórios de uso
+	ScopeAdmin  = "admin"  // Todas as operações, incluindo as administrativas
+)
+
+// apiKeyMarker inicia todas as chaves geradas pelo encoder, facilitando identificá-las em vazamentos.
+const apiKeyMarker = "enc_"
+
+// apiKeyPrefixLength é o tamanho do prefixo público da chave: o marcador e 12 caracteres hexadecimais.
+const apiKeyPrefixLength = len(apiKeyMarker) + 12
+
+/*
+APIKey é uma chave de acesso à API HTTP, no formato enc_<prefixo>_<segredo>.
+A chave completa só é conhecida na criação (ver NewAPIKey): o banco guarda apenas o
+hash SHA-256 e o prefixo público, que localiza a chave e a identifica nos logs.
+Uma chave com TenantID acessa apenas os jobs desse tenant; sem TenantID, acessa todos.
+*/
+type APIKey struct {
+	ID        string     `json:"key_id" gorm:"type:uuid;primary_key"`
+	Name      string     `json:"name" gorm:"type:varchar(255)"`
+	TenantID  string     `json:"tenant_id,omitempty" gorm:"type:varchar(255)"`
+	Prefix    string     `json:"prefix" gorm:"type:varchar(255)"`
+	KeyHash   string     `json:"-" gorm:"type:varchar(255)"`
+	Scopes    string     `json:"scopes" gorm:"type:varchar(255)"` // Escopos separados por vírgula
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+/*
+NewAPIKey gera uma chave com o nome, o tenant e os escopos informados.
+Retorna o registro a ser gravado e a chave completa, que deve ser entregue ao
+cliente e não pode ser recuperada depois.
+*/
+func NewAPIKey(name string, tenantID string, scopes []string) (*APIKey, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("api key without name")
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("api key without scopes")
+	}
+
+	for _, scope := range scopes {
+		if scope != ScopeSubmit && scope != ScopeRead && scope != ScopeAdmin {
+			return nil, "", fmt.Errorf("invalid api key scope: %q", scope)
+		}
+	}
+
+	random := make([]byte, 6+32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return nil, "", err
+	}
+
+	prefix := apiKeyMarker + hex.EncodeToString(random[:6])
+	key := prefix + "_" + base64.RawURLEncoding.EncodeToString(random[6:])
+
+	return &APIKey{
+		ID:        uuid.NewV4().String(),
+		Name:      name,
+		TenantID:  tenantID,
+		Prefix:    prefix,
+		KeyHash:   HashAPIKey(key),
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: time.Now(),
+	}, key, nil
+}
+
+// HashAPIKey retorna o hash SHA-256, em hexadecimal, de uma chave completa.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+APIKeyPrefix retorna o prefixo público de uma chave completa, ou false se o valor
+não tiver o formato das chaves geradas. Apenas o prefixo pode ser registrado em log.
+*/
+func APIKeyPrefix(key string) (string, bool) {
+	if len(key) <= apiKeyPrefixLength+1 || !strings.HasPrefix(key, apiKeyMarker) || key[apiKeyPrefixLength] != '_' {
+		return "", false
+	}
+
+	prefix := key[:apiKeyPrefixLength]
+	if _, err := hex.DecodeString(prefix[len(apiKeyMarker):]); err != nil {
+		return "", false
+	}
+
+	return prefix, true
+}
+
+// Matches verifica, em tempo constante, se a chave completa corresponde ao hash armazenado.
+func (k *APIKey) Matches(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(k.KeyHash)) == 1
+}
+
+// ScopeList retorna os escopos da chave.
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return nil
+	}
+
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope verifica se a chave possui o escopo informado; o escopo admin inclui todos os demais.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.ScopeList() {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Revoked indica se a chave foi revogada.
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
@@ -12,6 +12,19 @@ func init() {
 	govalidator.SetFieldsRequiredByDefault(true)
 }
 
+// Status possíveis de um job, na ordem em que ocorrem no processamento.
+const (
+	JobStatusStarting    = "STARTING"
+	JobStatusDownloading = "DOWNLOADING"
+	JobStatusConverting  = "CONVERTING"
+	JobStatusFragmenting = "FRAGMENTING"
+	JobStatusEncoding    = "ENCODING"
+	JobStatusUploading   = "UPLOADING"
+	JobStatusFinishing   = "FINISHING"
+	JobStatusCompleted   = "COMPLETED"
+	JobStatusFailed      = "FAILED"
+)
+
 /*
 Job representa um processo de encoding de vídeo
 Cada job está associado a um vídeo e contém informações sobre status, erro e datas de criação e atualização
@@ -21,14 +34,36 @@ Os campos são anotados com tags do GORM e validações do govalidator
 */
 
 type Job struct {
-	ID               string    `json:"job_id" valid:"uuid" gorm:"type:uuid;primary_key"`     // Identificador único do job
-	OutputBucketPath string    `json:"output_bucket_path" valid:"notnull"`                   // Caminho de saída do arquivo processado
-	Status           string    `json:"status" valid:"notnull"`                               // Status atual do job (ex: pending, completed)
-	Video            *Video    `json:"video" valid:"-"`                                      // Referência ao vídeo associado
-	VideoID          string    `json:"-" valid:"-" gorm:"column:video_id;type:uuid;notnull"` // Chave estrangeira para o vídeo
-	Error            string    `valid:"-"`                                                   // Mensagem de erro, se houver
-	CreatedAt        time.Time `json:"created_at" valid:"-"`                                 // Data de criação
-	UpdatedAt        time.Time `json:"updated_at" valid:"-"`                                 // Data da última atualização
+	ID               string     `json:"job_id" valid:"uuid" gorm:"type:uuid;primary_key"`     // Identificador único do job
+	OutputBucket     string     `json:"output_bucket,omitempty" valid:"-"`                    // Bucket de saída do arquivo processado
+	OutputBucketPath string     `json:"output_bucket_path" valid:"notnull"`                   // Caminho de saída do arquivo processado (prefixo dos objetos no bucket)
+	Profile          string     `json:"profile,omitempty" valid:"-"`                          // Perfil de encoding utilizado
+	SourceContainer  string     `json:"source_container,omitempty" valid:"-"`                 // Contêiner detectado no arquivo de entrada (mp4, mov, mkv...)
+	Conversion       string     `json:"conversion,omitempty" valid:"-"`                       // Conversão aplicada à entrada antes da fragmentação (none, remux, transcode)
+	Inputs           string     `json:"inputs,omitempty" valid:"-" gorm:"type:text"`          // Entradas concatenadas (JSON), se houver
+	ClipStart        *float64   `json:"clip_start,omitempty" valid:"-"`                       // Início do corte, em segundos
+	ClipEnd          *float64   `json:"clip_end,omitempty" valid:"-"`                         // Fim do corte, em segundos
+	Watermark        string     `json:"watermark,omitempty" valid:"-" gorm:"type:text"`       // Configuração da marca d'água (JSON), se houver
+	Loudnorm         string     `json:"loudnorm,omitempty" valid:"-" gorm:"type:text"`        // Alvos da normalização de loudness (JSON), se houver
+	EncryptionScheme string     `json:"encryption_scheme,omitempty" valid:"-"`                // Esquema de criptografia CENC (cenc, cbcs), se o conteúdo for criptografado
+	KeyID            string     `json:"key_id,omitempty" valid:"-"`                           // KID da chave de conteúdo (a chave nunca é armazenada)
+	Status           string     `json:"status" valid:"notnull"`                               // Status atual do job (ex: pending, completed)
+	Video            *Video     `json:"video" valid:"-"`                                      // Referência ao vídeo associado
+	VideoID          string     `json:"-" valid:"-" gorm:"column:video_id;type:uuid;notnull"` // Chave estrangeira para o vídeo
+	Error            string     `valid:"-" gorm:"type:text"`                                  // Mensagem de erro, se houver
+	ErrorCode        string     `json:"error_code,omitempty" valid:"-"`                       // Código do erro, se houver
+	CorrelationID    string     `json:"correlation_id,omitempty" valid:"-"`                   // Identificador para correlacionar eventos e mensagens
+	CallbackURL      string     `json:"callback_url,omitempty" valid:"-"`                     // URL notificada via webhook ao final do job
+	Version          int        `json:"version" valid:"-"`                                    // Versão do registro, incrementada a cada atualização (concorrência otimista)
+	HeartbeatAt      *time.Time `json:"heartbeat_at,omitempty" valid:"-"`                     // Último sinal de vida do worker que processa o job
+	Message          string     `json:"-" valid:"-" gorm:"type:text"`                         // Corpo original da mensagem, usado para reenfileirar o job
+	Requeues         int        `json:"requeues,omitempty" valid:"-"`                         // Quantas vezes a mensagem foi reenfileirada
+	OutputsPurgedAt  *time.Time `json:"outputs_purged_at,omitempty" valid:"-"`                // Data em que os arquivos de saída foram removidos pela retenção
+	Priority         int        `json:"priority" valid:"-"`                                   // Prioridade do job na fila de consumo (maior é mais urgente)
+	TenantID         string     `json:"tenant_id,omitempty" valid:"-"`                        // Tenant dono do job
+	DurationSeconds  float64    `json:"duration_seconds,omitempty" valid:"-"`                 // Duração da saída, em segundos, conhecida após a conversão
+	CreatedAt        time.Time  `json:"created_at" valid:"-"`                                 // Data de criação
+	UpdatedAt        time.Time  `json:"updated_at" valid:"-"`                                 // Data da última atualização
 }
 
 /*
@@ -55,15 +90,21 @@ func NewJob(output string, status string, video *Video) (*Job, error) {
 }
 
 /*
-prepare define os valores padrão de um job (ID, datas)
+prepare define os valores padrão de um job (ID, versão, datas)
 Essa função é privada (letra minúscula) e só pode ser chamada dentro do mesmo pacote
 */
 func (job *Job) prepare() {
 	job.ID = uuid.NewV4().String()
+	job.Version = 1
 	job.CreatedAt = time.Now()
 	job.UpdatedAt = time.Now()
 }
 
+// Finished indica se o job está em um status final (COMPLETED ou FAILED).
+func (job *Job) Finished() bool {
+	return job.Status == JobStatusCompleted || job.Status == JobStatusFailed
+}
+
 /*
 Validate executa a validação do job usando o govalidator
 Retorna erro caso a validação falhe
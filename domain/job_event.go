@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// JobEventSchemaVersion é a versão do esquema dos eventos publicados pelo encoder.
+// Deve ser incrementada sempre que houver uma mudança incompatível no formato.
+const JobEventSchemaVersion = "1.0"
+
+// JobEventType identifica o tipo de um evento do ciclo de vida de um job.
+type JobEventType string
+
+const (
+	JobAccepted     JobEventType = "job.accepted"      // Job criado a partir de uma mensagem válida
+	JobStageChanged JobEventType = "job.stage_changed" // Job avançou para uma nova etapa do processamento
+	JobProgress     JobEventType = "job.progress"      // Percentual de conclusão do job
+	JobCompleted    JobEventType = "job.completed"     // Job concluído com sucesso
+	JobFailed       JobEventType = "job.failed"        // Job ou mensagem com falha
+)
+
+// Códigos de erro enviados nos eventos job.failed (e, em outputs_error, nos eventos job.completed).
+const (
+	ErrorCodeInvalidMessage = "INVALID_MESSAGE"
+	ErrorCodeInvalidVideo   = "INVALID_VIDEO"
+	ErrorCodePersistence    = "PERSISTENCE_ERROR"
+	ErrorCodeDownload       = "DOWNLOAD_FAILED"
+	ErrorCodeConvert        = "CONVERT_FAILED"
+	ErrorCodeFragment       = "FRAGMENT_FAILED"
+	ErrorCodeEncode         = "ENCODE_FAILED"
+	ErrorCodeUpload         = "UPLOAD_FAILED"
+	ErrorCodeFinish         = "FINISH_FAILED"
+	ErrorCodeStalled        = "JOB_STALLED"
+	ErrorCodeQuotaExceeded  = "QUOTA_EXCEEDED"
+	ErrorCodeInternal       = "INTERNAL_ERROR"
+	ErrorCodeOutputs        = "OUTPUTS_UNAVAILABLE" // As URLs dos manifestos não puderam ser geradas (ex.: falha ao assiná-las)
+)
+
+/*
+JobEvent representa uma notificação do ciclo de vida de um job.
+Todos os eventos compartilham o mesmo envelope (versão do esquema, identificador,
+tipo, data e correlation ID); os demais campos são preenchidos conforme o tipo.
+*/
+type JobEvent struct {
+	SchemaVersion  string         `json:"schema_version"`
+	ID             string         `json:"event_id"`
+	Type           JobEventType   `json:"type"`
+	OccurredAt     time.Time      `json:"occurred_at"`
+	CorrelationID  string         `json:"correlation_id,omitempty"`
+	TenantID       string         `json:"tenant_id,omitempty"`
+	JobID          string         `json:"job_id,omitempty"`
+	VideoID        string         `json:"video_id,omitempty"`
+	ResourceID     string         `json:"resource_id,omitempty"`
+	Status         string         `json:"status,omitempty"`
+	PreviousStatus string         `json:"previous_status,omitempty"`
+	Progress       *int           `json:"progress,omitempty"`
+	Outputs        *JobOutputs    `json:"outputs,omitempty"`
+	OutputsError   *JobEventError `json:"outputs_error,omitempty"` // Motivo da ausência de Outputs em um job.completed
+	Error          *JobEventError `json:"error,omitempty"`
+	Message        string         `json:"message,omitempty"` // Corpo original da mensagem, quando não foi possível criar o job
+	JobCreatedAt   *time.Time     `json:"job_created_at,omitempty"`
+	JobUpdatedAt   *time.Time     `json:"job_updated_at,omitempty"`
+}
+
+/*
+JobOutputs lista as URLs dos manifestos gerados por um job concluído.
+Quando os objetos são privados, as URLs são assinadas e expiram em ExpiresAt.
+*/
+type JobOutputs struct {
+	ManifestURL    string     `json:"manifest_url"`               // Manifesto DASH (stream.mpd)
+	HLSManifestURL string     `json:"hls_manifest_url,omitempty"` // Playlist master HLS (master.m3u8)
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// JobEventError descreve a falha de um job com um código estável e a mensagem original.
+type JobEventError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+/*
+NewJobEvent cria um evento do tipo informado preenchido com os dados do job.
+O job pode ser nil quando a falha ocorre antes de sua criação.
+*/
+func NewJobEvent(eventType JobEventType, job *Job) *JobEvent {
+	event := JobEvent{
+		SchemaVersion: JobEventSchemaVersion,
+		ID:            uuid.NewV4().String(),
+		Type:          eventType,
+		OccurredAt:    time.Now(),
+	}
+
+	if job != nil {
+		event.CorrelationID = job.CorrelationID
+		event.TenantID = job.TenantID
+		event.JobID = job.ID
+		event.Status = job.Status
+		createdAt, updatedAt := job.CreatedAt, job.UpdatedAt
+		event.JobCreatedAt = &createdAt
+		event.JobUpdatedAt = &updatedAt
+
+		if job.Video != nil {
+			event.VideoID = job.Video.ID
+			event.ResourceID = job.Video.ResourceID
+		}
+	}
+
+	return &event
+}
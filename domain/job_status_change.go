@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+/*
+JobStatusChange registra uma mudança de status de um job. Version é a versão do job
+em que o novo status foi gravado, o que ordena o histórico mesmo quando várias
+mudanças ocorrem no mesmo instante.
+*/
+type JobStatusChange struct {
+	JobID     string    `json:"-" gorm:"column:job_id;type:uuid;primary_key"`
+	Version   int       `json:"version" gorm:"primary_key;auto_increment:false"`
+	Status    string    `json:"status" gorm:"type:varchar(255)"`
+	ErrorCode string    `json:"error_code,omitempty" gorm:"type:varchar(255)"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewJobStatusChange cria o registro do status atual do job.
+func NewJobStatusChange(job *Job) *JobStatusChange {
+	return &JobStatusChange{
+		JobID:     job.ID,
+		Version:   job.Version,
+		Status:    job.Status,
+		ErrorCode: job.ErrorCode,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Ações registradas pelas regras de retenção.
+const (
+	PurgeActionDeleteJob     = "delete_job"
+	PurgeActionDeleteVideo   = "delete_video"
+	PurgeActionDeleteOutputs = "delete_outputs"
+)
+
+/*
+PurgeAudit registra uma remoção feita por uma regra de retenção: o registro de
+um job ou vídeo, ou os arquivos de saída de um job.
+Em modo de simulação (DryRun), nada é removido, mas a ação é registrada da mesma forma.
+*/
+type PurgeAudit struct {
+	ID        string    `json:"audit_id" gorm:"type:uuid;primary_key"`
+	Rule      string    `json:"rule" gorm:"type:varchar(255)"`
+	Action    string    `json:"action" gorm:"type:varchar(255)"`
+	TargetID  string    `json:"target_id" gorm:"type:varchar(255)"`
+	Detail    string    `json:"detail,omitempty" gorm:"type:text"`
+	DryRun    bool      `json:"dry_run"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewPurgeAudit cria o registro de uma ação de retenção sobre o alvo informado.
+func NewPurgeAudit(rule string, action string, targetID string, detail string, dryRun bool) *PurgeAudit {
+	return &PurgeAudit{
+		ID:        uuid.NewV4().String(),
+		Rule:      rule,
+		Action:    action,
+		TargetID:  targetID,
+		Detail:    detail,
+		DryRun:    dryRun,
+		CreatedAt: time.Now(),
+	}
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"time"
 
 	"github.com/asaskevich/govalidator"
@@ -10,8 +11,16 @@ type Video struct {
 	ID         string    `json:"encoded_video_folder" valid:"uuid" gorm:"type:uuid;primary_key"`
 	ResourceID string    `json:"resource_id" valid:"notnull" gorm:"type:varchar(255)"`
 	FilePath   string    `json:"file_path" valid:"notnull" gorm:"type:varchar(255)"`
+	TenantID   string    `json:"tenant_id,omitempty" valid:"-" gorm:"type:varchar(255)"` // Tenant dono do vídeo
 	CreatedAt  time.Time `json:"-" valid:"-"`
 	Jobs       []*Job    `json:"-" valid:"-" gorm:"ForeignKey:VideoID"`
+	Tags       string    `json:"-" valid:"-" gorm:"type:text"` // Tags do vídeo no formato ",tag1,tag2,", usadas pelas regras de retenção
+
+	// Loudness do áudio de entrada, medido quando o job pede normalização (EBU R128).
+	InputLoudness          *float64 `json:"input_loudness,omitempty" valid:"-"`           // Loudness integrado, em LUFS
+	InputTruePeak          *float64 `json:"input_true_peak,omitempty" valid:"-"`          // Pico real, em dBTP
+	InputLoudnessRange     *float64 `json:"input_loudness_range,omitempty" valid:"-"`     // Faixa de loudness, em LU
+	InputLoudnessThreshold *float64 `json:"input_loudness_threshold,omitempty" valid:"-"` // Limiar de gating, em LUFS
 }
 
 func init() {
@@ -32,3 +41,42 @@ func (video *Video) Validate() error {
 
 	return nil
 }
+
+/*
+SetTags define as tags do vídeo, ignorando as vazias e as repetidas.
+As tags são armazenadas entre vírgulas (",tag1,tag2,") para que a busca por uma tag
+no banco seja um simples LIKE '%,tag,%'.
+*/
+func (video *Video) SetTags(tags []string) {
+	seen := map[string]bool{}
+	stored := ""
+
+	for _, tag := range tags {
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		stored += "," + tag
+	}
+
+	if stored != "" {
+		stored += ","
+	}
+
+	video.Tags = stored
+}
+
+// TagList retorna as tags do vídeo, na ordem em que foram definidas.
+func (video *Video) TagList() []string {
+	trimmed := strings.Trim(video.Tags, ",")
+	if trimmed == "" {
+		return nil
+	}
+
+	return strings.Split(trimmed, ",")
+}
+
+// HasTag verifica se o vídeo possui a tag informada.
+func (video *Video) HasTag(tag string) bool {
+	return tag != "" && strings.Contains(video.Tags, ","+tag+",")
+}
@@ -39,3 +39,16 @@ func TestVideoValidation(t *testing.T) {
 	err := video.Validate()
 	require.Nil(t, err)
 }
+
+func TestVideoTags(t *testing.T) {
+	video := domain.NewVideo()
+	require.Nil(t, video.TagList())
+	require.False(t, video.HasTag("promo"))
+
+	video.SetTags([]string{"promo", "", "trailer", "promo"})
+	require.Equal(t, ",promo,trailer,", video.Tags)
+	require.Equal(t, []string{"promo", "trailer"}, video.TagList())
+	require.True(t, video.HasTag("trailer"))
+	require.False(t, video.HasTag("trail"))
+	require.False(t, video.HasTag(""))
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+/*
+WebhookDelivery registra uma tentativa de entrega de um evento de job
+para a callback_url informada na mensagem.
+Cada tentativa (inclusive as repetições) gera um novo registro.
+*/
+type WebhookDelivery struct {
+	ID         string    `json:"delivery_id" gorm:"type:uuid;primary_key"`
+	JobID      string    `json:"job_id" gorm:"column:job_id;type:uuid;notnull"`
+	EventID    string    `json:"event_id" gorm:"type:varchar(255)"`
+	EventType  string    `json:"event_type" gorm:"type:varchar(255)"`
+	URL        string    `json:"url" gorm:"type:varchar(2048)"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewWebhookDelivery cria o registro de uma tentativa de entrega de um evento.
+func NewWebhookDelivery(job *Job, event *JobEvent, attempt int) *WebhookDelivery {
+	return &WebhookDelivery{
+		ID:        uuid.NewV4().String(),
+		JobID:     job.ID,
+		EventID:   event.ID,
+		EventType: string(event.Type),
+		URL:       job.CallbackURL,
+		Attempt:   attempt,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"net/http"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// APIKeyHeader é o cabeçalho alternativo a "Authorization: Bearer <chave>".
+const APIKeyHeader = "X-API-Key"
+
+// Falhas de autenticação; as mensagens são devolvidas ao cliente e registradas em log.
+var (
+	ErrMissingAPIKey = errors.New("missing API key")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	ErrRevokedAPIKey = errors.New("revoked API key")
+)
+
+// ErrTenantNotAllowed indica que a chave não pode acessar o tenant pedido.
+var ErrTenantNotAllowed = errors.New("tenant not allowed for this API key")
+
+// contextKey é a chave do contexto da requisição que guarda a chave de acesso autenticada.
+type contextKey struct{}
+
+/*
+Authenticator autentica as requisições da API HTTP pelas chaves de acesso e
+autoriza as operações pelos escopos da chave. As falhas são registradas em log
+com o método, o caminho, o endereço de origem e, no máximo, o prefixo público da
+chave: o segredo nunca é registrado.
+*/
+type Authenticator struct {
+	Keys repositories.APIKeyRepository
+}
+
+// NewAuthenticator cria um Authenticator com as chaves armazenadas no banco.
+func NewAuthenticator(db *gorm.DB) *Authenticator {
+	return &Authenticator{Keys: repositories.APIKeyRepositoryDb{Db: db}}
+}
+
+/*
+Authenticate retorna a chave ativa informada na requisição, em "Authorization: Bearer"
+ou em X-API-Key. Falhas do banco são retornadas com a causa.
+*/
+func (a *Authenticator) Authenticate(r *http.Request) (*domain.APIKey, error) {
+	secret := requestAPIKey(r)
+	if secret == "" {
+		return nil, ErrMissingAPIKey
+	}
+
+	prefix, ok := domain.APIKeyPrefix(secret)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := a.Keys.FindByPrefix(prefix)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.Matches(secret) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.Revoked() {
+		return nil, ErrRevokedAPIKey
+	}
+
+	return key, nil
+}
+
+/*
+Require retorna um middleware que exige uma chave ativa com o escopo informado.
+Sem chave válida, a resposta é 401; sem o escopo, 403. A chave autenticada fica
+disponível para o handler em KeyFrom.
+*/
+func (a *Authenticator) Require(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := a.Authenticate(r)
+			if err != nil {
+				prefix, _ := domain.APIKeyPrefix(requestAPIKey(r))
+				log.Printf("api: authentication failed for %v %v from %v (key prefix: %q): %v", r.Method, r.URL.Path, r.RemoteAddr, prefix, err)
+
+				if !isAuthError(err) {
+					writeError(w, http.StatusInternalServerError, "internal error")
+					return
+				}
+
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			if !key.HasScope(scope) {
+				log.Printf("api: authorization failed for %v %v from %v (key %v): missing scope %v", r.Method, r.URL.Path, r.RemoteAddr, key.Prefix, scope)
+				writeError(w, http.StatusForbidden, fmt.Sprintf("API key does not have the %v scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, key)))
+		})
+	}
+}
+
+// KeyFrom retorna a chave autenticada por Require, ou nil fora de uma rota protegida.
+func KeyFrom(ctx context.Context) *domain.APIKey {
+	key, _ := ctx.Value(contextKey{}).(*domain.APIKey)
+	return key
+}
+
+/*
+TenantFilter restringe o filtro de uma consulta ao tenant da chave da requisição.
+Chaves sem tenant consultam todos os tenants e mantêm o filtro informado.
+*/
+func TenantFilter(ctx context.Context, filter repositories.ListFilter) repositories.ListFilter {
+	if key := KeyFrom(ctx); key != nil && key.TenantID != "" {
+		filter.TenantID = key.TenantID
+	}
+
+	return filter
+}
+
+/*
+TenantFor retorna o tenant de um job enviado pela requisição: o da chave, quando
+ela pertence a um tenant, ou o pedido, para chaves sem tenant. Retorna
+ErrTenantNotAllowed se a chave pedir um tenant diferente do seu.
+*/
+func TenantFor(ctx context.Context, requested string) (string, error) {
+	key := KeyFrom(ctx)
+	if key == nil || key.TenantID == "" {
+		return requested, nil
+	}
+
+	if requested != "" && requested != key.TenantID {
+		return "", ErrTenantNotAllowed
+	}
+
+	return key.TenantID, nil
+}
+
+/*
+CanAccess verifica se a chave da requisição pode acessar um registro do tenant
+informado. Os handlers devem responder 404 quando não puder, para não revelar
+a existência de registros de outros tenants.
+*/
+func CanAccess(ctx context.Context, tenantID string) bool {
+	key := KeyFrom(ctx)
+	return key != nil && (key.TenantID == "" || key.TenantID == tenantID)
+}
+
+// requestAPIKey lê a chave do cabeçalho Authorization (Bearer) ou, na ausência dele, de X-API-Key.
+func requestAPIKey(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(authorization[len("Bearer "):])
+	}
+
+	return strings.TrimSpace(r.Header.Get(APIKeyHeader))
+}
+
+// isAuthError verifica se o erro é uma falha de autenticação, e não do banco.
+func isAuthError(err error) bool {
+	return errors.Is(err, ErrMissingAPIKey) || errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrRevokedAPIKey)
+}
+
+// writeError responde com o status e a mensagem de erro em JSON.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
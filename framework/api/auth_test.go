@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"bytes"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/api"
+	"microsservico-encoder/framework/database"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// captureLog redireciona o log padrão durante o teste e retorna o buffer com as linhas registradas.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	log.SetOutput(&buffer)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	return &buffer
+}
+
+/*
+TestAuthenticatorRequire verifica que o middleware aceita apenas chaves ativas com o
+escopo da rota, pelos dois cabeçalhos, e que as falhas não registram o segredo.
+*/
+func TestAuthenticatorRequire(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	keys := repositories.APIKeyRepositoryDb{Db: db}
+	newKey := func(tenantID string, scopes ...string) (*domain.APIKey, string) {
+		key, secret, err := domain.NewAPIKey("test", tenantID, scopes)
+		require.Nil(t, err)
+		_, err = keys.Insert(key)
+		require.Nil(t, err)
+		return key, secret
+	}
+
+	_, reader := newKey("acme", domain.ScopeRead)
+	_, admin := newKey("", domain.ScopeAdmin)
+	revoked, revokedSecret := newKey("acme", domain.ScopeSubmit)
+	require.Nil(t, keys.Revoke(revoked.ID, time.Now()))
+
+	authenticator := &api.Authenticator{Keys: keys}
+	handler := authenticator.Require(domain.ScopeSubmit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(api.KeyFrom(r.Context()).Prefix))
+	}))
+
+	request := func(header string, value string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	output := captureLog(t)
+
+	w := request("Authorization", "Bearer "+admin)
+	require.Equal(t, http.StatusOK, w.Code)
+	prefix, _ := domain.APIKeyPrefix(admin)
+	require.Equal(t, prefix, w.Body.String())
+
+	require.Equal(t, http.StatusOK, request(api.APIKeyHeader, admin).Code)
+
+	w = request("", "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	require.JSONEq(t, `{"error": "missing API key"}`, w.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, request("Authorization", "Bearer "+admin+"x").Code)
+	require.Equal(t, http.StatusUnauthorized, request("Authorization", "Bearer not-a-key").Code)
+
+	w = request(api.APIKeyHeader, revokedSecret)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.JSONEq(t, `{"error": "revoked API key"}`, w.Body.String())
+
+	require.Equal(t, http.StatusForbidden, request("Authorization", "Bearer "+reader).Code)
+
+	require.Contains(t, output.String(), revoked.Prefix)
+	for _, secret := range []string{admin, reader, revokedSecret} {
+		require.NotContains(t, output.String(), secret)
+	}
+}
+
+// TestTenantScoping verifica que as consultas e os envios ficam restritos ao tenant da chave.
+func TestTenantScoping(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	keys := repositories.APIKeyRepositoryDb{Db: db}
+	authenticator := &api.Authenticator{Keys: keys}
+
+	serve := func(tenantID string, handler http.HandlerFunc) {
+		key, secret, err := domain.NewAPIKey("test", tenantID, []string{domain.ScopeRead})
+		require.Nil(t, err)
+		_, err = keys.Insert(key)
+		require.Nil(t, err)
+
+		r := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+		r.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		authenticator.Require(domain.ScopeRead)(handler).ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	serve("acme", func(w http.ResponseWriter, r *http.Request) {
+		filter := api.TenantFilter(r.Context(), repositories.ListFilter{TenantID: "other", Status: domain.JobStatusFailed})
+		require.Equal(t, repositories.ListFilter{TenantID: "acme", Status: domain.JobStatusFailed}, filter)
+
+		tenantID, err := api.TenantFor(r.Context(), "")
+		require.Nil(t, err)
+		require.Equal(t, "acme", tenantID)
+
+		_, err = api.TenantFor(r.Context(), "other")
+		require.ErrorIs(t, err, api.ErrTenantNotAllowed)
+
+		require.True(t, api.CanAccess(r.Context(), "acme"))
+		require.False(t, api.CanAccess(r.Context(), "other"))
+	})
+
+	serve("", func(w http.ResponseWriter, r *http.Request) {
+		filter := api.TenantFilter(r.Context(), repositories.ListFilter{TenantID: "other"})
+		require.Equal(t, "other", filter.TenantID)
+
+		tenantID, err := api.TenantFor(r.Context(), "other")
+		require.Nil(t, err)
+		require.Equal(t, "other", tenantID)
+
+		require.True(t, api.CanAccess(r.Context(), "acme"))
+	})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"net/http"
+)
+
+// maxSubmitBodySize limita o corpo das mensagens de job enviadas pela API.
+const maxSubmitBodySize = 1 << 20
+
+/*
+SubmitHandler publica na fila de consumo os jobs enviados via POST, com o corpo no
+formato de JobMessage. Deve ser protegido por Require(domain.ScopeSubmit): o tenant
+do job é o da chave (ver TenantFor) e é gravado na mensagem, de modo que um produtor
+não consome as cotas nem publica nos eventos de outro tenant.
+Responde 202 com o correlation ID e o tenant do job, 400 para mensagens inválidas e
+403 quando o corpo pede um tenant diferente do da chave.
+*/
+type SubmitHandler struct {
+	Publisher  queue.Publisher
+	Exchange   string
+	RoutingKey string
+}
+
+func (h *SubmitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSubmitBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "error reading request body")
+		return
+	}
+
+	var requested struct {
+		TenantID string `json:"tenant_id"`
+	}
+	err = json.Unmarshal(body, &requested)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job message: "+err.Error())
+		return
+	}
+
+	tenantID, err := TenantFor(r.Context(), requested.TenantID)
+	if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	jobMessage, err := services.SubmitJob(h.Publisher, h.Exchange, h.RoutingKey, body, tenantID)
+	if errors.Is(err, services.ErrInvalidJobMessage) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err != nil {
+		log.Printf("api: error publishing job: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"correlation_id": jobMessage.CorrelationID,
+		"tenant_id":      jobMessage.TenantID,
+	})
+}
@@ -0,0 +1,78 @@
+package api_test
+
+import (
+	"encoding/json"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"microsservico-encoder/framework/api"
+	"microsservico-encoder/framework/database"
+	"microsservico-encoder/framework/queue"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+TestSubmitHandler verifica que o job enviado pela API é publicado com o tenant da
+chave, gravado no corpo e no cabeçalho, e que a chave não pode pedir outro tenant.
+*/
+func TestSubmitHandler(t *testing.T) {
+	db := database.NewDbTest()
+	defer db.Close()
+
+	keys := repositories.APIKeyRepositoryDb{Db: db}
+	newKey := func(tenantID string) string {
+		key, secret, err := domain.NewAPIKey("test", tenantID, []string{domain.ScopeSubmit})
+		require.Nil(t, err)
+		_, err = keys.Insert(key)
+		require.Nil(t, err)
+		return secret
+	}
+
+	acme := newKey("acme")
+	global := newKey("")
+
+	broker := queue.NewMemoryBroker(0)
+	authenticator := &api.Authenticator{Keys: keys}
+	handler := authenticator.Require(domain.ScopeSubmit)(&api.SubmitHandler{Publisher: broker, RoutingKey: "videos"})
+
+	submit := func(secret string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := submit(acme, `{"resource_id": "a", "file_path": "a.mp4"}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var response map[string]string
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, "acme", response["tenant_id"])
+	require.NotEmpty(t, response["correlation_id"])
+
+	require.Equal(t, http.StatusForbidden, submit(acme, `{"resource_id": "a", "file_path": "a.mp4", "tenant_id": "other"}`).Code)
+	require.Equal(t, http.StatusBadRequest, submit(acme, `{"resource_id": "a", "file_path": "a.mp4", "priority": 11}`).Code)
+	require.Equal(t, http.StatusBadRequest, submit(acme, `not a json`).Code)
+
+	require.Equal(t, http.StatusAccepted, submit(global, `{"resource_id": "b", "file_path": "b.mp4", "tenant_id": "other"}`).Code)
+
+	published := broker.Published()
+	require.Len(t, published, 2)
+
+	tenants := []string{}
+	for _, p := range published {
+		require.Equal(t, "videos", p.RoutingKey)
+
+		jobMessage, err := services.ParseJobMessage(broker.Enqueue(p.Publishing.Body, nil))
+		require.Nil(t, err)
+		require.Equal(t, jobMessage.TenantID, p.Publishing.Headers[services.TenantIDHeader])
+		tenants = append(tenants, jobMessage.TenantID)
+	}
+	require.Equal(t, []string{"acme", "other"}, tenants)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/domain"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*
+runAPIKey executa o subcomando "apikey":
+
+	cli apikey create -name nome -scopes submit,read [-tenant id]
+	cli apikey list [-tenant id]
+	cli apikey revoke <id>
+
+A chave completa é exibida apenas na criação; o banco guarda somente o hash.
+Sem -tenant, a chave acessa os jobs de todos os tenants.
+*/
+func runAPIKey(args []string) {
+	if len(args) < 1 {
+		log.Fatalf("usage: cli apikey create|list|revoke")
+	}
+
+	switch args[0] {
+	case "create":
+		createAPIKey(args[1:])
+	case "list":
+		listAPIKeys(args[1:])
+	case "revoke":
+		revokeAPIKey(args[1:])
+	default:
+		log.Fatalf("unknown apikey command %q: use create, list or revoke", args[0])
+	}
+}
+
+func createAPIKey(args []string) {
+	flags := flag.NewFlagSet("apikey create", flag.ExitOnError)
+	name := flags.String("name", "", "nome que identifica o cliente da chave")
+	tenantID := flags.String("tenant", "", "tenant da chave (vazio: todos os tenants)")
+	scopes := flags.String("scopes", domain.ScopeRead, "escopos separados por vírgula: submit, read, admin")
+	flags.Parse(args)
+
+	// Com tenants configurados, a chave só pode pertencer a um deles.
+	if *tenantID != "" {
+		tenants, err := services.LoadTenants()
+		if err != nil {
+			log.Fatalf("error loading tenants: %v", err)
+		}
+
+		_, err = tenants.Resolve(*tenantID)
+		if err != nil {
+			log.Fatalf("error creating api key: %v", err)
+		}
+	}
+
+	key, secret, err := domain.NewAPIKey(*name, *tenantID, strings.Split(*scopes, ","))
+	if err != nil {
+		log.Fatalf("error creating api key: %v", err)
+	}
+
+	dbConnection := connectDb()
+	defer dbConnection.Close()
+
+	_, err = repositories.APIKeyRepositoryDb{Db: dbConnection}.Insert(key)
+	if err != nil {
+		log.Fatalf("error creating api key: %v", err)
+	}
+
+	fmt.Printf("api key %v created (prefix %v, scopes %v)\n", key.ID, key.Prefix, key.Scopes)
+	fmt.Printf("key: %v\n", secret)
+	fmt.Println("store the key now: it cannot be shown again")
+}
+
+func listAPIKeys(args []string) {
+	flags := flag.NewFlagSet("apikey list", flag.ExitOnError)
+	tenantID := flags.String("tenant", "", "lista apenas as chaves deste tenant")
+	flags.Parse(args)
+
+	dbConnection := connectDb()
+	defer dbConnection.Close()
+
+	keys, err := repositories.APIKeyRepositoryDb{Db: dbConnection}.List(*tenantID)
+	if err != nil {
+		log.Fatalf("error listing api keys: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPREFIX\tNAME\tTENANT\tSCOPES\tCREATED\tREVOKED")
+	for _, key := range keys {
+		revoked := "-"
+		if key.Revoked() {
+			revoked = key.RevokedAt.Format(time.RFC3339)
+		}
+
+		tenant := key.TenantID
+		if tenant == "" {
+			tenant = "*"
+		}
+
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			key.ID, key.Prefix, key.Name, tenant, key.Scopes, key.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	w.Flush()
+}
+
+func revokeAPIKey(args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: cli apikey revoke <id>")
+	}
+
+	dbConnection := connectDb()
+	defer dbConnection.Close()
+
+	err := repositories.APIKeyRepositoryDb{Db: dbConnection}.Revoke(args[0], time.Now())
+	if err != nil {
+		log.Fatalf("error revoking api key: %v", err)
+	}
+
+	fmt.Printf("api key %v revoked\n", args[0])
+}
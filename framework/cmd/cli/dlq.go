@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"os"
+)
+
+/*
+runDlq executa o subcomando "dlq":
+
+	cli dlq replay [-queue fila] [-resource-id id] [-correlation-id id] [-limit n] [-dry-run]
+
+As mensagens da fila de mensagens mortas (RABBITMQ_DLQ) que atendem aos filtros são
+republicadas na fila de consumo (RABBITMQ_CONSUMER_QUEUE_NAME); as demais permanecem na DLQ.
+*/
+func runDlq(args []string) {
+	if len(args) < 1 || args[0] != "replay" {
+		log.Fatalf("usage: cli dlq replay [flags]")
+	}
+
+	flags := flag.NewFlagSet("dlq replay", flag.ExitOnError)
+	deadLetterQueue := flags.String("queue", os.Getenv("RABBITMQ_DLQ"), "fila de mensagens mortas")
+	resourceID := flags.String("resource-id", "", "republica apenas as mensagens deste resource_id")
+	correlationID := flags.String("correlation-id", "", "republica apenas as mensagens deste correlation ID")
+	limit := flags.Int("limit", 100, "quantidade máxima de mensagens lidas da DLQ (0: todas)")
+	dryRun := flags.Bool("dry-run", false, "apenas conta as mensagens, sem republicá-las")
+	flags.Parse(args[1:])
+
+	if *deadLetterQueue == "" {
+		log.Fatalf("dead letter queue not informed: use -queue or RABBITMQ_DLQ")
+	}
+
+	rabbitMQ := queue.NewRabbitMQ()
+	ch := rabbitMQ.Connect()
+	defer ch.Close()
+
+	replayer := &services.DeadLetterReplayer{
+		Getter:     rabbitMQ,
+		Publisher:  rabbitMQ,
+		Queue:      *deadLetterQueue,
+		RoutingKey: rabbitMQ.ConsumerQueueName,
+		DryRun:     *dryRun,
+	}
+
+	result, err := replayer.Replay(services.DeadLetterFilter{
+		ResourceID:    *resourceID,
+		CorrelationID: *correlationID,
+		Limit:         *limit,
+	})
+	if err != nil {
+		log.Fatalf("error replaying dead letters: %v", err)
+	}
+
+	fmt.Printf("%d matched, %d replayed, %d left in %v (dry run: %v)\n",
+		result.Matched, result.Replayed, result.Matched-result.Replayed+result.Skipped, *deadLetterQueue, *dryRun)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"microsservico-encoder/application/services"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/*
+runEncode executa o subcomando "encode":
+
+	cli encode [-out dir] [-bucket nome] [-profile perfil] [-output-path template] [-start s] [-end s] <arquivo>
+
+A saída é gravada em <out>/<bucket>/<caminho de saída>. Sem localStoragePath,
+os arquivos intermediários usam uma pasta temporária, removida ao final.
+*/
+func runEncode(args []string) {
+	defaultBucket := os.Getenv("outputBucketName")
+	if defaultBucket == "" {
+		defaultBucket = "local"
+	}
+
+	flags := flag.NewFlagSet("encode", flag.ExitOnError)
+	out := flags.String("out", "output", "diretório de saída")
+	bucket := flags.String("bucket", defaultBucket, "bucket de saída (subdiretório de -out)")
+	profile := flags.String("profile", "", "perfil de encoding (padrão: default)")
+	outputPath := flags.String("output-path", "", "template do caminho de saída, ex.: {resource_id}/{video_id}")
+	start := flags.String("start", "", "início do corte, em segundos")
+	end := flags.String("end", "", "fim do corte, em segundos")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("usage: cli encode [flags] <file>")
+	}
+
+	if os.Getenv("localStoragePath") == "" {
+		workDir, err := ioutil.TempDir("", "encoder")
+		if err != nil {
+			log.Fatalf("error creating work directory: %v", err)
+		}
+		defer os.RemoveAll(workDir)
+		os.Setenv("localStoragePath", workDir)
+	}
+
+	message := services.JobMessage{
+		OutputBucket: *bucket,
+		OutputPath:   *outputPath,
+		Profile:      *profile,
+		Start:        parseSeconds("start", *start),
+		End:          parseSeconds("end", *end),
+	}
+
+	encoder := &services.LocalEncoder{OutputDir: *out}
+	job, err := encoder.Encode(flags.Arg(0), message)
+	if err != nil {
+		log.Fatalf("error encoding %v: %v", flags.Arg(0), err)
+	}
+
+	fmt.Printf("job %v %v\n", job.ID, job.Status)
+	fmt.Printf("output: %v\n", filepath.Join(*out, job.OutputBucket, filepath.FromSlash(job.OutputBucketPath)))
+}
+
+// parseSeconds converte o valor de uma flag em segundos, retornando nil quando vazio.
+func parseSeconds(name string, value string) *float64 {
+	if value == "" {
+		return nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("invalid -%v: %v", name, value)
+	}
+
+	return &seconds
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"microsservico-encoder/application/services"
+	"microsservico-encoder/framework/queue"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+/*
+runJob executa o subcomando "job":
+
+	cli job show <id>           exibe o job em JSON e o histórico de status
+	cli job retry <id>          reenfileira a mensagem original de um job que falhou na fila de consumo
+	cli job submit <file.json>  publica um novo job na fila de consumo, com a prioridade AMQP do campo priority
+*/
+func runJob(args []string) {
+	if len(args) != 2 {
+		log.Fatalf("usage: cli job show|retry <id> | cli job submit <file.json>")
+	}
+
+	if args[0] == "submit" {
+		submitJob(args[1])
+		return
+	}
+
+	dbConnection := connectDb()
+	defer dbConnection.Close()
+
+	jobs := repositories.JobRepositoryDb{Db: dbConnection}
+	id := args[1]
+
+	switch args[0] {
+	case "show":
+		job, err := jobs.Find(id)
+		if err != nil {
+			log.Fatalf("error finding job: %v", err)
+		}
+
+		history, err := jobs.History(id)
+		if err != nil {
+			log.Fatalf("error finding job history: %v", err)
+		}
+
+		out, err := json.MarshalIndent(job, "", "  ")
+		if err != nil {
+			log.Fatalf("error formatting job: %v", err)
+		}
+		fmt.Println(string(out))
+
+		fmt.Println("\nhistory:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, change := range history {
+			fmt.Fprintf(w, "  v%d\t%v\t%v\t%v\n", change.Version, change.CreatedAt.Format(time.RFC3339), change.Status, change.ErrorCode)
+		}
+		w.Flush()
+
+	case "retry":
+		rabbitMQ := queue.NewRabbitMQ()
+		ch := rabbitMQ.Connect()
+		defer ch.Close()
+
+		job, err := services.RetryJob(jobs, rabbitMQ, "", rabbitMQ.ConsumerQueueName, id)
+		if err != nil {
+			log.Fatalf("error retrying job: %v", err)
+		}
+
+		fmt.Printf("job %v re-enqueued on %v (correlation id %v)\n", job.ID, rabbitMQ.ConsumerQueueName, job.CorrelationID)
+
+	default:
+		log.Fatalf("unknown job command %q: use show, retry or submit", args[0])
+	}
+}
+
+// submitJob publica a mensagem do arquivo informado ("-" lê da entrada padrão) na fila de consumo.
+func submitJob(path string) {
+	var body []byte
+	var err error
+	if path == "-" {
+		body, err = io.ReadAll(os.Stdin)
+	} else {
+		body, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		log.Fatalf("error reading job message: %v", err)
+	}
+
+	rabbitMQ := queue.NewRabbitMQ()
+	ch := rabbitMQ.Connect()
+	defer ch.Close()
+
+	jobMessage, err := services.SubmitJob(rabbitMQ, "", rabbitMQ.ConsumerQueueName, body, "")
+	if err != nil {
+		log.Fatalf("error submitting job: %v", err)
+	}
+
+	fmt.Printf("job for %v submitted to %v with priority %d (correlation id %v)\n", jobMessage.ResourceID, rabbitMQ.ConsumerQueueName, *jobMessage.Priority, jobMessage.CorrelationID)
+}
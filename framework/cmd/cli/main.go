@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"microsservico-encoder/framework/database"
+	"os"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: cli <command> [arguments]
+
+commands:
+  encode [flags] <file>   executa o pipeline completo para um arquivo local, sem broker ou bucket
+  job show <id>           exibe o job e o histórico de status
+  job retry <id>          reenfileira a mensagem original de um job que falhou
+  job submit <file.json>  publica um novo job com a prioridade AMQP do campo priority
+  dlq replay [flags]      move as mensagens mortas de volta para a fila de entrada
+  usage [flags]           exibe o uso (jobs e minutos processados) de cada tenant no período
+  apikey create [flags]   cria uma chave de acesso à API HTTP (exibida uma única vez)
+  apikey list [flags]     lista as chaves de acesso, sem os segredos
+  apikey revoke <id>      revoga uma chave de acesso
+
+Use "cli <command> -h" para ver as flags de cada comando.
+`
+
+/*
+main é o ponto de entrada da CLI de operação do encoder.
+As variáveis de ambiente são lidas do .env, quando presente, como no servidor.
+*/
+func main() {
+	log.SetFlags(0)
+
+	err := godotenv.Load()
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("error loading .env file: %v", err)
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "encode":
+		runEncode(args)
+	case "job":
+		runJob(args)
+	case "dlq":
+		runDlq(args)
+	case "usage":
+		runUsage(args)
+	case "apikey":
+		runAPIKey(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%v", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+/*
+connectDb conecta ao banco configurado nas variáveis de ambiente, como o servidor.
+A CLI nunca aplica migrações: com o esquema desatualizado, a conexão falha.
+*/
+func connectDb() *gorm.DB {
+	debug, _ := strconv.ParseBool(os.Getenv("DEBUG"))
+
+	db := database.Database{
+		Dsn:        os.Getenv("DSN"),
+		DsnTest:    os.Getenv("DSN_TEST"),
+		DbType:     os.Getenv("DB_TYPE"),
+		DbTypeTest: os.Getenv("DB_TYPE_TEST"),
+		Env:        os.Getenv("ENV"),
+		Debug:      debug,
+	}
+
+	dbConnection, err := db.Connect()
+	if err != nil {
+		log.Fatalf("error connecting to DB: %v", err)
+	}
+
+	return dbConnection
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"microsservico-encoder/application/repositories"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// dateLayout é o formato das datas aceitas pelas flags da CLI.
+const dateLayout = "2006-01-02"
+
+/*
+runUsage executa o subcomando "usage":
+
+	cli usage [-from AAAA-MM-DD] [-to AAAA-MM-DD] [-tenant id]
+
+Exibe, por tenant, os jobs criados no período (por padrão, o mês corrente, em UTC)
+e os minutos de saída processados. A data final não é incluída.
+*/
+func runUsage(args []string) {
+	now := time.Now().UTC()
+
+	flags := flag.NewFlagSet("usage", flag.ExitOnError)
+	from := flags.String("from", now.AddDate(0, 0, 1-now.Day()).Format(dateLayout), "início do período (incluído)")
+	to := flags.String("to", "", "fim do período (não incluído; padrão: agora)")
+	tenantID := flags.String("tenant", "", "exibe apenas este tenant")
+	flags.Parse(args)
+
+	filter := repositories.ListFilter{TenantID: *tenantID}
+
+	var err error
+	filter.CreatedFrom, err = time.Parse(dateLayout, *from)
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+
+	if *to != "" {
+		filter.CreatedTo, err = time.Parse(dateLayout, *to)
+		if err != nil {
+			log.Fatalf("invalid -to: %v", err)
+		}
+	}
+
+	dbConnection := connectDb()
+	defer dbConnection.Close()
+
+	usages, err := repositories.JobRepositoryDb{Db: dbConnection}.Usage(filter)
+	if err != nil {
+		log.Fatalf("error reading usage: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tJOBS\tCOMPLETED\tFAILED\tACTIVE\tMINUTES")
+	for _, usage := range usages {
+		fmt.Fprintf(w, "%v\t%d\t%d\t%d\t%d\t%.1f\n",
+			usage.TenantID, usage.Jobs, usage.Completed, usage.Failed, usage.Active(), usage.Minutes())
+	}
+	w.Flush()
+}
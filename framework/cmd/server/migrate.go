@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"microsservico-encoder/framework/database"
+	"strconv"
+)
+
+/*
+migrate executa o subcomando "migrate" do servidor:
+
+	server migrate up        aplica as migrações pendentes
+	server migrate down [n]  reverte as últimas n migrações (padrão: 1)
+	server migrate status    exibe a versão atual e as migrações pendentes
+	server migrate unlock    remove o lock do SQLite deixado por uma execução interrompida
+*/
+func migrate(args []string) {
+	dbConnection, err := db.Open()
+	if err != nil {
+		log.Fatalf("error connecting to DB: %v", err)
+	}
+	defer dbConnection.Close()
+
+	migrator, err := database.NewMigrator(dbConnection)
+	if err != nil {
+		log.Fatalf("error loading migrations: %v", err)
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			log.Fatalf("error applying migrations: %v", err)
+		}
+		fmt.Printf("%d migration(s) applied\n", len(applied))
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid number of migrations to revert: %v", args[1])
+			}
+		}
+
+		reverted, err := migrator.Down(steps)
+		if err != nil {
+			log.Fatalf("error reverting migrations: %v", err)
+		}
+		fmt.Printf("%d migration(s) reverted\n", len(reverted))
+
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("error reading migration status: %v", err)
+		}
+
+		fmt.Printf("current version: %d\nlatest version: %d\n", status.Current, status.Latest)
+		for _, migration := range status.Pending {
+			fmt.Printf("pending: %04d_%v\n", migration.Version, migration.Name)
+		}
+
+	case "unlock":
+		err := migrator.Unlock()
+		if err != nil {
+			log.Fatalf("error removing migration lock: %v", err)
+		}
+		fmt.Println("migration lock removed")
+
+	default:
+		log.Fatalf("unknown migrate command %q: use up, down [n], status or unlock", command)
+	}
+}
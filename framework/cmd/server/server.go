@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 	"microsservico-encoder/application/services"
 	"microsservico-encoder/framework/database"
 	"microsservico-encoder/framework/queue"
+	"microsservico-encoder/framework/storage"
 	"os"
 	"strconv"
 
 	"github.com/joho/godotenv"
-	"github.com/streadway/amqp"
 )
 
 // db representa a instância de configuração do banco de dados.
@@ -49,18 +50,24 @@ func init() {
 main é o ponto de entrada da aplicação.
 Ela estabelece conexões com o banco e o RabbitMQ, inicializa os canais de mensagens,
 instancia o JobManager e inicia o processamento.
+Com o argumento "migrate", executa apenas as migrações do banco (ver migrate).
 */
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
 	// Canais de comunicação para mensagens da fila e retorno dos jobs
-	messageChannel := make(chan amqp.Delivery)
+	messageChannel := make(chan queue.Message)
 	jobReturnChannel := make(chan services.JobWorkerResult)
 
 	// Conecta ao banco de dados
 	dbConnection, err := db.Connect()
 
 	if err != nil {
-		log.Fatalf("error connecting to DB")
+		log.Fatalf("error connecting to DB: %v", err)
 	}
 
 	defer dbConnection.Close()
@@ -73,7 +80,35 @@ func main() {
 	// Inicia o consumo de mensagens da fila
 	rabbitMQ.Consume(messageChannel)
 
+	// Inicia o reaper de jobs travados; o lock no banco garante uma única instância ativa
+	reaperEnabled, _ := strconv.ParseBool(os.Getenv("REAPER_ENABLED"))
+	if reaperEnabled {
+		reaper, err := services.NewJobReaper(dbConnection, rabbitMQ)
+		if err != nil {
+			log.Fatalf("error configuring job reaper: %v", err)
+		}
+
+		go reaper.Run(context.Background())
+	}
+
+	// Inicia o purger das regras de retenção; o lock no banco garante uma única instância ativa
+	retentionEnabled, _ := strconv.ParseBool(os.Getenv("RETENTION_ENABLED"))
+	if retentionEnabled {
+		client, err := storage.NewGCSClient(context.Background())
+		if err != nil {
+			log.Fatalf("error creating storage client for retention: %v", err)
+		}
+		defer client.Close()
+
+		purger, err := services.NewPurger(dbConnection, client)
+		if err != nil {
+			log.Fatalf("error configuring retention purger: %v", err)
+		}
+
+		go purger.Run(context.Background())
+	}
+
 	// Instancia o JobManager e inicia o processamento dos jobs
 	jobManager := services.NewJobManager(dbConnection, rabbitMQ, jobReturnChannel, messageChannel)
-	jobManager.Start(ch)
+	jobManager.Start()
 }
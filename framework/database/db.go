@@ -2,7 +2,6 @@ package database
 
 import (
 	"log"
-	"microsservico-encoder/domain"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
@@ -41,28 +40,66 @@ func NewDbTest() *gorm.DB {
 	return connection
 }
 
-func (d *Database) Connect() (*gorm.DB, error) {
+/*
+Open abre a conexão com o banco sem verificar o schema.
+É utilizada pelo comando migrate, que precisa da conexão antes das migrações.
+*/
+func (d *Database) Open() (*gorm.DB, error) {
 
 	var err error
 
-	if d.Env != "test" {
-		d.Db, err = gorm.Open(d.DbType, d.Dsn)
-	} else {
-		d.Db, err = gorm.Open(d.DbTypeTest, d.DsnTest)
+	dbType, dsn := d.DbType, d.Dsn
+	if d.Env == "test" {
+		dbType, dsn = d.DbTypeTest, d.DsnTest
 	}
 
+	d.Db, err = gorm.Open(dbType, dsn)
+
 	if err != nil {
 		return nil, err
 	}
 
+	// Cada conexão com o SQLite em memória abre um banco diferente, então as
+	// migrações e os testes concorrentes devem compartilhar uma única conexão.
+	if dbType == "sqlite3" && dsn == ":memory:" {
+		d.Db.DB().SetMaxOpenConns(1)
+	}
+
 	if d.Debug {
 		d.Db.LogMode(true)
 	}
 
+	return d.Db, nil
+}
+
+/*
+Connect abre a conexão com o banco e garante que o schema está atualizado.
+Com AutoMigrateDb, as migrações pendentes são aplicadas; caso contrário, a conexão
+é recusada com ErrSchemaBehind quando houver migrações pendentes.
+*/
+func (d *Database) Connect() (*gorm.DB, error) {
+
+	db, err := d.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	if d.AutoMigrateDb {
-		d.Db.AutoMigrate(&domain.Video{}, &domain.Job{})
-		d.Db.Model(domain.Job{}).AddForeignKey("video_id", "videos (id)", "CASCADE", "CASCADE")
+		_, err = migrator.Up()
+	} else {
+		err = migrator.EnsureUpToDate()
 	}
 
-	return d.Db, nil
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
@@ -0,0 +1,515 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrationFiles contém as migrações SQL de cada dialeto, em migrations/<dialeto>/.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationFileName segue o formato <versão>_<nome>.<up|down>.sql (ex.: 0001_initial_schema.up.sql).
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// postgresLockID identifica o advisory lock utilizado pelas migrações no Postgres.
+const postgresLockID = 72616369
+
+/*
+initialSchemaColumns são as colunas criadas pela migração inicial. Bancos que já possuíam
+as tabelas (criadas pelo AutoMigrate de versões anteriores) precisam de todas elas.
+*/
+var initialSchemaColumns = map[string][]string{
+	"videos": {"id", "resource_id", "file_path", "created_at", "input_loudness", "input_true_peak",
+		"input_loudness_range", "input_loudness_threshold"},
+	"jobs": {"id", "output_bucket", "output_bucket_path", "profile", "source_container", "conversion",
+		"inputs", "clip_start", "clip_end", "watermark", "loudnorm", "encryption_scheme", "key_id", "status",
+		"video_id", "error", "error_code", "correlation_id", "callback_url", "created_at", "updated_at"},
+	"webhook_deliveries": {"id", "job_id", "event_id", "event_type", "url", "attempt", "status_code",
+		"success", "error", "created_at"},
+}
+
+var (
+	// ErrSchemaBehind indica que o banco não possui todas as migrações desta versão da aplicação.
+	ErrSchemaBehind = errors.New("database schema is behind")
+	// ErrMigrationLocked indica que outra execução de migrações está em andamento.
+	ErrMigrationLocked = errors.New("migrations are locked by another process")
+	// ErrPartialSchema indica tabelas preexistentes sem todas as colunas da migração inicial.
+	ErrPartialSchema = errors.New("existing tables do not match the initial schema")
+)
+
+// Migration representa uma migração versionada, com os scripts de aplicação e reversão.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus descreve a versão atual do banco e as migrações pendentes.
+type MigrationStatus struct {
+	Current int
+	Latest  int
+	Pending []Migration
+}
+
+/*
+Migrator aplica e reverte as migrações do dialeto do banco (postgres ou sqlite3),
+registrando as versões aplicadas na tabela schema_migrations.
+Execuções concorrentes são serializadas por um lock: advisory lock no Postgres e
+a tabela schema_migrations_lock no SQLite. O lock do SQLite não é liberado se o
+processo cair: ele expira após LockTimeout e também pode ser removido com Unlock.
+*/
+type Migrator struct {
+	Db          *sql.DB
+	Dialect     string
+	Migrations  []Migration
+	LockTimeout time.Duration // Idade a partir da qual o lock do SQLite é considerado abandonado (0 nunca expira)
+}
+
+/*
+NewMigrator cria um Migrator para a conexão informada, carregando as migrações
+embutidas do dialeto da conexão. A expiração do lock é lida de MIGRATION_LOCK_TIMEOUT
+(padrão: 15m) e deve ser maior que a duração da migração mais longa.
+*/
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	dialect := db.Dialect().GetName()
+
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	lockTimeout, err := time.ParseDuration(os.Getenv("MIGRATION_LOCK_TIMEOUT"))
+	if err != nil || lockTimeout < 0 {
+		lockTimeout = 15 * time.Minute
+	}
+
+	return &Migrator{Db: db.DB(), Dialect: dialect, Migrations: migrations, LockTimeout: lockTimeout}, nil
+}
+
+/*
+loadMigrations lê as migrações de um dialeto, ordenadas pela versão.
+Toda migração deve ter os scripts up e down.
+*/
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %v", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %v", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names: %v and %v", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%v must have up and down scripts", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+/*
+Status retorna a versão atual do banco, a última versão conhecida e as migrações pendentes.
+*/
+func (m *Migrator) Status() (*MigrationStatus, error) {
+	ctx := context.Background()
+
+	conn, err := m.Db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return m.status(ctx, conn)
+}
+
+/*
+EnsureUpToDate retorna ErrSchemaBehind se houver migrações pendentes.
+*/
+func (m *Migrator) EnsureUpToDate() error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("%w: at version %d, latest is %d; run the migrate command", ErrSchemaBehind, status.Current, status.Latest)
+	}
+
+	return nil
+}
+
+/*
+Up aplica todas as migrações pendentes, em ordem, cada uma em uma transação.
+Retorna as migrações aplicadas.
+*/
+func (m *Migrator) Up() ([]Migration, error) {
+	applied := []Migration{}
+
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		status, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range status.Pending {
+			err = m.apply(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+				if migration.Version == 1 {
+					err := checkInitialSchema(ctx, tx)
+					if err != nil {
+						return err
+					}
+				}
+
+				_, err := tx.ExecContext(ctx, m.bind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+					migration.Version, migration.Name, time.Now().UTC())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error applying migration %d_%v: %w", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("migration %d_%v applied", migration.Version, migration.Name)
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+/*
+Down reverte as últimas steps migrações aplicadas, da mais recente para a mais antiga.
+Retorna as migrações revertidas.
+*/
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	reverted := []Migration{}
+
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.Migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+
+			err = m.apply(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, m.bind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error reverting migration %d_%v: %v", migration.Version, migration.Name, err)
+			}
+
+			log.Printf("migration %d_%v reverted", migration.Version, migration.Name)
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// apply executa o script e o registro da versão na mesma transação.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, script)
+	if err == nil {
+		err = record(tx)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+/*
+checkInitialSchema verifica se as tabelas possuem todas as colunas da migração inicial.
+O CREATE TABLE IF NOT EXISTS não altera tabelas existentes: no SQLite, que não possui
+ADD COLUMN IF NOT EXISTS, um banco criado por uma versão anterior do AutoMigrate falha
+aqui, com as colunas ausentes, em vez de falhar nas migrações seguintes ou em produção.
+*/
+func checkInitialSchema(ctx context.Context, tx *sql.Tx) error {
+	tables := make([]string, 0, len(initialSchemaColumns))
+	for table := range initialSchemaColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	missing := []string{}
+	for _, table := range tables {
+		rows, err := tx.QueryContext(ctx, "SELECT * FROM "+table+" WHERE 1 = 0")
+		if err != nil {
+			return err
+		}
+
+		columns, err := rows.Columns()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		existing := map[string]bool{}
+		for _, column := range columns {
+			existing[column] = true
+		}
+
+		for _, column := range initialSchemaColumns[table] {
+			if !existing[column] {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing columns %v; add them before migrating", ErrPartialSchema, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) (*MigrationStatus, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{}
+	for _, migration := range m.Migrations {
+		status.Latest = migration.Version
+		if applied[migration.Version] {
+			status.Current = migration.Version
+		} else {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+
+	return status, nil
+}
+
+// applied cria a tabela schema_migrations, se necessário, e retorna as versões aplicadas.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer NOT NULL PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamp NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+/*
+withLock executa fn em uma conexão dedicada enquanto mantém o lock das migrações.
+*/
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := m.Db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.Dialect == "postgres" {
+		// O advisory lock pertence à sessão e é liberado se o processo cair.
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", postgresLockID)
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", postgresLockID)
+
+		return fn(ctx, conn)
+	}
+
+	err = createLockTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	err = m.expireLock(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", time.Now().UTC())
+	if err != nil {
+		return ErrMigrationLocked
+	}
+	defer conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1")
+
+	return fn(ctx, conn)
+}
+
+/*
+Unlock remove o lock das migrações do SQLite deixado por uma execução interrompida.
+Deve ser usado apenas quando não houver outra execução em andamento. No Postgres, o
+advisory lock é liberado com a sessão e não há o que remover.
+*/
+func (m *Migrator) Unlock() error {
+	if m.Dialect == "postgres" {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	conn, err := m.Db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = createLockTable(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1")
+	return err
+}
+
+func createLockTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id integer NOT NULL PRIMARY KEY CHECK (id = 1),
+		locked_at timestamp NOT NULL
+	)`)
+	return err
+}
+
+/*
+expireLock remove o lock do SQLite obtido há mais de LockTimeout, deixado por um processo
+que caiu durante as migrações. A remoção compara o valor lido, para não liberar um lock
+obtido por outro processo nesse intervalo.
+*/
+func (m *Migrator) expireLock(ctx context.Context, conn *sql.Conn) error {
+	if m.LockTimeout <= 0 {
+		return nil
+	}
+
+	var lockedAt time.Time
+	var raw string
+	err := conn.QueryRowContext(ctx, "SELECT locked_at, CAST(locked_at AS TEXT) FROM schema_migrations_lock WHERE id = 1").Scan(&lockedAt, &raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if time.Since(lockedAt) < m.LockTimeout {
+		return nil
+	}
+
+	result, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1 AND CAST(locked_at AS TEXT) = ?", raw)
+	if err != nil {
+		return err
+	}
+
+	if released, _ := result.RowsAffected(); released > 0 {
+		log.Printf("migration lock acquired at %v expired after %v and was released", lockedAt.Format(time.RFC3339), m.LockTimeout)
+	}
+
+	return nil
+}
+
+// bind converte os placeholders "?" para o formato do dialeto ($1, $2... no Postgres).
+func (m *Migrator) bind(query string) string {
+	if m.Dialect != "postgres" {
+		return query
+	}
+
+	result := []byte{}
+	position := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			position++
+			result = append(result, []byte("$"+strconv.Itoa(position))...)
+			continue
+		}
+		result = append(result, query[i])
+	}
+
+	return string(result)
+}
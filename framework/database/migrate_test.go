@@ -0,0 +1,151 @@
+package database_test
+
+import (
+	"errors"
+	"microsservico-encoder/framework/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDatabase(autoMigrate bool) *database.Database {
+	db := database.NewDb()
+	db.Env = "test"
+	db.DbTypeTest = "sqlite3"
+	db.DsnTest = ":memory:"
+	db.AutoMigrateDb = autoMigrate
+	return db
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	connection, err := newTestDatabase(false).Open()
+	require.Nil(t, err)
+	defer connection.Close()
+
+	migrator, err := database.NewMigrator(connection)
+	require.Nil(t, err)
+	require.NotEmpty(t, migrator.Migrations)
+	latest := migrator.Migrations[len(migrator.Migrations)-1].Version
+
+	status, err := migrator.Status()
+	require.Nil(t, err)
+	require.Equal(t, 0, status.Current)
+	require.Equal(t, latest, status.Latest)
+	require.Len(t, status.Pending, len(migrator.Migrations))
+
+	applied, err := migrator.Up()
+	require.Nil(t, err)
+	require.Len(t, applied, len(migrator.Migrations))
+	require.True(t, connection.HasTable("jobs"))
+	require.Nil(t, migrator.EnsureUpToDate())
+
+	applied, err = migrator.Up()
+	require.Nil(t, err)
+	require.Empty(t, applied)
+
+	reverted, err := migrator.Down(1)
+	require.Nil(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, latest, reverted[0].Version)
+
+	err = migrator.EnsureUpToDate()
+	require.True(t, errors.Is(err, database.ErrSchemaBehind))
+
+	_, err = migrator.Down(len(migrator.Migrations))
+	require.Nil(t, err)
+	require.False(t, connection.HasTable("jobs"))
+
+	applied, err = migrator.Up()
+	require.Nil(t, err)
+	require.Len(t, applied, len(migrator.Migrations))
+}
+
+func TestMigratorLock(t *testing.T) {
+	connection, err := newTestDatabase(false).Open()
+	require.Nil(t, err)
+	defer connection.Close()
+
+	migrator, err := database.NewMigrator(connection)
+	require.Nil(t, err)
+
+	_, err = migrator.Up()
+	require.Nil(t, err)
+
+	// Simula outra execução em andamento.
+	require.Nil(t, connection.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, CURRENT_TIMESTAMP)").Error)
+
+	_, err = migrator.Down(1)
+	require.True(t, errors.Is(err, database.ErrMigrationLocked))
+
+	require.Nil(t, migrator.Unlock())
+	_, err = migrator.Down(1)
+	require.Nil(t, err)
+}
+
+// TestMigratorExpiresStaleLock simula um processo que caiu com o lock e verifica que ele expira após LockTimeout.
+func TestMigratorExpiresStaleLock(t *testing.T) {
+	connection, err := newTestDatabase(false).Open()
+	require.Nil(t, err)
+	defer connection.Close()
+
+	migrator, err := database.NewMigrator(connection)
+	require.Nil(t, err)
+	require.Equal(t, 15*time.Minute, migrator.LockTimeout)
+
+	_, err = migrator.Up()
+	require.Nil(t, err)
+
+	require.Nil(t, connection.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", time.Now().UTC().Add(-time.Hour)).Error)
+
+	migrator.LockTimeout = 0
+	_, err = migrator.Down(1)
+	require.True(t, errors.Is(err, database.ErrMigrationLocked))
+
+	migrator.LockTimeout = 15 * time.Minute
+	reverted, err := migrator.Down(1)
+	require.Nil(t, err)
+	require.Len(t, reverted, 1)
+
+	var count int
+	require.Nil(t, connection.Table("schema_migrations_lock").Count(&count).Error)
+	require.Equal(t, 0, count)
+}
+
+/*
+TestMigratorRefusesPartialSchema simula um banco criado pelo AutoMigrate da versão
+original, sem as colunas adicionadas depois, e verifica que a migração inicial falha
+indicando as colunas ausentes, sem registrar a versão.
+*/
+func TestMigratorRefusesPartialSchema(t *testing.T) {
+	connection, err := newTestDatabase(false).Open()
+	require.Nil(t, err)
+	defer connection.Close()
+
+	require.Nil(t, connection.Exec(`CREATE TABLE videos (id uuid NOT NULL PRIMARY KEY, resource_id varchar(255), file_path varchar(255), created_at datetime)`).Error)
+	require.Nil(t, connection.Exec(`CREATE TABLE jobs (id uuid NOT NULL PRIMARY KEY, output_bucket_path varchar(255), status varchar(255),
+		video_id uuid NOT NULL, error varchar(255), created_at datetime, updated_at datetime)`).Error)
+
+	migrator, err := database.NewMigrator(connection)
+	require.Nil(t, err)
+
+	_, err = migrator.Up()
+	require.True(t, errors.Is(err, database.ErrPartialSchema))
+	require.Contains(t, err.Error(), "jobs.correlation_id")
+	require.Contains(t, err.Error(), "videos.input_loudness")
+	require.NotContains(t, err.Error(), "jobs.status")
+
+	status, err := migrator.Status()
+	require.Nil(t, err)
+	require.Equal(t, 0, status.Current)
+}
+
+func TestConnectRefusesSchemaBehind(t *testing.T) {
+	_, err := newTestDatabase(false).Connect()
+	require.True(t, errors.Is(err, database.ErrSchemaBehind))
+
+	connection, err := newTestDatabase(true).Connect()
+	require.Nil(t, err)
+	defer connection.Close()
+	require.True(t, connection.HasTable("webhook_deliveries"))
+}
@@ -0,0 +1,80 @@
+-- Esquema criado anteriormente pelo AutoMigrate do GORM.
+-- IF NOT EXISTS permite adotar as migrações em bancos que já possuem as tabelas.
+CREATE TABLE IF NOT EXISTS videos (
+    id uuid NOT NULL,
+    resource_id varchar(255),
+    file_path varchar(255),
+    created_at timestamp with time zone,
+    input_loudness numeric,
+    input_true_peak numeric,
+    input_loudness_range numeric,
+    input_loudness_threshold numeric,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid NOT NULL,
+    output_bucket varchar(255),
+    output_bucket_path varchar(255),
+    profile varchar(255),
+    source_container varchar(255),
+    conversion varchar(255),
+    inputs text,
+    clip_start numeric,
+    clip_end numeric,
+    watermark text,
+    loudnorm text,
+    encryption_scheme varchar(255),
+    key_id varchar(255),
+    status varchar(255),
+    video_id uuid NOT NULL,
+    error varchar(255),
+    error_code varchar(255),
+    correlation_id varchar(255),
+    callback_url varchar(255),
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    PRIMARY KEY (id),
+    CONSTRAINT jobs_video_id_videos_id_foreign FOREIGN KEY (video_id)
+        REFERENCES videos (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id uuid NOT NULL,
+    job_id uuid,
+    event_id varchar(255),
+    event_type varchar(255),
+    url varchar(2048),
+    attempt integer,
+    status_code integer,
+    success boolean,
+    error text,
+    created_at timestamp with time zone,
+    PRIMARY KEY (id),
+    CONSTRAINT webhook_deliveries_job_id_jobs_id_foreign FOREIGN KEY (job_id)
+        REFERENCES jobs (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+-- Bancos criados por versões anteriores do AutoMigrate não possuem as colunas mais novas,
+-- e o CREATE TABLE IF NOT EXISTS acima não altera tabelas existentes.
+ALTER TABLE videos
+    ADD COLUMN IF NOT EXISTS input_loudness numeric,
+    ADD COLUMN IF NOT EXISTS input_true_peak numeric,
+    ADD COLUMN IF NOT EXISTS input_loudness_range numeric,
+    ADD COLUMN IF NOT EXISTS input_loudness_threshold numeric;
+
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS output_bucket varchar(255),
+    ADD COLUMN IF NOT EXISTS profile varchar(255),
+    ADD COLUMN IF NOT EXISTS source_container varchar(255),
+    ADD COLUMN IF NOT EXISTS conversion varchar(255),
+    ADD COLUMN IF NOT EXISTS inputs text,
+    ADD COLUMN IF NOT EXISTS clip_start numeric,
+    ADD COLUMN IF NOT EXISTS clip_end numeric,
+    ADD COLUMN IF NOT EXISTS watermark text,
+    ADD COLUMN IF NOT EXISTS loudnorm text,
+    ADD COLUMN IF NOT EXISTS encryption_scheme varchar(255),
+    ADD COLUMN IF NOT EXISTS key_id varchar(255),
+    ADD COLUMN IF NOT EXISTS error_code varchar(255),
+    ADD COLUMN IF NOT EXISTS correlation_id varchar(255),
+    ADD COLUMN IF NOT EXISTS callback_url varchar(255);
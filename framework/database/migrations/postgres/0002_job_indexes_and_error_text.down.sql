@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_job_id;
+DROP INDEX IF EXISTS idx_videos_resource_id;
+DROP INDEX IF EXISTS idx_jobs_status;
+DROP INDEX IF EXISTS idx_jobs_video_id;
+
+ALTER TABLE jobs ALTER COLUMN error TYPE varchar(255) USING left(error, 255);
@@ -0,0 +1,7 @@
+-- Mensagens de erro (ex.: falhas de upload agregadas) podem passar de 255 caracteres.
+ALTER TABLE jobs ALTER COLUMN error TYPE text;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_video_id ON jobs (video_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+CREATE INDEX IF NOT EXISTS idx_videos_resource_id ON videos (resource_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_job_id ON webhook_deliveries (job_id);
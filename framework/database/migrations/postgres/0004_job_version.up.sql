@@ -0,0 +1,2 @@
+-- Versão do job para o controle de concorrência otimista das atualizações.
+ALTER TABLE jobs ADD COLUMN version integer NOT NULL DEFAULT 1;
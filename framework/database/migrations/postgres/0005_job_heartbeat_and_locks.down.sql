@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS locks;
+
+ALTER TABLE jobs DROP COLUMN requeues;
+ALTER TABLE jobs DROP COLUMN message;
+ALTER TABLE jobs DROP COLUMN heartbeat_at;
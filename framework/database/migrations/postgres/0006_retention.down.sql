@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS purge_audits;
+
+ALTER TABLE jobs DROP COLUMN outputs_purged_at;
+ALTER TABLE videos DROP COLUMN tags;
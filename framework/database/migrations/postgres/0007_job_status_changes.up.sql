@@ -0,0 +1,9 @@
+-- Histórico das mudanças de status dos jobs, uma linha por versão em que o status mudou.
+CREATE TABLE IF NOT EXISTS job_status_changes (
+    job_id uuid NOT NULL,
+    version integer NOT NULL,
+    status varchar(255) NOT NULL,
+    error_code varchar(255),
+    created_at timestamp with time zone,
+    PRIMARY KEY (job_id, version)
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_jobs_tenant_id_created_at;
+DROP INDEX IF EXISTS idx_jobs_tenant_id_status;
+DROP INDEX IF EXISTS idx_videos_tenant_id;
+
+ALTER TABLE jobs DROP COLUMN duration_seconds;
+ALTER TABLE jobs DROP COLUMN tenant_id;
+ALTER TABLE videos DROP COLUMN tenant_id;
@@ -0,0 +1,3 @@
+-- Índice das buscas de jobs pelo caminho de saída, usadas pelo purger para não
+-- remover os arquivos de um prefixo compartilhado por outros jobs.
+CREATE INDEX IF NOT EXISTS idx_jobs_output ON jobs (output_bucket, output_bucket_path);
@@ -0,0 +1,54 @@
+-- Esquema criado anteriormente pelo AutoMigrate do GORM.
+-- IF NOT EXISTS permite adotar as migrações em bancos que já possuem as tabelas.
+-- O SQLite não possui ADD COLUMN IF NOT EXISTS: tabelas existentes sem todas as colunas
+-- abaixo são recusadas pelo Migrator (ErrPartialSchema).
+CREATE TABLE IF NOT EXISTS videos (
+    id uuid NOT NULL,
+    resource_id varchar(255),
+    file_path varchar(255),
+    created_at datetime,
+    input_loudness real,
+    input_true_peak real,
+    input_loudness_range real,
+    input_loudness_threshold real,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid NOT NULL,
+    output_bucket varchar(255),
+    output_bucket_path varchar(255),
+    profile varchar(255),
+    source_container varchar(255),
+    conversion varchar(255),
+    inputs text,
+    clip_start real,
+    clip_end real,
+    watermark text,
+    loudnorm text,
+    encryption_scheme varchar(255),
+    key_id varchar(255),
+    status varchar(255),
+    video_id uuid NOT NULL REFERENCES videos (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    error varchar(255),
+    error_code varchar(255),
+    correlation_id varchar(255),
+    callback_url varchar(255),
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id uuid NOT NULL,
+    job_id uuid REFERENCES jobs (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    event_id varchar(255),
+    event_type varchar(255),
+    url varchar(2048),
+    attempt integer,
+    status_code integer,
+    success bool,
+    error text,
+    created_at datetime,
+    PRIMARY KEY (id)
+);
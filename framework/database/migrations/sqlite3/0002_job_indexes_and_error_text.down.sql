@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_job_id;
+DROP INDEX IF EXISTS idx_videos_resource_id;
+DROP INDEX IF EXISTS idx_jobs_status;
+DROP INDEX IF EXISTS idx_jobs_video_id;
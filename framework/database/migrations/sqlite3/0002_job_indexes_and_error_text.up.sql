@@ -0,0 +1,5 @@
+-- O SQLite não limita o tamanho de varchar, então jobs.error não precisa ser alterada.
+CREATE INDEX IF NOT EXISTS idx_jobs_video_id ON jobs (video_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
+CREATE INDEX IF NOT EXISTS idx_videos_resource_id ON videos (resource_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_job_id ON webhook_deliveries (job_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_videos_created_at;
+DROP INDEX IF EXISTS idx_jobs_created_at;
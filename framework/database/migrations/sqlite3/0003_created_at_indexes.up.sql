@@ -0,0 +1,3 @@
+-- Índices para as listagens paginadas, ordenadas e filtradas pela data de criação.
+CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs (created_at);
+CREATE INDEX IF NOT EXISTS idx_videos_created_at ON videos (created_at);
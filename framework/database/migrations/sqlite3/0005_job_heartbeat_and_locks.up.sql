@@ -0,0 +1,12 @@
+-- Heartbeat dos workers, mensagem original (para reenfileirar) e reenfileiramentos feitos pelo reaper.
+ALTER TABLE jobs ADD COLUMN heartbeat_at datetime;
+ALTER TABLE jobs ADD COLUMN message text;
+ALTER TABLE jobs ADD COLUMN requeues integer NOT NULL DEFAULT 0;
+
+-- Locks com prazo de expiração, usados para que apenas uma instância execute tarefas periódicas.
+CREATE TABLE IF NOT EXISTS locks (
+    name varchar(255) NOT NULL,
+    owner varchar(255) NOT NULL,
+    expires_at datetime NOT NULL,
+    PRIMARY KEY (name)
+);
@@ -0,0 +1,19 @@
+-- Tags dos vídeos, no formato ",tag1,tag2,", usadas para selecionar as regras de retenção.
+ALTER TABLE videos ADD COLUMN tags text;
+
+-- Data em que os arquivos de saída do job foram removidos pelo purger.
+ALTER TABLE jobs ADD COLUMN outputs_purged_at datetime;
+
+-- Registro de auditoria das remoções feitas (ou simuladas) pelas regras de retenção.
+CREATE TABLE IF NOT EXISTS purge_audits (
+    id uuid NOT NULL,
+    rule varchar(255) NOT NULL,
+    action varchar(255) NOT NULL,
+    target_id varchar(255) NOT NULL,
+    detail text,
+    dry_run boolean NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_purge_audits_created_at ON purge_audits (created_at);
@@ -0,0 +1,44 @@
+-- O SQLite embutido não suporta DROP COLUMN, então a tabela é recriada sem a coluna.
+CREATE TABLE jobs_without_priority (
+    id uuid NOT NULL,
+    output_bucket varchar(255),
+    output_bucket_path varchar(255),
+    profile varchar(255),
+    source_container varchar(255),
+    conversion varchar(255),
+    inputs text,
+    clip_start real,
+    clip_end real,
+    watermark text,
+    loudnorm text,
+    encryption_scheme varchar(255),
+    key_id varchar(255),
+    status varchar(255),
+    video_id uuid NOT NULL REFERENCES videos (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    error varchar(255),
+    error_code varchar(255),
+    correlation_id varchar(255),
+    callback_url varchar(255),
+    created_at datetime,
+    updated_at datetime,
+    version integer NOT NULL DEFAULT 1,
+    heartbeat_at datetime,
+    message text,
+    requeues integer NOT NULL DEFAULT 0,
+    outputs_purged_at datetime,
+    PRIMARY KEY (id)
+);
+
+INSERT INTO jobs_without_priority
+SELECT id, output_bucket, output_bucket_path, profile, source_container, conversion, inputs,
+       clip_start, clip_end, watermark, loudnorm, encryption_scheme, key_id, status, video_id,
+       error, error_code, correlation_id, callback_url, created_at, updated_at, version,
+       heartbeat_at, message, requeues, outputs_purged_at
+FROM jobs;
+
+DROP TABLE jobs;
+ALTER TABLE jobs_without_priority RENAME TO jobs;
+
+CREATE INDEX idx_jobs_video_id ON jobs (video_id);
+CREATE INDEX idx_jobs_status ON jobs (status);
+CREATE INDEX idx_jobs_created_at ON jobs (created_at);
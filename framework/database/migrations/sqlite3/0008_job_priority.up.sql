@@ -0,0 +1,2 @@
+-- Prioridade do job na fila de consumo (0 a 10, maior é mais urgente).
+ALTER TABLE jobs ADD COLUMN priority integer NOT NULL DEFAULT 0;
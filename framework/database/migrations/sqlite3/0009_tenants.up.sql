@@ -0,0 +1,10 @@
+-- Tenant dono de cada vídeo e job; os registros existentes pertencem ao tenant padrão.
+ALTER TABLE videos ADD COLUMN tenant_id varchar(255) NOT NULL DEFAULT 'default';
+ALTER TABLE jobs ADD COLUMN tenant_id varchar(255) NOT NULL DEFAULT 'default';
+
+-- Duração da saída do job, em segundos, usada nas cotas e nos relatórios de uso.
+ALTER TABLE jobs ADD COLUMN duration_seconds real NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_videos_tenant_id ON videos (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_id_status ON jobs (tenant_id, status);
+CREATE INDEX IF NOT EXISTS idx_jobs_tenant_id_created_at ON jobs (tenant_id, created_at);
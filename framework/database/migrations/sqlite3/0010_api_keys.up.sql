@@ -0,0 +1,16 @@
+-- Chaves de acesso à API HTTP. Apenas o hash SHA-256 da chave é armazenado;
+-- o prefixo identifica a chave nas buscas e nos logs.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid NOT NULL,
+    name varchar(255) NOT NULL,
+    tenant_id varchar(255),
+    prefix varchar(255) NOT NULL,
+    key_hash varchar(255) NOT NULL,
+    scopes varchar(255) NOT NULL,
+    created_at datetime,
+    revoked_at datetime,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys (tenant_id);
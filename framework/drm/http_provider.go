@@ -0,0 +1,89 @@
+package drm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+/*
+HTTPKeyProvider obtém as chaves de um servidor de chaves. Para cada conteúdo,
+envia um POST para URL com {"content_id": "..."} e espera a resposta
+{"kid": "...", "key": "..."}. Se Token for informado, ele é enviado no cabeçalho
+Authorization (Bearer).
+*/
+type HTTPKeyProvider struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+/*
+NewHTTPKeyProviderFromEnv cria um HTTPKeyProvider com DRM_KEY_SERVER_URL,
+DRM_KEY_SERVER_TOKEN e o timeout DRM_KEY_SERVER_TIMEOUT (padrão: 10s).
+*/
+func NewHTTPKeyProviderFromEnv() (*HTTPKeyProvider, error) {
+	url := os.Getenv("DRM_KEY_SERVER_URL")
+	if url == "" {
+		return nil, fmt.Errorf("DRM_KEY_SERVER_URL is required by the http key provider")
+	}
+
+	timeout, err := time.ParseDuration(os.Getenv("DRM_KEY_SERVER_TIMEOUT"))
+	if err != nil {
+		timeout = 10 * time.Second
+	}
+
+	return &HTTPKeyProvider{
+		URL:    url,
+		Token:  os.Getenv("DRM_KEY_SERVER_TOKEN"),
+		Client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (h *HTTPKeyProvider) ContentKey(ctx context.Context, contentID string) (*ContentKey, error) {
+	body, err := json.Marshal(map[string]string{"content_id": contentID})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if h.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting content key: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error requesting content key: unexpected status code %v", response.StatusCode)
+	}
+
+	var key ContentKey
+	err = json.NewDecoder(response.Body).Decode(&key)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing content key response: %v", err)
+	}
+
+	err = key.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,91 @@
+package drm
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+ContentKey é a chave de conteúdo utilizada na criptografia CENC:
+KID identifica a chave no manifesto e no servidor de licenças, e Key é a chave AES-128.
+Ambos são representados em hexadecimal (32 caracteres).
+A chave nunca deve ser registrada em log nem gravada no job; String omite o seu valor.
+*/
+type ContentKey struct {
+	KID string `json:"kid"`
+	Key string `json:"key"`
+}
+
+// String descreve a chave sem o seu valor, para que ela não vaze em logs.
+func (k ContentKey) String() string {
+	return "kid=" + k.KID + " key=[redacted]"
+}
+
+// GoString evita que o formato %#v exponha a chave.
+func (k ContentKey) GoString() string {
+	return "drm.ContentKey{" + k.String() + "}"
+}
+
+/*
+Validate normaliza o KID e a chave (minúsculas, sem hífens) e verifica se ambos
+têm 16 bytes em hexadecimal.
+*/
+func (k *ContentKey) Validate() error {
+	k.KID = normalizeHex(k.KID)
+	k.Key = normalizeHex(k.Key)
+
+	if !isHex128(k.KID) {
+		return fmt.Errorf("invalid key id: must be 16 bytes in hex")
+	}
+
+	if !isHex128(k.Key) {
+		// A mensagem não inclui o valor recebido para não expor a chave.
+		return fmt.Errorf("invalid content key for kid %v: must be 16 bytes in hex", k.KID)
+	}
+
+	return nil
+}
+
+/*
+KeyProvider obtém a chave de conteúdo utilizada para criptografar um conteúdo.
+O contentID identifica o conteúdo (o resource_id do vídeo), permitindo que
+o mesmo conteúdo use sempre a mesma chave.
+*/
+type KeyProvider interface {
+	ContentKey(ctx context.Context, contentID string) (*ContentKey, error)
+}
+
+/*
+NewKeyProviderFromEnv cria o KeyProvider definido em DRM_KEY_PROVIDER:
+- static: chave única definida em DRM_STATIC_KEY, no formato <kid>:<key>;
+- file: chaves por conteúdo lidas do arquivo JSON DRM_KEY_FILE;
+- http: chaves obtidas do servidor DRM_KEY_SERVER_URL.
+Retorna nil, sem erro, quando DRM_KEY_PROVIDER não é informado.
+*/
+func NewKeyProviderFromEnv() (KeyProvider, error) {
+	switch provider := os.Getenv("DRM_KEY_PROVIDER"); provider {
+	case "":
+		return nil, nil
+	case "static":
+		return NewStaticKeyProvider(os.Getenv("DRM_STATIC_KEY"))
+	case "file":
+		return NewFileKeyProvider(os.Getenv("DRM_KEY_FILE"))
+	case "http":
+		return NewHTTPKeyProviderFromEnv()
+	default:
+		return nil, fmt.Errorf("invalid DRM_KEY_PROVIDER: %q", provider)
+	}
+}
+
+// normalizeHex remove espaços e hífens (KIDs no formato UUID) e converte para minúsculas.
+func normalizeHex(value string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), "-", ""))
+}
+
+func isHex128(value string) bool {
+	decoded, err := hex.DecodeString(value)
+	return err == nil && len(decoded) == 16
+}
@@ -0,0 +1,108 @@
+package drm_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"microsservico-encoder/framework/drm"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKID = "eb676abbcb345e96bbcf616630f1a3da"
+	testKey = "100b6c20940f779a4589152b57d2dacb"
+)
+
+func TestContentKeyDoesNotExposeKey(t *testing.T) {
+	key := drm.ContentKey{KID: testKID, Key: testKey}
+
+	require.NotContains(t, fmt.Sprintf("%v %+v %#v %s", key, key, key, key), testKey)
+	require.Contains(t, key.String(), testKID)
+}
+
+func TestStaticKeyProvider(t *testing.T) {
+	provider, err := drm.NewStaticKeyProvider("EB676ABB-CB34-5E96-BBCF-616630F1A3DA:" + testKey)
+	require.Nil(t, err)
+
+	key, err := provider.ContentKey(context.Background(), "any")
+	require.Nil(t, err)
+	require.Equal(t, testKID, key.KID)
+	require.Equal(t, testKey, key.Key)
+
+	_, err = drm.NewStaticKeyProvider(testKID + ":short")
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "short")
+
+	_, err = drm.NewStaticKeyProvider(testKID)
+	require.Error(t, err)
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "keys.json")
+	content := `{
+		"movie": {"kid": "` + testKID + `", "key": "` + testKey + `"},
+		"*": {"kid": "00000000000000000000000000000001", "key": "00000000000000000000000000000002"}
+	}`
+	require.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+	provider, err := drm.NewFileKeyProvider(path)
+	require.Nil(t, err)
+
+	key, err := provider.ContentKey(context.Background(), "movie")
+	require.Nil(t, err)
+	require.Equal(t, testKID, key.KID)
+
+	key, err = provider.ContentKey(context.Background(), "other")
+	require.Nil(t, err)
+	require.Equal(t, "00000000000000000000000000000001", key.KID)
+
+	delete(provider.Keys, "*")
+	_, err = provider.ContentKey(context.Background(), "other")
+	require.Error(t, err)
+}
+
+func TestHTTPKeyProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var request map[string]string
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+
+		if request["content_id"] == "invalid" {
+			json.NewEncoder(w).Encode(map[string]string{"kid": testKID, "key": "xyz"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"kid": testKID, "key": testKey})
+	}))
+	defer server.Close()
+
+	provider := &drm.HTTPKeyProvider{URL: server.URL, Token: "secret", Client: server.Client()}
+
+	key, err := provider.ContentKey(context.Background(), "movie")
+	require.Nil(t, err)
+	require.Equal(t, testKID, key.KID)
+	require.Equal(t, testKey, key.Key)
+
+	_, err = provider.ContentKey(context.Background(), "invalid")
+	require.Error(t, err)
+
+	provider.Token = "wrong"
+	_, err = provider.ContentKey(context.Background(), "movie")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "401")
+}
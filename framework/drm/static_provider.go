@@ -0,0 +1,85 @@
+package drm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// StaticKeyProvider utiliza a mesma chave para todos os conteúdos.
+type StaticKeyProvider struct {
+	Key ContentKey
+}
+
+/*
+NewStaticKeyProvider cria um StaticKeyProvider a partir de uma chave no formato <kid>:<key>.
+*/
+func NewStaticKeyProvider(value string) (*StaticKeyProvider, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid static key: expected <kid>:<key>")
+	}
+
+	key := ContentKey{KID: parts[0], Key: parts[1]}
+	err := key.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &StaticKeyProvider{Key: key}, nil
+}
+
+func (s *StaticKeyProvider) ContentKey(ctx context.Context, contentID string) (*ContentKey, error) {
+	key := s.Key
+	return &key, nil
+}
+
+/*
+FileKeyProvider utiliza chaves por conteúdo carregadas de um arquivo JSON
+no formato {"<content_id>": {"kid": "...", "key": "..."}}.
+A entrada "*" é utilizada para os conteúdos sem chave própria.
+*/
+type FileKeyProvider struct {
+	Keys map[string]ContentKey
+}
+
+/*
+NewFileKeyProvider carrega e valida as chaves do arquivo informado.
+*/
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %v", err)
+	}
+
+	keys := map[string]ContentKey{}
+	err = json.Unmarshal(content, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing key file %v: %v", path, err)
+	}
+
+	for contentID, key := range keys {
+		err = key.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("key file %v, content %v: %v", path, contentID, err)
+		}
+		keys[contentID] = key
+	}
+
+	return &FileKeyProvider{Keys: keys}, nil
+}
+
+func (f *FileKeyProvider) ContentKey(ctx context.Context, contentID string) (*ContentKey, error) {
+	key, ok := f.Keys[contentID]
+	if !ok {
+		key, ok = f.Keys["*"]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("no content key for %v", contentID)
+	}
+
+	return &key, nil
+}
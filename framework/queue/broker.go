@@ -0,0 +1,53 @@
+package queue
+
+import "time"
+
+/*
+Message representa uma mensagem recebida de um broker, independente da
+implementação utilizada (RabbitMQ, memória, etc.).
+Permite ler o corpo e os cabeçalhos e confirmar ou rejeitar o processamento.
+*/
+type Message interface {
+	ID() string                      // Identificador da mensagem no broker (usado em logs)
+	Body() []byte                    // Conteúdo da mensagem
+	Headers() map[string]interface{} // Cabeçalhos da mensagem
+	Priority() uint8                 // Prioridade da mensagem no broker (0 quando não informada)
+	Ack() error                      // Confirma o processamento da mensagem
+	Nack(requeue bool) error         // Rejeita a mensagem, devolvendo-a para a fila se requeue for true
+}
+
+// MaxPriority é a maior prioridade aceita pela fila de consumo (argumento x-max-priority, ver NewRabbitMQ).
+const MaxPriority = 10
+
+// Publishing representa uma mensagem a ser publicada em um broker.
+type Publishing struct {
+	ContentType string
+	Body        []byte
+	Headers     map[string]interface{}
+	Priority    uint8         // Prioridade da mensagem, de 0 a MaxPriority
+	Expiration  time.Duration // Tempo de vida na fila (0 não expira); na fila de espera, o atraso até voltar à fila de consumo
+}
+
+/*
+Consumer é implementado por brokers capazes de entregar mensagens.
+As mensagens recebidas são enviadas para o canal `messageChannel`, que é
+fechado quando o broker deixa de entregar mensagens.
+*/
+type Consumer interface {
+	Consume(messageChannel chan Message)
+}
+
+// Publisher é implementado por brokers capazes de publicar mensagens em uma exchange.
+type Publisher interface {
+	Publish(exchange string, routingKey string, publishing Publishing) error
+}
+
+/*
+Getter é implementado por brokers capazes de buscar mensagens de uma fila sob demanda,
+uma de cada vez (ex.: para reprocessar a fila de mensagens mortas).
+Retorna false quando a fila não tem mensagens disponíveis. As mensagens obtidas
+devem ser confirmadas ou rejeitadas como as recebidas por Consume.
+*/
+type Getter interface {
+	Get(queue string) (Message, bool, error)
+}
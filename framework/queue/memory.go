@@ -0,0 +1,224 @@
+package queue
+
+import (
+	"strconv"
+	"sync"
+)
+
+/*
+MemoryBroker é uma implementação em memória de Consumer, Publisher e Getter.
+Ela é utilizada em testes para exercitar o processamento de jobs sem
+depender de um RabbitMQ em execução. Como uma fila com x-max-priority, entrega
+primeiro as mensagens de maior prioridade e, entre as de mesma prioridade, as
+mais antigas.
+*/
+type MemoryBroker struct {
+	mu        sync.Mutex
+	ready     *sync.Cond
+	pending   []*MemoryMessage
+	closed    bool
+	published []PublishedMessage
+	nextID    uint64
+}
+
+// PublishedMessage registra uma mensagem publicada no MemoryBroker.
+type PublishedMessage struct {
+	Exchange   string
+	RoutingKey string
+	Publishing Publishing
+}
+
+/*
+NewMemoryBroker cria um MemoryBroker vazio. O tamanho informado apenas reserva
+espaço para as mensagens enfileiradas; Enqueue nunca bloqueia.
+*/
+func NewMemoryBroker(buffer int) *MemoryBroker {
+	broker := &MemoryBroker{pending: make([]*MemoryMessage, 0, buffer)}
+	broker.ready = sync.NewCond(&broker.mu)
+
+	return broker
+}
+
+/*
+Enqueue adiciona uma mensagem na fila de entrada do broker e a retorna,
+permitindo verificar posteriormente se ela foi confirmada ou rejeitada.
+*/
+func (b *MemoryBroker) Enqueue(body []byte, headers map[string]interface{}) *MemoryMessage {
+	return b.EnqueuePriority(body, headers, 0)
+}
+
+// EnqueuePriority adiciona uma mensagem com a prioridade informada.
+func (b *MemoryBroker) EnqueuePriority(body []byte, headers map[string]interface{}, priority uint8) *MemoryMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	message := &MemoryMessage{
+		id:       strconv.FormatUint(b.nextID, 10),
+		body:     body,
+		headers:  headers,
+		priority: priority,
+	}
+
+	b.pending = append(b.pending, message)
+	b.ready.Signal()
+
+	return message
+}
+
+// Close encerra a fila de entrada. Consumidores recebem o fechamento do canal após as mensagens pendentes.
+func (b *MemoryBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.ready.Broadcast()
+}
+
+// next remove e retorna a mensagem pendente de maior prioridade, ou nil se não houver. Deve ser chamado com o lock.
+func (b *MemoryBroker) next() *MemoryMessage {
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	best := 0
+	for i, message := range b.pending {
+		if message.priority > b.pending[best].priority {
+			best = i
+		}
+	}
+
+	message := b.pending[best]
+	b.pending = append(b.pending[:best], b.pending[best+1:]...)
+
+	return message
+}
+
+/*
+Consume repassa as mensagens enfileiradas para o canal `messageChannel`, por
+prioridade, fechando-o quando o broker for encerrado com Close.
+*/
+func (b *MemoryBroker) Consume(messageChannel chan Message) {
+	go func() {
+		for {
+			b.mu.Lock()
+			for len(b.pending) == 0 && !b.closed {
+				b.ready.Wait()
+			}
+			message := b.next()
+			b.mu.Unlock()
+
+			if message == nil {
+				close(messageChannel)
+				return
+			}
+
+			messageChannel <- message
+		}
+	}()
+}
+
+/*
+Get retorna a próxima mensagem enfileirada, sem aguardar. O MemoryBroker possui uma
+única fila de entrada, então o nome da fila é ignorado.
+*/
+func (b *MemoryBroker) Get(queue string) (Message, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	message := b.next()
+	if message == nil {
+		return nil, false, nil
+	}
+
+	return message, true, nil
+}
+
+// Publish registra a mensagem publicada para inspeção posterior.
+func (b *MemoryBroker) Publish(exchange string, routingKey string, publishing Publishing) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = append(b.published, PublishedMessage{
+		Exchange:   exchange,
+		RoutingKey: routingKey,
+		Publishing: publishing,
+	})
+
+	return nil
+}
+
+// Published retorna uma cópia das mensagens publicadas até o momento.
+func (b *MemoryBroker) Published() []PublishedMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	published := make([]PublishedMessage, len(b.published))
+	copy(published, b.published)
+
+	return published
+}
+
+/*
+MemoryMessage é a implementação em memória de Message.
+Registra se a mensagem foi confirmada (Ack) ou rejeitada (Nack).
+*/
+type MemoryMessage struct {
+	mu       sync.Mutex
+	id       string
+	body     []byte
+	headers  map[string]interface{}
+	priority uint8
+	acked    bool
+	nacked   bool
+	requeued bool
+}
+
+func (m *MemoryMessage) ID() string {
+	return m.id
+}
+
+func (m *MemoryMessage) Body() []byte {
+	return m.body
+}
+
+func (m *MemoryMessage) Headers() map[string]interface{} {
+	return m.headers
+}
+
+func (m *MemoryMessage) Priority() uint8 {
+	return m.priority
+}
+
+func (m *MemoryMessage) Ack() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.acked = true
+	return nil
+}
+
+func (m *MemoryMessage) Nack(requeue bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nacked = true
+	m.requeued = requeue
+	return nil
+}
+
+// Acked indica se a mensagem foi confirmada.
+func (m *MemoryMessage) Acked() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.acked
+}
+
+// Nacked indica se a mensagem foi rejeitada e se foi devolvida para a fila.
+func (m *MemoryMessage) Nacked() (nacked bool, requeued bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.nacked, m.requeued
+}
@@ -3,6 +3,8 @@ package queue
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/streadway/amqp"
 )
@@ -15,6 +17,7 @@ type RabbitMQ struct {
 	Port              string
 	Vhost             string
 	ConsumerQueueName string
+	DelayQueueName    string
 	ConsumerName      string
 	AutoAck           bool
 	Args              amqp.Table
@@ -24,13 +27,27 @@ type RabbitMQ struct {
 /*
 NewRabbitMQ cria e retorna uma instância da estrutura RabbitMQ preenchida com os valores
 definidos nas variáveis de ambiente. Também define os argumentos da fila, incluindo
-o Dead Letter Exchange (DLX).
+o Dead Letter Exchange (DLX) e, com RABBITMQ_PRIORITY_QUEUE=true, a prioridade máxima
+das mensagens (x-max-priority = MaxPriority).
+
+Os argumentos de uma fila não podem ser alterados depois de criada, nem por policies:
+declarar uma fila existente com outros argumentos falha com PRECONDITION_FAILED.
+Por isso a prioridade é opcional e exige uma fila nova. Para migrar:
+ 1. suba os workers com RABBITMQ_PRIORITY_QUEUE=true e RABBITMQ_CONSUMER_QUEUE_NAME
+    apontando para uma fila nova (ex.: videos-priority), que é criada com x-max-priority;
+ 2. passe a publicar os jobs na fila nova;
+ 3. mantenha ao menos um worker sem a opção consumindo a fila antiga até esvaziá-la.
 */
 func NewRabbitMQ() *RabbitMQ {
 
 	rabbitMQArgs := amqp.Table{}
 	rabbitMQArgs["x-dead-letter-exchange"] = os.Getenv("RABBITMQ_DLX")
 
+	priorityQueue, _ := strconv.ParseBool(os.Getenv("RABBITMQ_PRIORITY_QUEUE"))
+	if priorityQueue {
+		rabbitMQArgs["x-max-priority"] = int32(MaxPriority)
+	}
+
 	rabbitMQ := RabbitMQ{
 		User:              os.Getenv("RABBITMQ_DEFAULT_USER"),
 		Password:          os.Getenv("RABBITMQ_DEFAULT_PASS"),
@@ -38,6 +55,7 @@ func NewRabbitMQ() *RabbitMQ {
 		Port:              os.Getenv("RABBITMQ_DEFAULT_PORT"),
 		Vhost:             os.Getenv("RABBITMQ_DEFAULT_VHOST"),
 		ConsumerQueueName: os.Getenv("RABBITMQ_CONSUMER_QUEUE_NAME"),
+		DelayQueueName:    DelayQueueName(),
 		ConsumerName:      os.Getenv("RABBITMQ_CONSUMER_NAME"),
 		AutoAck:           false,
 		Args:              rabbitMQArgs,
@@ -46,6 +64,20 @@ func NewRabbitMQ() *RabbitMQ {
 	return &rabbitMQ
 }
 
+/*
+DelayQueueName retorna o nome da fila de espera: RABBITMQ_DELAY_QUEUE_NAME ou, se vazio,
+a fila de consumo seguida de "-delay". A fila não tem consumidores: as mensagens
+publicadas nela com Expiration voltam para a fila de consumo quando expiram.
+*/
+func DelayQueueName() string {
+	name := os.Getenv("RABBITMQ_DELAY_QUEUE_NAME")
+	if name == "" {
+		name = os.Getenv("RABBITMQ_CONSUMER_QUEUE_NAME") + "-delay"
+	}
+
+	return name
+}
+
 /*
 Connect estabelece a conexão com o RabbitMQ utilizando as configurações
 armazenadas na estrutura RabbitMQ e retorna o canal de comunicação aberto.
@@ -63,11 +95,11 @@ func (r *RabbitMQ) Connect() *amqp.Channel {
 }
 
 /*
-Consume declara a fila de consumo e registra um consumidor nela.
+Consume declara a fila de consumo e a fila de espera e registra um consumidor na fila de consumo.
 As mensagens recebidas são enviadas para o canal `messageChannel`.
 O processamento das mensagens ocorre de forma assíncrona em uma goroutine.
 */
-func (r *RabbitMQ) Consume(messageChannel chan amqp.Delivery) {
+func (r *RabbitMQ) Consume(messageChannel chan Message) {
 
 	q, err := r.Channel.QueueDeclare(
 		r.ConsumerQueueName, // name
@@ -79,6 +111,20 @@ func (r *RabbitMQ) Consume(messageChannel chan amqp.Delivery) {
 	)
 	failOnError(err, "failed to declare a queue")
 
+	// As mensagens expiradas na fila de espera são devolvidas à fila de consumo pela exchange padrão.
+	_, err = r.Channel.QueueDeclare(
+		r.DelayQueueName, // name
+		true,             // durable
+		false,            // delete when unused
+		false,            // exclusive
+		false,            // no-wait
+		amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": r.ConsumerQueueName,
+		}, // arguments
+	)
+	failOnError(err, "failed to declare the delay queue")
+
 	incomingMessage, err := r.Channel.Consume(
 		q.Name,         // queue
 		r.ConsumerName, // consumer
@@ -93,7 +139,7 @@ func (r *RabbitMQ) Consume(messageChannel chan amqp.Delivery) {
 	go func() {
 		for message := range incomingMessage {
 			log.Println("Incoming new message")
-			messageChannel <- message
+			messageChannel <- &rabbitMQMessage{delivery: message}
 		}
 		log.Println("RabbitMQ channel closed")
 		close(messageChannel)
@@ -101,10 +147,10 @@ func (r *RabbitMQ) Consume(messageChannel chan amqp.Delivery) {
 }
 
 /*
-Notify publica uma mensagem no RabbitMQ utilizando os parâmetros fornecidos,
-como exchange, routing key e tipo de conteúdo. Retorna erro em caso de falha.
+Publish publica uma mensagem no RabbitMQ na exchange e routing key informadas.
+Retorna erro em caso de falha.
 */
-func (r *RabbitMQ) Notify(message string, contentType string, exchange string, routingKey string) error {
+func (r *RabbitMQ) Publish(exchange string, routingKey string, publishing Publishing) error {
 
 	err := r.Channel.Publish(
 		exchange,   // exchange
@@ -112,8 +158,11 @@ func (r *RabbitMQ) Notify(message string, contentType string, exchange string, r
 		false,      // mandatory
 		false,      // immediate
 		amqp.Publishing{
-			ContentType: contentType,
-			Body:        []byte(message),
+			ContentType: publishing.ContentType,
+			Headers:     amqp.Table(publishing.Headers),
+			Body:        publishing.Body,
+			Priority:    publishing.Priority,
+			Expiration:  expiration(publishing.Expiration),
 		})
 
 	if err != nil {
@@ -123,6 +172,73 @@ func (r *RabbitMQ) Notify(message string, contentType string, exchange string, r
 	return nil
 }
 
+// expiration converte o tempo de vida da mensagem para o formato do AMQP (milissegundos), ou vazio se não expira.
+func expiration(ttl time.Duration) string {
+	if ttl <= 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(ttl.Milliseconds(), 10)
+}
+
+/*
+Get busca a próxima mensagem disponível na fila informada, sem confirmá-la.
+Retorna false quando a fila está vazia.
+*/
+func (r *RabbitMQ) Get(queue string) (Message, bool, error) {
+
+	delivery, ok, err := r.Channel.Get(queue, false)
+
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	return &rabbitMQMessage{delivery: delivery}, true, nil
+}
+
+/*
+Notify publica uma mensagem no RabbitMQ utilizando os parâmetros fornecidos,
+como exchange, routing key e tipo de conteúdo. Retorna erro em caso de falha.
+*/
+func (r *RabbitMQ) Notify(message string, contentType string, exchange string, routingKey string) error {
+	return r.Publish(exchange, routingKey, Publishing{
+		ContentType: contentType,
+		Body:        []byte(message),
+	})
+}
+
+/*
+rabbitMQMessage adapta um amqp.Delivery para a interface Message,
+permitindo que o restante da aplicação não dependa do pacote amqp.
+*/
+type rabbitMQMessage struct {
+	delivery amqp.Delivery
+}
+
+func (m *rabbitMQMessage) ID() string {
+	return strconv.FormatUint(m.delivery.DeliveryTag, 10)
+}
+
+func (m *rabbitMQMessage) Body() []byte {
+	return m.delivery.Body
+}
+
+func (m *rabbitMQMessage) Headers() map[string]interface{} {
+	return m.delivery.Headers
+}
+
+func (m *rabbitMQMessage) Priority() uint8 {
+	return m.delivery.Priority
+}
+
+func (m *rabbitMQMessage) Ack() error {
+	return m.delivery.Ack(false)
+}
+
+func (m *rabbitMQMessage) Nack(requeue bool) error {
+	return m.delivery.Reject(requeue)
+}
+
 /*
 failOnError é uma função utilitária que encerra a aplicação com log
 caso um erro seja encontrado.
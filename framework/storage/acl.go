@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"fmt"
+
+	gcs "cloud.google.com/go/storage"
+)
+
+/*
+ACLPolicy define a permissão aplicada aos objetos enviados para o bucket de saída.
+- public: objetos legíveis por qualquer pessoa (comportamento original do encoder).
+- private: objetos acessíveis apenas pelo dono; a leitura deve usar URLs assinadas.
+- bucket-default: nenhuma ACL é enviada e vale a configuração padrão do bucket.
+*/
+type ACLPolicy string
+
+const (
+	ACLPublic        ACLPolicy = "public"
+	ACLPrivate       ACLPolicy = "private"
+	ACLBucketDefault ACLPolicy = "bucket-default"
+)
+
+/*
+ParseACLPolicy converte o valor informado (ex.: variável UPLOAD_ACL) em um ACLPolicy.
+Um valor vazio mantém o comportamento original (public).
+*/
+func ParseACLPolicy(value string) (ACLPolicy, error) {
+	switch ACLPolicy(value) {
+	case "":
+		return ACLPublic, nil
+	case ACLPublic, ACLPrivate, ACLBucketDefault:
+		return ACLPolicy(value), nil
+	}
+
+	return "", fmt.Errorf("invalid ACL policy: %v", value)
+}
+
+/*
+Apply configura o writer do Google Cloud Storage de acordo com a política.
+*/
+func (p ACLPolicy) Apply(writer *gcs.Writer) {
+	switch p {
+	case ACLPublic:
+		writer.ACL = []gcs.ACLRule{{Entity: gcs.AllUsers, Role: gcs.RoleReader}}
+	case ACLPrivate:
+		writer.PredefinedACL = "private"
+	}
+}
+
+// IsPrivate indica se os objetos só podem ser lidos por meio de URLs assinadas.
+func (p ACLPolicy) IsPrivate() bool {
+	return p == ACLPrivate
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+/*
+Client abstrai o armazenamento de objetos utilizado pelo encoder (buckets de
+entrada e saída), permitindo trocar o Google Cloud Storage por uma implementação
+em memória nos testes.
+*/
+type Client interface {
+	// Upload grava o conteúdo de r no objeto informado, aplicando a política de ACL.
+	// Retorna a quantidade de bytes gravados.
+	Upload(ctx context.Context, bucket string, object string, r io.Reader, acl ACLPolicy) (int64, error)
+	// Download abre o objeto informado para leitura. O chamador deve fechar o reader.
+	Download(ctx context.Context, bucket string, object string) (io.ReadCloser, error)
+	// List retorna, em ordem, os nomes dos objetos do bucket que começam com prefix.
+	List(ctx context.Context, bucket string, prefix string) ([]string, error)
+	// Delete remove o objeto informado.
+	Delete(ctx context.Context, bucket string, object string) error
+	// Close libera os recursos do cliente.
+	Close() error
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+DirClient é uma implementação de Client que grava os objetos em um diretório local:
+o objeto "caminho/arquivo" do bucket "bucket" fica em <Root>/bucket/caminho/arquivo.
+É utilizada para executar jobs localmente, sem um bucket real. A ACL é ignorada.
+*/
+type DirClient struct {
+	Root string
+}
+
+// path retorna o caminho local do objeto, recusando nomes que saiam do diretório do bucket.
+func (c *DirClient) path(bucket string, object string) (string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("invalid bucket: %q", bucket)
+	}
+
+	dir := filepath.Join(c.Root, bucket)
+	path := filepath.Join(dir, filepath.FromSlash(object))
+	if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object: %q", object)
+	}
+
+	return path, nil
+}
+
+func (c *DirClient) Upload(ctx context.Context, bucket string, object string, r io.Reader, acl ACLPolicy) (int64, error) {
+	path, err := c.path(bucket, object)
+	if err != nil {
+		return 0, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return written, err
+	}
+
+	return written, f.Close()
+}
+
+func (c *DirClient) Download(ctx context.Context, bucket string, object string) (io.ReadCloser, error) {
+	path, err := c.path(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+func (c *DirClient) List(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	dir := filepath.Join(c.Root, bucket)
+	names := []string{}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		name = filepath.ToSlash(name)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (c *DirClient) Delete(ctx context.Context, bucket string, object string) error {
+	path, err := c.path(bucket, object)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func (c *DirClient) Close() error {
+	return nil
+}
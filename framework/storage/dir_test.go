@@ -0,0 +1,54 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"microsservico-encoder/framework/storage"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirClient(t *testing.T) {
+	root, err := ioutil.TempDir("", "dir-client")
+	require.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	ctx := context.Background()
+	client := &storage.DirClient{Root: root}
+
+	names, err := client.List(ctx, "bucket", "")
+	require.Nil(t, err)
+	require.Empty(t, names)
+
+	for _, object := range []string{"video/stream.mpd", "video/audio/init.mp4", "other/stream.mpd"} {
+		_, err = client.Upload(ctx, "bucket", object, bytes.NewReader([]byte(object)), storage.ACLPublic)
+		require.Nil(t, err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(root, "bucket", "video", "stream.mpd"))
+	require.Nil(t, err)
+	require.Equal(t, "video/stream.mpd", string(content))
+
+	names, err = client.List(ctx, "bucket", "video/")
+	require.Nil(t, err)
+	require.Equal(t, []string{"video/audio/init.mp4", "video/stream.mpd"}, names)
+
+	r, err := client.Download(ctx, "bucket", "other/stream.mpd")
+	require.Nil(t, err)
+	content, err = ioutil.ReadAll(r)
+	require.Nil(t, err)
+	require.Nil(t, r.Close())
+	require.Equal(t, "other/stream.mpd", string(content))
+
+	require.Nil(t, client.Delete(ctx, "bucket", "video/stream.mpd"))
+	require.Error(t, client.Delete(ctx, "bucket", "video/stream.mpd"))
+
+	_, err = client.Upload(ctx, "bucket", "../escape", bytes.NewReader(nil), storage.ACLPublic)
+	require.Error(t, err)
+	_, err = client.Download(ctx, "..", "bucket/video/audio/init.mp4")
+	require.Error(t, err)
+}
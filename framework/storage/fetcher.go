@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+/*
+Fetcher abre para leitura um arquivo de entrada identificado por uma URI.
+Cada esquema de URI (gs, s3, file, http, https) possui o seu próprio Fetcher.
+*/
+type Fetcher interface {
+	Fetch(ctx context.Context, source *url.URL) (io.ReadCloser, error)
+}
+
+// Fetchers associa cada esquema de URI ao Fetcher responsável por ele.
+type Fetchers map[string]Fetcher
+
+/*
+NewFetchers cria os fetchers padrão:
+- gs://bucket/objeto, lido com o cliente de armazenamento (se nil, o Google Cloud Storage);
+- s3://bucket/objeto, com as credenciais AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY e AWS_REGION;
+- file:///caminho, restrito à pasta INPUT_FILE_ROOT (ex.: um ponto de montagem NFS);
+- http(s)://..., com retomada do download por Range em caso de interrupção.
+*/
+func NewFetchers(client Client) Fetchers {
+	httpFetcher := NewHTTPFetcher()
+
+	return Fetchers{
+		"gs":    &BucketFetcher{Client: client},
+		"s3":    NewS3Fetcher(),
+		"file":  &FileFetcher{Root: os.Getenv("INPUT_FILE_ROOT")},
+		"http":  httpFetcher,
+		"https": httpFetcher,
+	}
+}
+
+/*
+Open seleciona o Fetcher pelo esquema da URI e abre o arquivo para leitura.
+Caminhos sem esquema (ex.: "convite.mp4") são lidos do bucket defaultBucket,
+mantendo o formato original das mensagens.
+*/
+func (f Fetchers) Open(ctx context.Context, uri string, defaultBucket string) (io.ReadCloser, error) {
+	source, err := ParseSourceURI(uri, defaultBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, ok := f[source.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input scheme: %v", source.Scheme)
+	}
+
+	return fetcher.Fetch(ctx, source)
+}
+
+/*
+ParseSourceURI converte o file_path de uma mensagem em uma URI.
+Caminhos sem esquema são convertidos para gs://defaultBucket/caminho.
+*/
+func ParseSourceURI(uri string, defaultBucket string) (*url.URL, error) {
+	if !strings.Contains(uri, "://") {
+		return &url.URL{Scheme: "gs", Host: defaultBucket, Path: "/" + strings.TrimPrefix(uri, "/")}, nil
+	}
+
+	source, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	source.Scheme = strings.ToLower(source.Scheme)
+
+	return source, nil
+}
+
+/*
+BucketFetcher lê objetos de gs://bucket/objeto com um Client.
+Sem um Client configurado, um cliente do GCS é criado e fechado junto com o reader.
+*/
+type BucketFetcher struct {
+	Client Client
+}
+
+func (b *BucketFetcher) Fetch(ctx context.Context, source *url.URL) (io.ReadCloser, error) {
+	bucket, object := source.Host, strings.TrimPrefix(source.Path, "/")
+	if bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid bucket uri: %v", source)
+	}
+
+	if b.Client != nil {
+		return b.Client.Download(ctx, bucket, object)
+	}
+
+	client, err := NewGCSClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := client.Download(ctx, bucket, object)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &closeBoth{ReadCloser: r, client: client}, nil
+}
+
+// closeBoth fecha o reader e o cliente que o criou.
+type closeBoth struct {
+	io.ReadCloser
+	client Client
+}
+
+func (c *closeBoth) Close() error {
+	err := c.ReadCloser.Close()
+	if clientErr := c.client.Close(); err == nil {
+		err = clientErr
+	}
+	return err
+}
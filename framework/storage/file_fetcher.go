@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+FileFetcher lê arquivos locais de URIs file:///caminho.
+Apenas arquivos dentro de Root podem ser lidos, para que uma mensagem não
+consiga acessar arquivos arbitrários do servidor. Sem Root, o esquema é recusado.
+*/
+type FileFetcher struct {
+	Root string
+}
+
+func (f *FileFetcher) Fetch(ctx context.Context, source *url.URL) (io.ReadCloser, error) {
+	if f.Root == "" {
+		return nil, errors.New("file inputs are disabled: INPUT_FILE_ROOT is not set")
+	}
+
+	if source.Host != "" && source.Host != "localhost" {
+		return nil, fmt.Errorf("invalid file uri: %v", source)
+	}
+
+	root, err := filepath.Abs(f.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve links simbólicos para que eles não apontem para fora de Root.
+	path, err := filepath.EvalSymlinks(filepath.Clean(source.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return nil, fmt.Errorf("file %v is outside of %v", source.Path, f.Root)
+	}
+
+	return os.Open(path)
+}
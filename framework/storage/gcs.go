@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSClient é a implementação de Client para o Google Cloud Storage.
+type GCSClient struct {
+	client *gcs.Client
+}
+
+/*
+NewGCSClient cria um cliente autenticado do Google Cloud Storage, utilizando
+as credenciais de GOOGLE_APPLICATION_CREDENTIALS.
+*/
+func NewGCSClient(ctx context.Context) (*GCSClient, error) {
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCSClient{client: client}, nil
+}
+
+func (c *GCSClient) Upload(ctx context.Context, bucket string, object string, r io.Reader, acl ACLPolicy) (int64, error) {
+	wc := c.client.Bucket(bucket).Object(object).NewWriter(ctx)
+	acl.Apply(wc)
+
+	written, err := io.Copy(wc, r)
+	if err != nil {
+		wc.Close()
+		return written, err
+	}
+
+	if err := wc.Close(); err != nil {
+		return written, err
+	}
+
+	return written, nil
+}
+
+func (c *GCSClient) Download(ctx context.Context, bucket string, object string) (io.ReadCloser, error) {
+	return c.client.Bucket(bucket).Object(object).NewReader(ctx)
+}
+
+func (c *GCSClient) List(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	names := []string{}
+
+	it := c.client.Bucket(bucket).Objects(ctx, &gcs.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, attrs.Name)
+	}
+}
+
+func (c *GCSClient) Delete(ctx context.Context, bucket string, object string) error {
+	return c.client.Bucket(bucket).Object(object).Delete(ctx)
+}
+
+func (c *GCSClient) Close() error {
+	return c.client.Close()
+}
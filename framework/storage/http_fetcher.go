@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"microsservico-encoder/framework/utils"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+/*
+HTTPFetcher baixa arquivos de URIs http(s)://.
+Se a conexão for interrompida no meio do download, ou ficar mais de IdleTimeout sem
+receber dados, ele é retomado a partir do último byte recebido com o cabeçalho Range,
+até MaxRetries vezes.
+*/
+type HTTPFetcher struct {
+	Client      *http.Client
+	MaxRetries  int
+	Backoff     time.Duration
+	IdleTimeout time.Duration // Tempo máximo sem receber dados, incluindo a espera pela resposta (0 desativa)
+}
+
+/*
+NewHTTPFetcher cria um HTTPFetcher com as retomadas definidas em
+HTTP_INPUT_MAX_RETRIES (padrão: 5) e HTTP_INPUT_BACKOFF (padrão: 1s), o tempo limite
+de conexão em HTTP_INPUT_CONNECT_TIMEOUT (padrão: 10s) e o de leitura sem dados em
+HTTP_INPUT_IDLE_TIMEOUT (padrão: 30s).
+Os downloads só acessam endereços públicos, a menos que ALLOW_PRIVATE_TARGETS=true.
+*/
+func NewHTTPFetcher() *HTTPFetcher {
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("ALLOW_PRIVATE_TARGETS"))
+	return newHTTPFetcher(allowPrivate)
+}
+
+// newHTTPFetcher cria um HTTPFetcher configurado pelas variáveis de ambiente, restrito ou não a endereços públicos.
+func newHTTPFetcher(allowPrivate bool) *HTTPFetcher {
+	maxRetries, err := strconv.Atoi(os.Getenv("HTTP_INPUT_MAX_RETRIES"))
+	if err != nil || maxRetries < 0 {
+		maxRetries = 5
+	}
+
+	backoff, err := time.ParseDuration(os.Getenv("HTTP_INPUT_BACKOFF"))
+	if err != nil {
+		backoff = time.Second
+	}
+
+	connectTimeout, err := time.ParseDuration(os.Getenv("HTTP_INPUT_CONNECT_TIMEOUT"))
+	if err != nil || connectTimeout < 0 {
+		connectTimeout = 10 * time.Second
+	}
+
+	idleTimeout, err := time.ParseDuration(os.Getenv("HTTP_INPUT_IDLE_TIMEOUT"))
+	if err != nil || idleTimeout < 0 {
+		idleTimeout = 30 * time.Second
+	}
+
+	return &HTTPFetcher{
+		Client:      &http.Client{Transport: utils.NewHTTPTransport(connectTimeout, allowPrivate)},
+		MaxRetries:  maxRetries,
+		Backoff:     backoff,
+		IdleTimeout: idleTimeout,
+	}
+}
+
+func (h *HTTPFetcher) Fetch(ctx context.Context, source *url.URL) (io.ReadCloser, error) {
+	reader := &resumableReader{ctx: ctx, fetcher: h, url: source.String()}
+
+	err := reader.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return reader, nil
+}
+
+/*
+resumableReader lê o corpo de uma resposta HTTP e, em caso de erro de leitura,
+refaz a requisição pedindo apenas os bytes que ainda não foram lidos.
+*/
+type resumableReader struct {
+	ctx     context.Context
+	fetcher *HTTPFetcher
+	url     string
+	body    io.ReadCloser
+	offset  int64
+	retries int
+	failure error      // Erro de uma leitura que também entregou bytes; a retomada ocorre na leitura seguinte
+	idle    *idleTimer // Cancela a requisição atual após IdleTimeout sem dados
+}
+
+/*
+open faz a requisição a partir de offset. Retomadas exigem suporte do servidor a Range:
+a resposta parcial deve começar exatamente no byte pedido.
+*/
+func (r *resumableReader) open() error {
+	request, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.idle = newIdleTimer(r.fetcher.IdleTimeout, cancel)
+	request = request.WithContext(ctx)
+
+	if r.offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+
+	response, err := r.fetcher.Client.Do(request)
+	if err != nil {
+		r.idle.stop()
+		return r.idle.wrap(err)
+	}
+
+	expected := http.StatusOK
+	if r.offset > 0 {
+		expected = http.StatusPartialContent
+	}
+
+	if response.StatusCode != expected {
+		r.idle.stop()
+		response.Body.Close()
+		return fmt.Errorf("unexpected status code %d downloading %v", response.StatusCode, r.url)
+	}
+
+	if r.offset > 0 {
+		contentRange := response.Header.Get("Content-Range")
+		if start, ok := contentRangeStart(contentRange); !ok || start != r.offset {
+			r.idle.stop()
+			response.Body.Close()
+			return fmt.Errorf("unexpected content range %q resuming %v at byte %d", contentRange, r.url, r.offset)
+		}
+	}
+
+	r.idle.pause()
+	r.body = response.Body
+	return nil
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	for {
+		err := r.failure
+		r.failure = nil
+
+		if err == nil {
+			var n int
+			r.idle.reset()
+			n, err = r.body.Read(p)
+			r.idle.pause()
+			r.offset += int64(n)
+			err = r.idle.wrap(err)
+
+			if err == nil || err == io.EOF {
+				return n, err
+			}
+
+			// Os bytes recebidos são entregues agora e a retomada fica para a próxima leitura.
+			if n > 0 {
+				r.failure = err
+				return n, nil
+			}
+		}
+
+		if r.retries >= r.fetcher.MaxRetries || r.ctx.Err() != nil {
+			return 0, err
+		}
+
+		r.retries++
+		log.Printf("download of %v interrupted at byte %d, resuming (%d/%d): %v", r.url, r.offset, r.retries, r.fetcher.MaxRetries, err)
+		r.idle.stop()
+		r.body.Close()
+
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(r.fetcher.Backoff):
+		}
+
+		if openErr := r.open(); openErr != nil {
+			return 0, openErr
+		}
+	}
+}
+
+func (r *resumableReader) Close() error {
+	r.idle.stop()
+	return r.body.Close()
+}
+
+/*
+idleTimer cancela a requisição quando nenhum dado é recebido dentro do tempo limite.
+O Client não pode ter um Timeout total, pois ele interromperia downloads longos que
+continuam recebendo dados.
+*/
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired int32
+}
+
+// newIdleTimer inicia o tempo limite da requisição; com timeout 0, nunca cancela.
+func newIdleTimer(timeout time.Duration, cancel context.CancelFunc) *idleTimer {
+	t := &idleTimer{timeout: timeout, cancel: cancel}
+	if timeout > 0 {
+		t.timer = time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&t.expired, 1)
+			cancel()
+		})
+	}
+
+	return t
+}
+
+// reset reinicia a contagem antes de cada leitura.
+func (t *idleTimer) reset() {
+	if t.timer != nil {
+		t.timer.Reset(t.timeout)
+	}
+}
+
+// pause suspende a contagem enquanto o chamador processa os dados recebidos.
+func (t *idleTimer) pause() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// stop encerra a contagem e libera o contexto da requisição, que não será mais lida.
+func (t *idleTimer) stop() {
+	t.pause()
+	t.cancel()
+}
+
+// wrap substitui o erro de cancelamento causado pelo tempo limite por uma mensagem clara.
+func (t *idleTimer) wrap(err error) error {
+	if err != nil && err != io.EOF && atomic.LoadInt32(&t.expired) == 1 {
+		return fmt.Errorf("no data received for %v: %w", t.timeout, err)
+	}
+
+	return err
+}
+
+// contentRangeStart retorna o primeiro byte de um cabeçalho Content-Range (ex.: "bytes 8-19/20").
+func contentRangeStart(contentRange string) (int64, bool) {
+	var start, end int64
+	var size string
+
+	_, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &size)
+	if err != nil || start > end {
+		return 0, false
+	}
+
+	return start, true
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+MemoryClient é uma implementação de Client que mantém os objetos em memória.
+É utilizada em testes para exercitar download e upload sem um bucket real.
+*/
+type MemoryClient struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	acls    map[string]ACLPolicy
+}
+
+// NewMemoryClient cria um MemoryClient vazio.
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		objects: map[string][]byte{},
+		acls:    map[string]ACLPolicy{},
+	}
+}
+
+// Put grava um objeto diretamente, para preparar o conteúdo dos testes.
+func (c *MemoryClient) Put(bucket string, object string, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.objects[bucket+"/"+object] = content
+}
+
+// Get retorna o conteúdo de um objeto e se ele existe.
+func (c *MemoryClient) Get(bucket string, object string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, ok := c.objects[bucket+"/"+object]
+	return content, ok
+}
+
+// ACL retorna a política de ACL aplicada no upload do objeto.
+func (c *MemoryClient) ACL(bucket string, object string) ACLPolicy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.acls[bucket+"/"+object]
+}
+
+// Objects retorna os nomes ("bucket/objeto") de todos os objetos armazenados, em ordem.
+func (c *MemoryClient) Objects() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.objects))
+	for name := range c.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (c *MemoryClient) Upload(ctx context.Context, bucket string, object string, r io.Reader, acl ACLPolicy) (int64, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.objects[bucket+"/"+object] = content
+	c.acls[bucket+"/"+object] = acl
+
+	return int64(len(content)), nil
+}
+
+func (c *MemoryClient) Download(ctx context.Context, bucket string, object string) (io.ReadCloser, error) {
+	content, ok := c.Get(bucket, object)
+	if !ok {
+		return nil, fmt.Errorf("object %v/%v does not exist", bucket, object)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (c *MemoryClient) List(ctx context.Context, bucket string, prefix string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := []string{}
+	for name := range c.objects {
+		if strings.HasPrefix(name, bucket+"/"+prefix) {
+			names = append(names, strings.TrimPrefix(name, bucket+"/"))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (c *MemoryClient) Delete(ctx context.Context, bucket string, object string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.objects[bucket+"/"+object]; !ok {
+		return fmt.Errorf("object %v/%v does not exist", bucket, object)
+	}
+
+	delete(c.objects, bucket+"/"+object)
+	delete(c.acls, bucket+"/"+object)
+
+	return nil
+}
+
+func (c *MemoryClient) Close() error {
+	return nil
+}
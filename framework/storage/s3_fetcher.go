@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash é o SHA-256 de um corpo vazio, utilizado nas requisições GET.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+/*
+S3Fetcher lê objetos de s3://bucket/objeto pela API REST do Amazon S3 (ou de um
+serviço compatível, como o MinIO, quando Endpoint é informado), assinando as
+requisições com AWS Signature Version 4.
+*/
+type S3Fetcher struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Endpoint        string // Ex.: http://minio:9000. Se vazio, https://s3.<região>.amazonaws.com
+	HTTP            *HTTPFetcher
+}
+
+/*
+NewS3Fetcher cria um S3Fetcher com as credenciais AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+AWS_SESSION_TOKEN, a região AWS_REGION (padrão: us-east-1) e o endpoint opcional S3_ENDPOINT.
+O endpoint é definido pelo operador, e não pelas mensagens, por isso pode estar na rede
+interna (ex.: um MinIO): os downloads não são restritos a endereços públicos.
+*/
+func NewS3Fetcher() *S3Fetcher {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Fetcher{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          region,
+		Endpoint:        os.Getenv("S3_ENDPOINT"),
+		HTTP:            newHTTPFetcher(true),
+	}
+}
+
+/*
+Fetch baixa o objeto com uma requisição GET assinada no estilo path
+(<endpoint>/<bucket>/<objeto>). As retomadas por Range são assinadas novamente.
+*/
+func (s *S3Fetcher) Fetch(ctx context.Context, source *url.URL) (io.ReadCloser, error) {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, errors.New("s3 inputs require AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	bucket, object := source.Host, strings.TrimPrefix(source.Path, "/")
+	if bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid s3 uri: %v", source)
+	}
+
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+
+	target, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + object)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher := *s.HTTP
+	fetcher.Client = &http.Client{
+		Timeout:   s.HTTP.Client.Timeout,
+		Transport: &s3SigningTransport{signer: s, base: s.HTTP.Client.Transport},
+	}
+
+	return fetcher.Fetch(ctx, target)
+}
+
+// s3SigningTransport assina cada requisição (inclusive as retomadas) antes de enviá-la.
+type s3SigningTransport struct {
+	signer *S3Fetcher
+	base   http.RoundTripper
+}
+
+func (t *s3SigningTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	t.signer.Sign(request, time.Now())
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return base.RoundTrip(request)
+}
+
+/*
+Sign adiciona à requisição os cabeçalhos de autenticação AWS Signature Version 4
+para o serviço s3, assinando todos os cabeçalhos presentes e o host, com a data informada.
+*/
+func (s *S3Fetcher) Sign(request *http.Request, at time.Time) {
+	timestamp := at.UTC()
+	amzDate := timestamp.Format("20060102T150405Z")
+	date := timestamp.Format("20060102")
+
+	request.Header.Set("x-amz-date", amzDate)
+	request.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if s.SessionToken != "" {
+		request.Header.Set("x-amz-security-token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.Query().Encode(),
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// signedManifestSuffix é inserido antes da extensão das cópias assinadas dos manifestos (ex.: stream.signed.mpd).
+const signedManifestSuffix = ".signed"
+
+var (
+	dashURLAttribute = regexp.MustCompile(`\b(sourceURL|media)="([^"]*)"`)
+	dashBaseURL      = regexp.MustCompile(`<BaseURL([^>]*)>([^<]*)</BaseURL>`)
+	hlsURIAttribute  = regexp.MustCompile(`URI="([^"]*)"`)
+)
+
+/*
+SignManifest publica uma cópia do manifesto DASH (.mpd) ou HLS (.m3u8) informado em que
+as referências relativas (inicializações, segmentos, legendas e playlists) são
+substituídas por URLs assinadas, e retorna a URL assinada dessa cópia.
+Com a ACL privada, é a cópia que permite a reprodução: o player recebe apenas URLs
+assinadas, que expiram juntas após TTL. As playlists HLS referenciadas também ganham
+cópias assinadas. Manifestos DASH com SegmentTemplate não podem ser assinados, pois as
+URLs dos segmentos são montadas pelo player: a saída deve usar SegmentList.
+*/
+func (s *URLSigner) SignManifest(ctx context.Context, client Client, bucket string, manifest string) (string, time.Time, error) {
+	signer := &manifestSigner{
+		ctx:     ctx,
+		signer:  s,
+		client:  client,
+		bucket:  bucket,
+		expires: time.Now().Add(s.TTL),
+		signed:  map[string]string{},
+	}
+
+	url, err := signer.sign(manifest)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return url, signer.expires, nil
+}
+
+// SignedManifestName retorna o nome da cópia assinada de um manifesto (ex.: stream.signed.mpd).
+func SignedManifestName(manifest string) string {
+	extension := path.Ext(manifest)
+	return strings.TrimSuffix(manifest, extension) + signedManifestSuffix + extension
+}
+
+// manifestSigner assina os manifestos de uma saída, publicando cada playlist uma única vez.
+type manifestSigner struct {
+	ctx     context.Context
+	signer  *URLSigner
+	client  Client
+	bucket  string
+	expires time.Time
+	signed  map[string]string // URL assinada da cópia de cada manifesto já publicado
+}
+
+// sign publica a cópia assinada do manifesto e retorna a sua URL assinada.
+func (m *manifestSigner) sign(manifest string) (string, error) {
+	if url, ok := m.signed[manifest]; ok {
+		return url, nil
+	}
+
+	reader, err := m.client.Download(m.ctx, m.bucket, manifest)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return "", err
+	}
+
+	resolve := func(reference string) (string, error) {
+		return m.resolve(manifest, reference)
+	}
+
+	switch path.Ext(manifest) {
+	case ".mpd":
+		content, err = rewriteDASH(content, resolve)
+	case ".m3u8":
+		content, err = rewriteHLS(content, resolve)
+	default:
+		err = errors.New("unsupported manifest format")
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("error signing manifest %v: %w", manifest, err)
+	}
+
+	signedManifest := SignedManifestName(manifest)
+	_, err = m.client.Upload(m.ctx, m.bucket, signedManifest, bytes.NewReader(content), ACLPrivate)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := m.signer.signURL(m.bucket, signedManifest, m.expires)
+	if err != nil {
+		return "", err
+	}
+
+	m.signed[manifest] = url
+	return url, nil
+}
+
+/*
+resolve retorna a URL assinada de uma referência do manifesto. Referências absolutas
+(outros hosts, data:, skd:// etc.) são mantidas; playlists HLS são assinadas recursivamente.
+*/
+func (m *manifestSigner) resolve(manifest string, reference string) (string, error) {
+	parsed, err := url.Parse(reference)
+	if err != nil {
+		return "", err
+	}
+
+	if parsed.IsAbs() || parsed.Host != "" || strings.HasPrefix(parsed.Path, "/") || parsed.Path == "" {
+		return reference, nil
+	}
+
+	object := path.Join(path.Dir(manifest), parsed.Path)
+	if path.Ext(object) == ".m3u8" {
+		return m.sign(object)
+	}
+
+	return m.signer.signURL(m.bucket, object, m.expires)
+}
+
+/*
+rewriteDASH substitui as URLs de Initialization, SegmentURL e BaseURL do manifesto.
+BaseURLs de diretório não são aceitas, pois mudariam a resolução das demais URLs.
+*/
+func rewriteDASH(content []byte, resolve func(string) (string, error)) ([]byte, error) {
+	if bytes.Contains(content, []byte("<SegmentTemplate")) {
+		return nil, errors.New("segment templates cannot be signed, use a segment list")
+	}
+
+	var err error
+	replace := func(reference string) string {
+		if err != nil {
+			return reference
+		}
+
+		var url string
+		url, err = resolve(xmlUnescape(reference))
+		return xmlEscape(url)
+	}
+
+	content = dashURLAttribute.ReplaceAllFunc(content, func(match []byte) []byte {
+		groups := dashURLAttribute.FindSubmatch(match)
+		return []byte(fmt.Sprintf(`%s="%s"`, groups[1], replace(string(groups[2]))))
+	})
+
+	content = dashBaseURL.ReplaceAllFunc(content, func(match []byte) []byte {
+		groups := dashBaseURL.FindSubmatch(match)
+		reference := strings.TrimSpace(string(groups[2]))
+		if strings.HasSuffix(reference, "/") && err == nil {
+			err = fmt.Errorf("directory base URL %q cannot be signed", reference)
+		}
+
+		return []byte(fmt.Sprintf(`<BaseURL%s>%s</BaseURL>`, groups[1], replace(reference)))
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// rewriteHLS substitui as URIs da playlist: as linhas de mídia e os atributos URI das tags.
+func rewriteHLS(content []byte, resolve func(string) (string, error)) ([]byte, error) {
+	var err error
+	replace := func(reference string) string {
+		if err != nil {
+			return reference
+		}
+
+		var url string
+		url, err = resolve(reference)
+		return url
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			lines[i] = hlsURIAttribute.ReplaceAllStringFunc(line, func(match string) string {
+				return `URI="` + replace(hlsURIAttribute.FindStringSubmatch(match)[1]) + `"`
+			})
+		default:
+			lines[i] = replace(line)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// xmlEscape escapa um valor para uso em atributos e textos XML.
+func xmlEscape(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
+
+// xmlUnescape desfaz o escape das entidades XML predefinidas.
+func xmlUnescape(value string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(value)
+}
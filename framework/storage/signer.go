@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+)
+
+// MaxSignedURLTTL é a maior validade aceita pelo Cloud Storage para as URLs assinadas V4.
+const MaxSignedURLTTL = 7 * 24 * time.Hour
+
+// ErrInvalidSignedURLTTL indica uma validade das URLs assinadas fora do intervalo aceito pelo Cloud Storage.
+var ErrInvalidSignedURLTTL = errors.New("invalid signed URL TTL")
+
+/*
+URLSigner gera URLs assinadas com prazo de validade para leitura de objetos privados,
+utilizando a chave de uma service account do Google Cloud.
+*/
+type URLSigner struct {
+	GoogleAccessID string        // E-mail da service account
+	PrivateKey     []byte        // Chave privada PEM da service account
+	TTL            time.Duration // Validade das URLs geradas
+}
+
+// serviceAccount contém os campos utilizados do arquivo de credenciais da service account.
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+}
+
+/*
+NewURLSigner cria um URLSigner a partir do arquivo de credenciais JSON da service account.
+Retorna ErrInvalidSignedURLTTL se ttl não for positivo ou for maior que MaxSignedURLTTL:
+o Cloud Storage recusaria todas as URLs geradas.
+*/
+func NewURLSigner(credentialsFile string, ttl time.Duration) (*URLSigner, error) {
+	if ttl <= 0 || ttl > MaxSignedURLTTL {
+		return nil, fmt.Errorf("%w: %v must be positive and at most %v", ErrInvalidSignedURLTTL, ttl, MaxSignedURLTTL)
+	}
+
+	content, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var account serviceAccount
+	err = json.Unmarshal(content, &account)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, errors.New("credentials file has no client_email or private_key")
+	}
+
+	return &URLSigner{
+		GoogleAccessID: account.ClientEmail,
+		PrivateKey:     []byte(account.PrivateKey),
+		TTL:            ttl,
+	}, nil
+}
+
+/*
+NewURLSignerFromEnv cria um URLSigner com as credenciais de GOOGLE_APPLICATION_CREDENTIALS
+e a validade definida em SIGNED_URL_TTL (padrão: 1h; no máximo MaxSignedURLTTL).
+Um SIGNED_URL_TTL inválido retorna ErrInvalidSignedURLTTL.
+*/
+func NewURLSignerFromEnv() (*URLSigner, error) {
+	ttl := time.Hour
+	if value := os.Getenv("SIGNED_URL_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: SIGNED_URL_TTL: %v", ErrInvalidSignedURLTTL, err)
+		}
+		ttl = parsed
+	}
+
+	return NewURLSigner(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), ttl)
+}
+
+/*
+SignURL gera uma URL assinada (V4) para leitura do objeto, válida por TTL.
+Retorna também a data de expiração da URL.
+*/
+func (s *URLSigner) SignURL(bucket string, object string) (string, time.Time, error) {
+	expires := time.Now().Add(s.TTL)
+
+	url, err := s.signURL(bucket, object, expires)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return url, expires, nil
+}
+
+// signURL gera a URL assinada (V4) para leitura do objeto, válida até expires.
+func (s *URLSigner) signURL(bucket string, object string, expires time.Time) (string, error) {
+	return gcs.SignedURL(bucket, object, &gcs.SignedURLOptions{
+		GoogleAccessID: s.GoogleAccessID,
+		PrivateKey:     s.PrivateKey,
+		Method:         "GET",
+		Expires:        expires,
+		Scheme:         gcs.SigningSchemeV4,
+	})
+}
+
+/*
+SignURLs gera URLs assinadas para vários objetos do mesmo bucket, como o manifesto
+e os segmentos de um vídeo. O resultado é indexado pelo nome do objeto.
+*/
+func (s *URLSigner) SignURLs(bucket string, objects []string) (map[string]string, error) {
+	urls := make(map[string]string, len(objects))
+
+	for _, object := range objects {
+		url, _, err := s.SignURL(bucket, object)
+		if err != nil {
+			return nil, err
+		}
+		urls[object] = url
+	}
+
+	return urls, nil
+}
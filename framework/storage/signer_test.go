@@ -0,0 +1,229 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"io/ioutil"
+	"microsservico-encoder/framework/storage"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseACLPolicy(t *testing.T) {
+	acl, err := storage.ParseACLPolicy("")
+	require.Nil(t, err)
+	require.Equal(t, storage.ACLPublic, acl)
+
+	acl, err = storage.ParseACLPolicy("private")
+	require.Nil(t, err)
+	require.True(t, acl.IsPrivate())
+
+	acl, err = storage.ParseACLPolicy("bucket-default")
+	require.Nil(t, err)
+	require.Equal(t, storage.ACLBucketDefault, acl)
+
+	_, err = storage.ParseACLPolicy("world")
+	require.Error(t, err)
+}
+
+/*
+TestURLSignerSignsObjects gera uma service account falsa e verifica que as URLs
+assinadas apontam para o objeto e expiram de acordo com o TTL.
+*/
+func TestURLSignerSignsObjects(t *testing.T) {
+	signer := newTestSigner(t, 15*time.Minute)
+
+	signed, expires, err := signer.SignURL("bucket", "video/stream.mpd")
+	require.Nil(t, err)
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), expires, time.Minute)
+
+	parsed, err := url.Parse(signed)
+	require.Nil(t, err)
+	require.Equal(t, "/bucket/video/stream.mpd", parsed.Path)
+	require.NotEmpty(t, parsed.Query().Get("X-Goog-Expires"))
+	require.NotEmpty(t, parsed.Query().Get("X-Goog-Signature"))
+
+	urls, err := signer.SignURLs("bucket", []string{"video/stream.mpd", "video/video/avc1/seg-1.m4s"})
+	require.Nil(t, err)
+	require.Len(t, urls, 2)
+}
+
+/*
+TestNewURLSignerValidatesTTL verifica que validades fora do intervalo aceito pelas URLs
+assinadas V4 são recusadas na criação do URLSigner.
+*/
+func TestNewURLSignerValidatesTTL(t *testing.T) {
+	newTestSigner(t, storage.MaxSignedURLTTL)
+
+	for _, ttl := range []time.Duration{0, -time.Minute, storage.MaxSignedURLTTL + time.Second} {
+		_, err := storage.NewURLSigner("credentials.json", ttl)
+		require.ErrorIs(t, err, storage.ErrInvalidSignedURLTTL, ttl)
+	}
+
+	t.Setenv("SIGNED_URL_TTL", "8d")
+	_, err := storage.NewURLSignerFromEnv()
+	require.ErrorIs(t, err, storage.ErrInvalidSignedURLTTL)
+
+	t.Setenv("SIGNED_URL_TTL", "169h")
+	_, err = storage.NewURLSignerFromEnv()
+	require.ErrorIs(t, err, storage.ErrInvalidSignedURLTTL)
+}
+
+// newTestSigner cria um URLSigner com uma service account falsa, gerada para o teste.
+func newTestSigner(t *testing.T, ttl time.Duration) *storage.URLSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	credentials, err := json.Marshal(map[string]string{
+		"client_email": "encoder@project.iam.gserviceaccount.com",
+		"private_key":  string(privateKey),
+	})
+	require.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "signer")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	credentialsFile := filepath.Join(dir, "credentials.json")
+	require.Nil(t, ioutil.WriteFile(credentialsFile, credentials, 0600))
+
+	signer, err := storage.NewURLSigner(credentialsFile, ttl)
+	require.Nil(t, err)
+
+	return signer
+}
+
+const testMPD = `<?xml version="1.0" ?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <Representation id="video/avc1" bandwidth="800000">
+        <SegmentList timescale="1000" duration="10000">
+          <Initialization sourceURL="video/avc1/init.mp4"/>
+          <SegmentURL media="video/avc1/seg-1.m4s"/>
+          <SegmentURL media="video/avc1/seg-2.m4s"/>
+        </SegmentList>
+      </Representation>
+    </AdaptationSet>
+    <AdaptationSet mimeType="text/vtt" lang="pt-BR">
+      <Representation id="subtitles/pt-BR" bandwidth="0">
+        <BaseURL>subtitles/pt-BR/subtitles.vtt</BaseURL>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",LANGUAGE="en",URI="audio/en/mp4a/media.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO="audio"
+video/avc1/media.m3u8
+`
+
+const testMediaPlaylist = `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key-id"
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:10.0,
+seg-1.m4s
+#EXTINF:10.0,
+seg-2.m4s
+#EXT-X-ENDLIST
+`
+
+/*
+TestURLSignerSignManifest verifica que as cópias assinadas dos manifestos DASH e HLS
+referenciam apenas URLs assinadas dos objetos da saída, incluindo os segmentos e as
+playlists das variantes, e que todas expiram juntas.
+*/
+func TestURLSignerSignManifest(t *testing.T) {
+	signer := newTestSigner(t, time.Hour)
+
+	client := storage.NewMemoryClient()
+	client.Put("bucket", "tenant/video-id/stream.mpd", []byte(testMPD))
+	client.Put("bucket", "tenant/video-id/master.m3u8", []byte(testMasterPlaylist))
+	client.Put("bucket", "tenant/video-id/video/avc1/media.m3u8", []byte(testMediaPlaylist))
+	client.Put("bucket", "tenant/video-id/audio/en/mp4a/media.m3u8", []byte(testMediaPlaylist))
+
+	// requireSigned verifica que a URL é assinada, aponta para o objeto informado e expira com as demais.
+	var validity string
+	requireSigned := func(signed string, object string) {
+		parsed, err := url.Parse(signed)
+		require.Nil(t, err)
+		require.Equal(t, "/bucket/"+object, parsed.Path)
+		require.NotEmpty(t, parsed.Query().Get("X-Goog-Signature"))
+
+		urlValidity := parsed.Query().Get("X-Goog-Date") + "+" + parsed.Query().Get("X-Goog-Expires")
+		if validity == "" {
+			validity = urlValidity
+		}
+		require.Equal(t, validity, urlValidity)
+	}
+
+	ctx := context.Background()
+
+	dashURL, expires, err := signer.SignManifest(ctx, client, "bucket", "tenant/video-id/stream.mpd")
+	require.Nil(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+	requireSigned(dashURL, "tenant/video-id/stream.signed.mpd")
+
+	mpd, ok := client.Get("bucket", "tenant/video-id/stream.signed.mpd")
+	require.True(t, ok)
+	require.Equal(t, storage.ACLPrivate, client.ACL("bucket", "tenant/video-id/stream.signed.mpd"))
+
+	var document struct {
+		Initialization []struct {
+			SourceURL string `xml:"sourceURL,attr"`
+		} `xml:"Period>AdaptationSet>Representation>SegmentList>Initialization"`
+		Segments []struct {
+			Media string `xml:"media,attr"`
+		} `xml:"Period>AdaptationSet>Representation>SegmentList>SegmentURL"`
+		BaseURLs []string `xml:"Period>AdaptationSet>Representation>BaseURL"`
+	}
+	require.Nil(t, xml.Unmarshal(mpd, &document))
+	require.Len(t, document.Initialization, 1)
+	requireSigned(document.Initialization[0].SourceURL, "tenant/video-id/video/avc1/init.mp4")
+	require.Len(t, document.Segments, 2)
+	requireSigned(document.Segments[0].Media, "tenant/video-id/video/avc1/seg-1.m4s")
+	requireSigned(document.Segments[1].Media, "tenant/video-id/video/avc1/seg-2.m4s")
+	require.Len(t, document.BaseURLs, 1)
+	requireSigned(document.BaseURLs[0], "tenant/video-id/subtitles/pt-BR/subtitles.vtt")
+
+	validity = ""
+	hlsURL, _, err := signer.SignManifest(ctx, client, "bucket", "tenant/video-id/master.m3u8")
+	require.Nil(t, err)
+	requireSigned(hlsURL, "tenant/video-id/master.signed.m3u8")
+
+	master, ok := client.Get("bucket", "tenant/video-id/master.signed.m3u8")
+	require.True(t, ok)
+	lines := strings.Split(string(master), "\n")
+	requireSigned(lines[3], "tenant/video-id/video/avc1/media.signed.m3u8")
+	audio := regexp.MustCompile(`URI="([^"]*)"`).FindStringSubmatch(lines[1])
+	require.Len(t, audio, 2)
+	requireSigned(audio[1], "tenant/video-id/audio/en/mp4a/media.signed.m3u8")
+
+	media, ok := client.Get("bucket", "tenant/video-id/video/avc1/media.signed.m3u8")
+	require.True(t, ok)
+	lines = strings.Split(string(media), "\n")
+	require.Equal(t, `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key-id"`, lines[2])
+	requireSigned(strings.TrimSuffix(strings.TrimPrefix(lines[3], `#EXT-X-MAP:URI="`), `"`), "tenant/video-id/video/avc1/init.mp4")
+	requireSigned(lines[5], "tenant/video-id/video/avc1/seg-1.m4s")
+	requireSigned(lines[7], "tenant/video-id/video/avc1/seg-2.m4s")
+
+	client.Put("bucket", "template/stream.mpd", []byte(`<MPD><SegmentTemplate media="seg-$Number$.m4s"/></MPD>`))
+	_, _, err = signer.SignManifest(ctx, client, "bucket", "template/stream.mpd")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "segment list")
+}
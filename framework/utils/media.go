@@ -0,0 +1,79 @@
+package utils
+
+import "bytes"
+
+// SniffLength é a quantidade de bytes do início do arquivo utilizada por DetectContainer.
+const SniffLength = 512
+
+/*
+DetectContainer identifica o formato do contêiner de vídeo a partir dos primeiros
+bytes do arquivo (idealmente SniffLength bytes), sem confiar na extensão ou no
+content-type informado pela origem.
+Retorna "mp4", "mov", "mkv", "webm", "avi", "ts" ou "flv", ou uma string vazia
+se o conteúdo não for reconhecido como vídeo.
+*/
+func DetectContainer(header []byte) string {
+	switch {
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		if bytes.Equal(header[8:12], []byte("qt  ")) {
+			return "mov"
+		}
+		return "mp4"
+	case len(header) >= 8 && isQuickTimeAtom(header[4:8]):
+		return "mov"
+	case len(header) >= 4 && bytes.Equal(header[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// O DocType do cabeçalho EBML diferencia WebM de Matroska.
+		if bytes.Contains(header, []byte("webm")) {
+			return "webm"
+		}
+		return "mkv"
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")):
+		return "avi"
+	case len(header) >= 189 && header[0] == 0x47 && header[188] == 0x47:
+		return "ts"
+	case len(header) >= 3 && bytes.Equal(header[:3], []byte("FLV")):
+		return "flv"
+	}
+
+	return ""
+}
+
+/*
+DetectAudioContainer identifica o formato de um arquivo de áudio a partir dos primeiros
+bytes. Além dos contêineres reconhecidos por DetectContainer (ex.: .m4a é "mp4"),
+retorna "mp3", "aac" (ADTS), "wav", "ogg" ou "flac", ou uma string vazia se o
+conteúdo não for reconhecido.
+*/
+func DetectAudioContainer(header []byte) string {
+	if container := DetectContainer(header); container != "" {
+		return container
+	}
+
+	switch {
+	case len(header) >= 3 && bytes.Equal(header[:3], []byte("ID3")):
+		return "mp3"
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xF6 == 0xF0:
+		// Sincronismo de um frame ADTS (MPEG-4/MPEG-2 AAC, layer 0).
+		return "aac"
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return "mp3"
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return "wav"
+	case len(header) >= 4 && bytes.Equal(header[:4], []byte("OggS")):
+		return "ogg"
+	case len(header) >= 4 && bytes.Equal(header[:4], []byte("fLaC")):
+		return "flac"
+	}
+
+	return ""
+}
+
+// isQuickTimeAtom indica se o tipo do primeiro atom é típico de arquivos QuickTime sem ftyp.
+func isQuickTimeAtom(atom []byte) bool {
+	for _, known := range []string{"moov", "mdat", "wide", "free", "skip"} {
+		if bytes.Equal(atom, []byte(known)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress indica uma conexão recusada com um endereço interno (loopback, rede privada, link-local...).
+var ErrForbiddenAddress = errors.New("destination address is not allowed")
+
+// sharedAddressSpace é a faixa de CGNAT (RFC 6598), também usada em redes internas de provedores de nuvem.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+/*
+IsPublicAddress verifica se o endereço pode ser acessado a partir de URLs informadas nas
+mensagens: loopback, redes privadas, link-local (incluindo o metadata server da nuvem,
+169.254.169.254), CGNAT, multicast e o endereço não especificado são recusados.
+*/
+func IsPublicAddress(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+/*
+NewHTTPTransport cria o transporte dos clientes HTTP que acessam URLs informadas nas
+mensagens (entradas http(s) e callback_url), com o tempo limite de conexão informado.
+Sem allowPrivate, as conexões são restritas a endereços públicos: a verificação ocorre
+a cada conexão, depois da resolução de DNS, e cobre também os redirecionamentos.
+Proxies do ambiente não são utilizados, para que a verificação valha para o destino final.
+*/
+func NewHTTPTransport(connectTimeout time.Duration, allowPrivate bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicAddressesOnly
+	}
+
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: connectTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// publicAddressesOnly recusa a conexão com o endereço resolvido quando ele não é público.
+func publicAddressesOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicAddress(ip) {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM       = []byte("\xEF\xBB\xBF")
+	srtTimestamps = regexp.MustCompile(`^(\d{1,2}:\d{2}:\d{2}),(\d{3})\s*-->\s*(\d{1,2}:\d{2}:\d{2}),(\d{3})(.*)$`)
+)
+
+/*
+DetectSubtitleFormat identifica o formato de um arquivo de legendas a partir dos
+primeiros bytes. Retorna "vtt" (WebVTT), "srt" (SubRip) ou uma string vazia se o
+conteúdo não for reconhecido.
+*/
+func DetectSubtitleFormat(header []byte) string {
+	header = bytes.TrimPrefix(header, utf8BOM)
+
+	// O cabeçalho pode ter sido cortado no meio de um caractere.
+	for len(header) > 0 && !utf8.Valid(header) {
+		header = header[:len(header)-1]
+	}
+	if len(header) == 0 || bytes.IndexByte(header, 0) >= 0 {
+		return ""
+	}
+
+	if bytes.HasPrefix(header, []byte("WEBVTT")) {
+		return "vtt"
+	}
+
+	// No SRT, o primeiro bloco é o número da legenda seguido da linha de tempo.
+	lines := strings.Split(strings.ReplaceAll(string(header), "\r\n", "\n"), "\n")
+	if len(lines) >= 2 && isDigits(strings.TrimSpace(lines[0])) && srtTimestamps.MatchString(strings.TrimSpace(lines[1])) {
+		return "srt"
+	}
+
+	return ""
+}
+
+/*
+SRTToWebVTT converte legendas SubRip para WebVTT: adiciona o cabeçalho WEBVTT e
+troca a vírgula dos milissegundos por ponto nas linhas de tempo. A numeração das
+legendas é mantida como identificador das cues.
+*/
+func SRTToWebVTT(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	out := bufio.NewWriter(w)
+
+	_, err := out.WriteString("WEBVTT\n\n")
+	if err != nil {
+		return err
+	}
+
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if first {
+			line = strings.TrimPrefix(line, string(utf8BOM))
+			first = false
+		}
+
+		if match := srtTimestamps.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			line = match[1] + "." + match[2] + " --> " + match[3] + "." + match[4] + match[5]
+		}
+
+		_, err = out.WriteString(line + "\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,7 +1,10 @@
 package utils_test
 
 import (
+	"bytes"
 	"microsservico-encoder/framework/utils"
+	"net"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -21,3 +24,48 @@ func TestIsJson(t *testing.T) {
 	err = utils.IsJson(json)
 	require.Error(t, err)
 }
+
+func TestDetectContainer(t *testing.T) {
+	require.Equal(t, "mp4", utils.DetectContainer([]byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00")))
+	require.Equal(t, "mov", utils.DetectContainer([]byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00")))
+	require.Equal(t, "mkv", utils.DetectContainer([]byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x88matroska")))
+	require.Equal(t, "webm", utils.DetectContainer([]byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm")))
+	require.Equal(t, "avi", utils.DetectContainer([]byte("RIFF\x00\x00\x00\x00AVI LIST")))
+	require.Equal(t, "", utils.DetectContainer([]byte("<html><body>not a video</body></html>")))
+}
+
+func TestDetectSubtitleFormat(t *testing.T) {
+	require.Equal(t, "vtt", utils.DetectSubtitleFormat([]byte("WEBVTT\n\n00:00.000 --> 00:01.000\nOlá")))
+	require.Equal(t, "vtt", utils.DetectSubtitleFormat([]byte("\xEF\xBB\xBFWEBVTT\n")))
+	require.Equal(t, "srt", utils.DetectSubtitleFormat([]byte("1\r\n00:00:01,000 --> 00:00:02,500\r\nOlá\r\n")))
+	require.Equal(t, "", utils.DetectSubtitleFormat([]byte("\x00\x00\x00\x20ftypisom")))
+	require.Equal(t, "", utils.DetectSubtitleFormat([]byte("<html><body>not a subtitle</body></html>")))
+}
+
+func TestSRTToWebVTT(t *testing.T) {
+	srt := "\xEF\xBB\xBF1\r\n00:00:01,000 --> 00:00:02,500\r\nOlá\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nTchau\r\n"
+
+	var vtt bytes.Buffer
+	require.Nil(t, utils.SRTToWebVTT(strings.NewReader(srt), &vtt))
+	require.Equal(t, "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nOlá\n\n2\n00:00:03.000 --> 00:00:04.000\nTchau\n", vtt.String())
+}
+
+func TestDetectAudioContainer(t *testing.T) {
+	require.Equal(t, "mp4", utils.DetectAudioContainer([]byte("\x00\x00\x00\x20ftypM4A \x00\x00\x02\x00")))
+	require.Equal(t, "mp3", utils.DetectAudioContainer([]byte("ID3\x04\x00\x00\x00\x00\x00\x00")))
+	require.Equal(t, "mp3", utils.DetectAudioContainer([]byte("\xFF\xFB\x90\x64")))
+	require.Equal(t, "aac", utils.DetectAudioContainer([]byte("\xFF\xF1\x50\x80")))
+	require.Equal(t, "wav", utils.DetectAudioContainer([]byte("RIFF\x00\x00\x00\x00WAVEfmt ")))
+	require.Equal(t, "ogg", utils.DetectAudioContainer([]byte("OggS\x00\x02")))
+	require.Equal(t, "", utils.DetectAudioContainer([]byte("WEBVTT\n")))
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	for _, address := range []string{"8.8.8.8", "142.250.78.14", "2001:4860:4860::8888"} {
+		require.True(t, utils.IsPublicAddress(net.ParseIP(address)), address)
+	}
+
+	for _, address := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.0.10", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		require.False(t, utils.IsPublicAddress(net.ParseIP(address)), address)
+	}
+}